@@ -0,0 +1,483 @@
+package Cx1ClientGo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Access review (recertification) report: who has access to what in the tenant.
+	The report lists every user and service account with their groups, direct roles, effective tenant-wide roles and
+	access assignments, and inverts this into a per-resource view. Users are flagged when they:
+	- are dormant (enabled, but not logged in for DormantDays or never logged in)
+	- hold roles directly instead of through groups
+	- hold a tenant admin role, or are the tenant owner
+	The report can be written as CSV, JSON or a standalone HTML page.
+*/
+
+const (
+	AccessReviewFlagDormant     = "dormant"
+	AccessReviewFlagNeverLogged = "never logged in"
+	AccessReviewFlagDirectRoles = "direct role grants"
+	AccessReviewFlagTenantAdmin = "tenant admin"
+	AccessReviewFlagTenantOwner = "tenant owner"
+)
+
+var defaultAccessReviewAdminRoles = []string{"ast-admin", "iam-admin"}
+var defaultAccessReviewIgnoredRoles = []string{"offline_access", "uma_authorization"}
+
+type accessReviewBuilder struct {
+	client      *Cx1Client
+	options     AccessReviewOptions
+	resolver    *PermissionResolver
+	assignments map[string][]AccessAssignment
+	tenantID    string
+	tenantName  string
+	cutoff      time.Time
+}
+
+// Generates the access review for all users and OIDC client service accounts in the tenant
+func (c Cx1Client) GetAccessReview(options AccessReviewOptions) (AccessReview, error) {
+	if options.DormantDays == 0 {
+		options.DormantDays = 90
+	}
+	if len(options.AdminRoles) == 0 {
+		options.AdminRoles = defaultAccessReviewAdminRoles
+	}
+	if options.IgnoredRoles == nil {
+		options.IgnoredRoles = defaultAccessReviewIgnoredRoles
+	}
+
+	review := AccessReview{
+		Tenant:      c.tenant,
+		GeneratedAt: time.Now().UTC(),
+		DormantDays: options.DormantDays,
+	}
+	c.logger.Infof("Generating access review for tenant %v", c.tenant)
+
+	b := accessReviewBuilder{
+		client:      &c,
+		options:     options,
+		resolver:    NewPermissionResolver(&c),
+		assignments: make(map[string][]AccessAssignment),
+		tenantID:    c.GetTenantID(),
+		tenantName:  c.GetTenantName(),
+		cutoff:      review.GeneratedAt.AddDate(0, 0, -options.DormantDays),
+	}
+
+	users, err := c.GetAllUsers()
+	if err != nil {
+		return review, fmt.Errorf("failed to get users: %s", err)
+	}
+
+	serviceAccounts, err := c.getAccessReviewServiceAccounts()
+	if err != nil {
+		return review, err
+	}
+
+	owner, err := c.GetTenantOwner()
+	if err != nil {
+		c.logger.Warnf("Failed to get tenant owner: %s", err)
+	}
+
+	seen := []string{}
+	for _, user := range users {
+		seen = append(seen, user.UserID)
+		entry, err := b.reviewUser(user, "")
+		if err != nil {
+			return review, err
+		}
+		review.Users = append(review.Users, entry)
+	}
+	for clientId, user := range serviceAccounts {
+		if slices.Contains(seen, user.UserID) {
+			continue
+		}
+		entry, err := b.reviewUser(user, clientId)
+		if err != nil {
+			return review, err
+		}
+		review.Users = append(review.Users, entry)
+	}
+
+	for id := range review.Users {
+		u := &review.Users[id]
+		if owner.UserID != "" && u.UserID == owner.UserID {
+			u.TenantOwner = true
+			u.Flags = append(u.Flags, AccessReviewFlagTenantOwner)
+		}
+	}
+
+	sort.Slice(review.Users, func(i, j int) bool {
+		return review.Users[i].UserName < review.Users[j].UserName
+	})
+	review.Resources = accessReviewResources(review.Users)
+
+	c.logger.Infof("Access review covers %d users and %d resources", len(review.Users), len(review.Resources))
+	return review, nil
+}
+
+// returns the service account users behind OIDC clients, keyed by client ID
+func (c Cx1Client) getAccessReviewServiceAccounts() (map[string]User, error) {
+	accounts := make(map[string]User)
+
+	clients, err := c.GetClients()
+	if err != nil {
+		return accounts, fmt.Errorf("failed to get OIDC clients: %s", err)
+	}
+
+	for _, client := range clients {
+		if enabled, ok := client.OIDCClientRaw["serviceAccountsEnabled"].(bool); ok && !enabled {
+			continue
+		}
+		user, err := c.GetServiceAccountByID(client.ID)
+		if err != nil {
+			c.logger.Tracef("Client %v has no service account: %s", client.String(), err)
+			continue
+		}
+		accounts[client.ClientID] = user
+	}
+	return accounts, nil
+}
+
+func (b *accessReviewBuilder) reviewUser(user User, clientId string) (AccessReviewUser, error) {
+	c := b.client
+	c.logger.Debugf("Reviewing access of user %v", user.String())
+
+	entry := AccessReviewUser{
+		UserID:         user.UserID,
+		UserName:       user.UserName,
+		Email:          user.Email,
+		Enabled:        user.Enabled,
+		ServiceAccount: clientId != "",
+		ClientID:       clientId,
+		LastLogin:      user.LastLogin.Time,
+		Groups:         []string{},
+		DirectRoles:    []string{},
+		Entitlements:   []AccessReviewEntitlement{},
+		Flags:          []string{},
+	}
+	tenant := EffectivePermissions{EntityID: user.UserID, EntityName: user.UserName, ResourceType: "tenant", ResourceID: b.tenantID}
+
+	roles, err := c.GetUserRoles(&user)
+	if err != nil {
+		return entry, fmt.Errorf("failed to get roles for user %v: %s", user.String(), err)
+	}
+	for _, role := range roles {
+		if err = b.resolver.addRoleGrants(&tenant, role, PermissionViaUserRole, user.UserName, []string{}); err != nil {
+			return entry, err
+		}
+		if !b.isIgnoredRole(role.Name) {
+			entry.DirectRoles = append(entry.DirectRoles, role.Name)
+		}
+	}
+	sort.Strings(entry.DirectRoles)
+	if len(entry.DirectRoles) > 0 {
+		entry.Entitlements = append(entry.Entitlements, AccessReviewEntitlement{
+			ResourceType: "tenant",
+			ResourceID:   b.tenantID,
+			ResourceName: b.tenantName,
+			Roles:        entry.DirectRoles,
+			Via:          "direct role",
+		})
+	}
+
+	if err = b.addAssignments(&entry, &tenant, user.UserID, "user", "assigned to user"); err != nil {
+		return entry, err
+	}
+
+	groups, err := c.GetUserGroups(&user)
+	if err != nil {
+		return entry, fmt.Errorf("failed to get groups for user %v: %s", user.String(), err)
+	}
+
+	seen := []string{}
+	for _, g := range groups {
+		entry.Groups = append(entry.Groups, g.Path)
+		ancestors, err := b.resolver.getAncestors(g)
+		if err != nil {
+			return entry, err
+		}
+		for _, group := range ancestors {
+			if slices.Contains(seen, group.GroupID) {
+				continue
+			}
+			seen = append(seen, group.GroupID)
+
+			via := "member"
+			if group.GroupID != g.GroupID {
+				via = fmt.Sprintf("member of subgroup %v", g.Path)
+			}
+			entry.Entitlements = append(entry.Entitlements, AccessReviewEntitlement{
+				ResourceType: "group",
+				ResourceID:   group.GroupID,
+				ResourceName: group.Path,
+				Roles:        accessReviewGroupRoles(group),
+				Via:          via,
+			})
+
+			if err = b.addAssignments(&entry, &tenant, group.GroupID, "group", fmt.Sprintf("assigned to group %v", group.Path)); err != nil {
+				return entry, err
+			}
+		}
+	}
+	sort.Strings(entry.Groups)
+
+	entry.TenantRoles = tenant.GetPermissions()
+	for _, role := range b.options.AdminRoles {
+		if tenant.Has(role) {
+			entry.TenantAdmin = true
+			break
+		}
+	}
+
+	if entry.Enabled && !entry.ServiceAccount {
+		if entry.LastLogin.IsZero() {
+			entry.Dormant = true
+			entry.Flags = append(entry.Flags, AccessReviewFlagNeverLogged)
+		} else if entry.LastLogin.Before(b.cutoff) {
+			entry.Dormant = true
+			entry.Flags = append(entry.Flags, AccessReviewFlagDormant)
+		}
+	}
+	if !entry.ServiceAccount && len(entry.DirectRoles) > 0 {
+		entry.DirectGrants = true
+		entry.Flags = append(entry.Flags, AccessReviewFlagDirectRoles)
+	}
+	if entry.TenantAdmin {
+		entry.Flags = append(entry.Flags, AccessReviewFlagTenantAdmin)
+	}
+
+	return entry, nil
+}
+
+// adds the access assignments of the entity as entitlements, and tenant-level assignments to the tenant roles
+func (b *accessReviewBuilder) addAssignments(entry *AccessReviewUser, tenant *EffectivePermissions, entityId, entityType, via string) error {
+	assignments, ok := b.assignments[entityId]
+	if !ok {
+		var err error
+		assignments, err = b.client.GetResourcesAccessibleToEntityByID(entityId, entityType, permissionResourceTypes)
+		if err != nil {
+			return fmt.Errorf("failed to get access assignments for %v %v: %s", entityType, entityId, err)
+		}
+		b.assignments[entityId] = assignments
+	}
+
+	for _, a := range assignments {
+		roles := []string{}
+		for _, ar := range a.EntityRoles {
+			roles = append(roles, ar.Name)
+			if a.ResourceType == "tenant" {
+				role, err := b.resolver.getAssignedRole(ar)
+				if err != nil {
+					return err
+				}
+				if err = b.resolver.addRoleGrants(tenant, role, PermissionViaAssignment, via, []string{}); err != nil {
+					return err
+				}
+			}
+		}
+		sort.Strings(roles)
+
+		name := a.ResourceName
+		if a.ResourceType == "tenant" && name == "" {
+			name = b.tenantName
+		}
+		entry.Entitlements = append(entry.Entitlements, AccessReviewEntitlement{
+			ResourceType: a.ResourceType,
+			ResourceID:   a.ResourceID,
+			ResourceName: name,
+			Roles:        roles,
+			Via:          via,
+		})
+	}
+	return nil
+}
+
+func (b *accessReviewBuilder) isIgnoredRole(name string) bool {
+	return strings.HasPrefix(name, "default-roles-") || slices.Contains(b.options.IgnoredRoles, name)
+}
+
+// returns the group's client roles, prefixed with the client name unless it is ast-app
+func accessReviewGroupRoles(group Group) []string {
+	roles := []string{}
+	for client, names := range group.ClientRoles {
+		for _, name := range names {
+			if client == "ast-app" {
+				roles = append(roles, name)
+			} else {
+				roles = append(roles, client+"/"+name)
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// inverts the per-user entitlements into the per-resource view
+func accessReviewResources(users []AccessReviewUser) []AccessReviewResource {
+	index := make(map[string]int)
+	resources := []AccessReviewResource{}
+
+	for _, u := range users {
+		for _, e := range u.Entitlements {
+			key := e.ResourceType + "/" + e.ResourceID
+			id, ok := index[key]
+			if !ok {
+				id = len(resources)
+				index[key] = id
+				resources = append(resources, AccessReviewResource{
+					ResourceType: e.ResourceType,
+					ResourceID:   e.ResourceID,
+					ResourceName: e.ResourceName,
+					Entries:      []AccessReviewGrantEntry{},
+				})
+			}
+			resources[id].Entries = append(resources[id].Entries, AccessReviewGrantEntry{
+				UserID:   u.UserID,
+				UserName: u.UserName,
+				Roles:    e.Roles,
+				Via:      e.Via,
+			})
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].ResourceType != resources[j].ResourceType {
+			return resources[i].ResourceType < resources[j].ResourceType
+		}
+		return resources[i].ResourceName < resources[j].ResourceName
+	})
+	return resources
+}
+
+// Returns the users with any of the flags set
+func (r AccessReview) GetFlaggedUsers() []AccessReviewUser {
+	users := []AccessReviewUser{}
+	for _, u := range r.Users {
+		if len(u.Flags) > 0 {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+func (r AccessReview) String() string {
+	return fmt.Sprintf("Access review of tenant %v at %v: %d users (%d flagged), %d resources", r.Tenant, r.GeneratedAt.Format(time.RFC3339), len(r.Users), len(r.GetFlaggedUsers()), len(r.Resources))
+}
+
+func (u AccessReviewUser) String() string {
+	if len(u.Flags) == 0 {
+		return u.UserName
+	}
+	return fmt.Sprintf("%v [%v]", u.UserName, strings.Join(u.Flags, ", "))
+}
+
+func (r AccessReview) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Writes the per-user matrix as CSV with one row per user entitlement. Users without entitlements have a single row.
+func (r AccessReview) WriteUsersCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Username", "Email", "Enabled", "Service Account", "Client ID", "Last Login", "Groups", "Direct Roles",
+		"Tenant Roles", "Flags", "Resource Type", "Resource Name", "Resource ID", "Roles", "Via"}); err != nil {
+		return err
+	}
+
+	for _, u := range r.Users {
+		lastLogin := ""
+		if !u.LastLogin.IsZero() {
+			lastLogin = u.LastLogin.Format(time.RFC3339)
+		}
+		prefix := []string{u.UserName, u.Email, fmt.Sprintf("%t", u.Enabled), fmt.Sprintf("%t", u.ServiceAccount), u.ClientID, lastLogin,
+			strings.Join(u.Groups, ";"), strings.Join(u.DirectRoles, ";"), strings.Join(u.TenantRoles, ";"), strings.Join(u.Flags, ";")}
+
+		if len(u.Entitlements) == 0 {
+			if err := writer.Write(append(prefix, "", "", "", "", "")); err != nil {
+				return err
+			}
+		}
+		for _, e := range u.Entitlements {
+			row := append(append([]string{}, prefix...), e.ResourceType, e.ResourceName, e.ResourceID, strings.Join(e.Roles, ";"), e.Via)
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the per-resource matrix as CSV with one row per user with access to the resource
+func (r AccessReview) WriteResourcesCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Resource Type", "Resource Name", "Resource ID", "Username", "Roles", "Via"}); err != nil {
+		return err
+	}
+
+	for _, res := range r.Resources {
+		for _, e := range res.Entries {
+			if err := writer.Write([]string{res.ResourceType, res.ResourceName, res.ResourceID, e.UserName, strings.Join(e.Roles, ";"), e.Via}); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+var accessReviewHTML = template.Must(template.New("accessreview").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format("2006-01-02")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Access review: {{.Tenant}}</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 3px 6px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+tr.flagged td { background: #fff3e0; }
+</style>
+</head>
+<body>
+<h1>Access review: {{.Tenant}}</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}. Users without a login in the last {{.DormantDays}} days are flagged as dormant.</p>
+<h2>Users</h2>
+<table>
+<tr><th>Username</th><th>Email</th><th>Enabled</th><th>Last login</th><th>Groups</th><th>Direct roles</th><th>Tenant roles</th><th>Flags</th></tr>
+{{range .Users}}<tr{{if .Flags}} class="flagged"{{end}}><td>{{.UserName}}{{if .ServiceAccount}} (client {{.ClientID}}){{end}}</td><td>{{.Email}}</td><td>{{.Enabled}}</td><td>{{date .LastLogin}}</td><td>{{join .Groups ", "}}</td><td>{{join .DirectRoles ", "}}</td><td>{{join .TenantRoles ", "}}</td><td>{{join .Flags ", "}}</td></tr>
+{{end}}</table>
+<h2>Resources</h2>
+<table>
+<tr><th>Type</th><th>Resource</th><th>Username</th><th>Roles</th><th>Via</th></tr>
+{{range $r := .Resources}}{{range .Entries}}<tr><td>{{$r.ResourceType}}</td><td>{{$r.ResourceName}}</td><td>{{.UserName}}</td><td>{{join .Roles ", "}}</td><td>{{.Via}}</td></tr>
+{{end}}{{end}}</table>
+</body>
+</html>
+`))
+
+// Writes the report as a standalone HTML page
+func (r AccessReview) WriteHTML(w io.Writer) error {
+	return accessReviewHTML.Execute(w, r)
+}
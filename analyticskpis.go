@@ -0,0 +1,522 @@
+package Cx1ClientGo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Typed access to the analytics KPI catalogue and helpers for dashboards.
+
+	The KPIs served by the analytics API are listed as AnalyticsKPI* constants, GetAnalyticsKPI fetches any of them
+	into a caller-provided type. On top of those, the following are derived from one or more KPIs:
+	  - fix rate over time: fixed vs. total vulnerabilities per severity (GetAnalyticsFixRateOvertime)
+	  - ageing: the oldest open vulnerabilities together with mean time to resolution (GetAnalyticsAgeing)
+	  - per-application risk: a weighted severity total per application (GetAnalyticsApplicationRisk)
+	  - mean time to resolution per severity compared with a target (GetAnalyticsMeanTimeWithinTarget), this only
+	    compares the mean: individual results resolved after the target are counted by ResultMetrics.GetSLABreaches
+	Scan volume is not an analytics KPI, it is counted from the scan list (GetAnalyticsScanVolumeOvertime) and
+	returned in the same shape as the other over-time series.
+
+	Filters take IDs, NewAnalyticsFilter resolves project, application and group names, and SetRelativeDateRange
+	accepts ranges such as "last 90 days". Series can be written as CSV (long format: one row per point) or JSON.
+*/
+
+const (
+	AnalyticsKPIVulnerabilitiesBySeverityTotal         = "vulnerabilitiesBySeverityTotal"
+	AnalyticsKPIVulnerabilitiesByStateTotal            = "vulnerabilitiesByStateTotal"
+	AnalyticsKPIVulnerabilitiesByStatusTotal           = "vulnerabilitiesByStatusTotal"
+	AnalyticsKPIVulnerabilitiesBySeverityAndStateTotal = "vulnerabilitiesBySeverityAndStateTotal"
+	AnalyticsKPIVulnerabilitiesBySeverityOvertime      = "vulnerabilitiesBySeverityOvertime"
+	AnalyticsKPIFixedVulnerabilitiesBySeverityOvertime = "fixedVulnerabilitiesBySeverityOvertime"
+	AnalyticsKPIMeanTimeToResolution                   = "meanTimeToResolution"
+	AnalyticsKPIMostCommonVulnerabilities              = "mostCommonVulnerabilities"
+	AnalyticsKPIMostAgingVulnerabilities               = "mostAgingVulnerabilities"
+)
+
+// Default SLA targets in days per severity, used by GetAnalyticsMeanTimeWithinTarget and ResultMetrics when no
+// targets are given
+var AnalyticsDefaultSLATargets = map[string]int64{
+	"critical": 7,
+	"high":     30,
+	"medium":   90,
+	"low":      180,
+}
+
+// Weight per severity used for the application risk score
+var analyticsRiskWeights = map[string]float64{
+	"critical": 10,
+	"high":     5,
+	"medium":   2,
+	"low":      1,
+}
+
+// Fetches any analytics KPI and unmarshals the response into v, for KPIs without a typed function
+func (c Cx1Client) GetAnalyticsKPI(kpi string, limit uint64, filter AnalyticsFilter, v interface{}) error {
+	data, err := c.getAnalytics(kpi, limit, filter)
+	if err != nil {
+		return fmt.Errorf("failed to fetch analytics KPI %v: %s", kpi, err)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse analytics KPI %v: %s", kpi, err)
+	}
+	return nil
+}
+
+// Builds an AnalyticsFilter from names, groups are resolved by path if the name contains a '/'
+func (c Cx1Client) NewAnalyticsFilter(names AnalyticsFilterNames) (AnalyticsFilter, error) {
+	filter := AnalyticsFilter{
+		ApplicationTags: names.ApplicationTags,
+		ProjectTags:     names.ProjectTags,
+		ScanTags:        names.ScanTags,
+		BranchNames:     names.BranchNames,
+		Scanners:        names.Scanners,
+		Severities:      names.Severities,
+		States:          names.States,
+		Timezone:        names.Timezone,
+	}
+
+	for _, name := range names.Projects {
+		project, err := c.GetProjectByName(name)
+		if err != nil {
+			return filter, fmt.Errorf("failed to resolve project %v: %s", name, err)
+		}
+		filter.Projects = append(filter.Projects, project.ProjectID)
+	}
+
+	for _, name := range names.Applications {
+		application, err := c.GetApplicationByName(name)
+		if err != nil {
+			return filter, fmt.Errorf("failed to resolve application %v: %s", name, err)
+		}
+		filter.Applications = append(filter.Applications, application.ApplicationID)
+	}
+
+	for _, name := range names.Groups {
+		var group Group
+		var err error
+		if strings.Contains(name, "/") {
+			group, err = c.GetGroupByPath(name)
+		} else {
+			group, err = c.GetGroupByName(name)
+		}
+		if err != nil {
+			return filter, fmt.Errorf("failed to resolve group %v: %s", name, err)
+		}
+		filter.Groups = append(filter.Groups, group.GroupID)
+	}
+
+	if names.DateRange != "" {
+		if err := filter.SetRelativeDateRange(names.DateRange); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// Sets the start and end date of the filter
+func (f *AnalyticsFilter) SetDateRange(start, end time.Time) {
+	f.StartDate = &AnalyticsTime{Time: start}
+	f.EndDate = &AnalyticsTime{Time: end}
+}
+
+// Sets the date range of the filter relative to now, see ParseAnalyticsDateRange
+func (f *AnalyticsFilter) SetRelativeDateRange(expression string) error {
+	start, end, err := ParseAnalyticsDateRange(expression, time.Now())
+	if err != nil {
+		return err
+	}
+	f.SetDateRange(start, end)
+	return nil
+}
+
+// Parses a relative date range and returns the start and end relative to now. Supported are
+// "last N days|weeks|months|years" (also singular, eg: "last month"), "today", "yesterday",
+// "this week|month|year" and "year to date" / "ytd". Ranges start at midnight in now's location.
+func ParseAnalyticsDateRange(expression string, now time.Time) (time.Time, time.Time, error) {
+	expr := strings.Join(strings.Fields(strings.ToLower(expression)), " ")
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch expr {
+	case "today":
+		return midnight, now, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), midnight, nil
+	case "this week":
+		offset := (int(midnight.Weekday()) + 6) % 7 // weeks start on monday
+		return midnight.AddDate(0, 0, -offset), now, nil
+	case "this month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now, nil
+	case "this year", "year to date", "ytd":
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()), now, nil
+	}
+
+	parts := strings.Split(expr, " ")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "last" {
+		return time.Time{}, time.Time{}, fmt.Errorf("unsupported date range '%v'", expression)
+	}
+
+	count := 1
+	unit := parts[1]
+	if len(parts) == 3 {
+		var err error
+		if count, err = strconv.Atoi(parts[1]); err != nil || count <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid count in date range '%v'", expression)
+		}
+		unit = parts[2]
+	}
+
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		return midnight.AddDate(0, 0, -count), now, nil
+	case "week":
+		return midnight.AddDate(0, 0, -7*count), now, nil
+	case "month":
+		return midnight.AddDate(0, -count, 0), now, nil
+	case "year":
+		return midnight.AddDate(-count, 0, 0), now, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unsupported unit '%v' in date range '%v'", unit, expression)
+}
+
+// Returns the percentage of vulnerabilities fixed per severity over time, computed as
+// fixed / (fixed + open) for each point of the fixedVulnerabilitiesBySeverityOvertime and
+// vulnerabilitiesBySeverityOvertime KPIs. Points without vulnerabilities have a fix rate of 0.
+func (c Cx1Client) GetAnalyticsFixRateOvertime(filter AnalyticsFilter) ([]AnalyticsOverTimeStats, error) {
+	rates := []AnalyticsOverTimeStats{}
+
+	open, err := c.GetAnalyticsVulnerabilitiesBySeverityOvertime(filter)
+	if err != nil {
+		return rates, fmt.Errorf("failed to get vulnerabilities over time: %s", err)
+	}
+	fixed, err := c.GetAnalyticsFixedVulnerabilitiesBySeverityOvertime(filter)
+	if err != nil {
+		return rates, fmt.Errorf("failed to get fixed vulnerabilities over time: %s", err)
+	}
+
+	openIndex := make(map[string]map[uint64]float32)
+	for _, series := range open {
+		points := make(map[uint64]float32, len(series.Values))
+		for _, v := range series.Values {
+			points[v.Time] = v.Value
+		}
+		openIndex[strings.ToLower(series.Label)] = points
+	}
+
+	for _, series := range fixed {
+		rate := AnalyticsOverTimeStats{
+			Label:  series.Label,
+			Values: make([]AnalyticsOverTimeEntry, 0, len(series.Values)),
+		}
+		openPoints := openIndex[strings.ToLower(series.Label)]
+		for _, v := range series.Values {
+			point := AnalyticsOverTimeEntry{Time: v.Time, Date: v.Date}
+			if total := v.Value + openPoints[v.Time]; total > 0 {
+				point.Value = 100 * v.Value / total
+			}
+			rate.Values = append(rate.Values, point)
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+// Returns the oldest open vulnerabilities (up to limit) together with the mean time to resolution per severity
+func (c Cx1Client) GetAnalyticsAgeing(limit uint64, filter AnalyticsFilter) (AnalyticsAgeingStats, error) {
+	var stats AnalyticsAgeingStats
+	var err error
+
+	if stats.MostAging, err = c.GetAnalyticsMostAgingVulnerabilities(limit, filter); err != nil {
+		return stats, fmt.Errorf("failed to get most aging vulnerabilities: %s", err)
+	}
+	if stats.MeanTimeToResolution, err = c.GetAnalyticsMeanTimeToResolution(filter); err != nil {
+		return stats, fmt.Errorf("failed to get mean time to resolution: %s", err)
+	}
+	return stats, nil
+}
+
+// Returns the number of scans per day and status within the filter's date range (default: last 30 days),
+// as over-time series labelled by status plus a "Total" series. Only the Projects, Applications and
+// BranchNames of the filter are applied.
+func (c Cx1Client) GetAnalyticsScanVolumeOvertime(filter AnalyticsFilter) ([]AnalyticsOverTimeStats, error) {
+	series := []AnalyticsOverTimeStats{}
+
+	end := time.Now()
+	if filter.EndDate != nil {
+		end = filter.EndDate.Time
+	}
+	start := end.AddDate(0, 0, -30)
+	if filter.StartDate != nil {
+		start = filter.StartDate.Time
+	}
+
+	projectIDs := make(map[string]bool)
+	for _, id := range filter.Projects {
+		projectIDs[id] = true
+	}
+	for _, id := range filter.Applications {
+		application, err := c.GetApplicationByID(id)
+		if err != nil {
+			return series, fmt.Errorf("failed to get application %v: %s", id, err)
+		}
+		if application.ProjectIds != nil {
+			for _, pid := range *application.ProjectIds {
+				projectIDs[pid] = true
+			}
+		}
+	}
+
+	// the tenant-wide list is only used when no projects or applications are given
+	scanFilters := []ScanFilter{}
+	if len(filter.Projects) == 0 && len(filter.Applications) == 0 {
+		scanFilters = append(scanFilters, ScanFilter{})
+	} else {
+		ids := make([]string, 0, len(projectIDs))
+		for id := range projectIDs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			scanFilters = append(scanFilters, ScanFilter{ProjectID: id})
+		}
+	}
+
+	scans := []Scan{}
+	for _, scanFilter := range scanFilters {
+		scanFilter.BaseFilter = BaseFilter{Limit: c.pagination.Scans}
+		scanFilter.Branches = filter.BranchNames
+		scanFilter.FromDate = start
+		scanFilter.ToDate = end
+		_, projectScans, err := c.GetAllScansFiltered(scanFilter)
+		if err != nil {
+			return series, fmt.Errorf("failed to get scans between %v and %v: %s", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+		}
+		scans = append(scans, projectScans...)
+	}
+
+	counts := make(map[string]map[time.Time]float32)
+	for _, scan := range scans {
+		created, err := time.Parse(time.RFC3339Nano, scan.CreatedAt)
+		if err != nil {
+			c.logger.Tracef("Skipping scan %v with invalid creation time %v: %s", scan.ScanID, scan.CreatedAt, err)
+			continue
+		}
+		created = created.In(start.Location())
+		day := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, start.Location())
+		for _, label := range []string{scan.Status, "Total"} {
+			if counts[label] == nil {
+				counts[label] = make(map[time.Time]float32)
+			}
+			counts[label][day]++
+		}
+	}
+
+	labels := make([]string, 0, len(counts))
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for _, label := range labels {
+		s := AnalyticsOverTimeStats{Label: label, Values: []AnalyticsOverTimeEntry{}}
+		for day := firstDay; !day.After(end); day = day.AddDate(0, 0, 1) {
+			s.Values = append(s.Values, AnalyticsOverTimeEntry{
+				Time:  uint64(day.UnixMilli()),
+				Value: counts[label][day],
+				Date:  AnalyticsTime{Time: day},
+			})
+		}
+		series = append(series, s)
+	}
+
+	return series, nil
+}
+
+// Returns the vulnerabilities per severity and a risk score for each application in the filter, or for all
+// applications if the filter has none, sorted by descending risk. The score is the severity-weighted number
+// of vulnerabilities (critical 10, high 5, medium 2, low 1) multiplied by the application's criticality.
+func (c Cx1Client) GetAnalyticsApplicationRisk(filter AnalyticsFilter) ([]AnalyticsApplicationRisk, error) {
+	risks := []AnalyticsApplicationRisk{}
+
+	var applications []Application
+	if len(filter.Applications) == 0 {
+		var err error
+		if applications, err = c.GetAllApplications(); err != nil {
+			return risks, fmt.Errorf("failed to get applications: %s", err)
+		}
+	} else {
+		for _, id := range filter.Applications {
+			application, err := c.GetApplicationByID(id)
+			if err != nil {
+				return risks, fmt.Errorf("failed to get application %v: %s", id, err)
+			}
+			applications = append(applications, application)
+		}
+	}
+
+	for _, application := range applications {
+		appFilter := filter
+		appFilter.Applications = []string{application.ApplicationID}
+		stats, err := c.GetAnalyticsVulnerabilitiesBySeverityTotal(appFilter)
+		if err != nil {
+			return risks, fmt.Errorf("failed to get vulnerabilities for application %v: %s", application.String(), err)
+		}
+
+		risk := AnalyticsApplicationRisk{
+			ApplicationID: application.ApplicationID,
+			Name:          application.Name,
+			Criticality:   application.Criticality,
+			Severities:    make(map[string]uint64),
+		}
+		for _, block := range stats.Distribution {
+			for _, entry := range block.Values {
+				severity := strings.ToLower(entry.Label)
+				risk.Severities[severity] += entry.Results
+				risk.Total += entry.Results
+				risk.Score += analyticsRiskWeights[severity] * float64(entry.Results)
+			}
+		}
+		if application.Criticality > 0 {
+			risk.Score *= float64(application.Criticality)
+		}
+		risks = append(risks, risk)
+	}
+
+	sort.SliceStable(risks, func(i, j int) bool { return risks[i].Score > risks[j].Score })
+	return risks, nil
+}
+
+// Compares the mean time to resolution per severity with the targets in days. The analytics API reports the
+// mean time in days, as shown on the Cx1 analytics dashboard. A mean within the target does not mean that no
+// result breached it, see ResultMetrics.GetSLABreaches for that.
+// Severities are matched case-insensitively, if targets is nil AnalyticsDefaultSLATargets is used.
+func (c Cx1Client) GetAnalyticsMeanTimeWithinTarget(filter AnalyticsFilter, targets map[string]int64) ([]AnalyticsMeanTimeTarget, error) {
+	compliance := []AnalyticsMeanTimeTarget{}
+	if targets == nil {
+		targets = AnalyticsDefaultSLATargets
+	}
+
+	stats, err := c.GetAnalyticsMeanTimeToResolution(filter)
+	if err != nil {
+		return compliance, fmt.Errorf("failed to get mean time to resolution: %s", err)
+	}
+
+	for _, entry := range stats.MeanTimeData {
+		target, ok := targets[strings.ToLower(entry.Label)]
+		if !ok {
+			continue
+		}
+		compliance = append(compliance, AnalyticsMeanTimeTarget{
+			Severity:     entry.Label,
+			Results:      entry.Results,
+			MeanTimeDays: entry.MeanTime,
+			TargetDays:   target,
+			WithinTarget: entry.MeanTime <= target,
+		})
+	}
+	return compliance, nil
+}
+
+func (r AnalyticsApplicationRisk) String() string {
+	return fmt.Sprintf("[%v] %v: risk %.1f (%d vulnerabilities)", ShortenGUID(r.ApplicationID), r.Name, r.Score, r.Total)
+}
+
+func (s AnalyticsMeanTimeTarget) String() string {
+	status := "within target"
+	if !s.WithinTarget {
+		status = "over target"
+	}
+	return fmt.Sprintf("%v: mean time to resolution %d days vs. target %d days - %v", s.Severity, s.MeanTimeDays, s.TargetDays, status)
+}
+
+// Writes over-time series as CSV with the columns series, date, time (ms), value
+func WriteAnalyticsOverTimeCSV(w io.Writer, stats []AnalyticsOverTimeStats) error {
+	rows := [][]string{{"series", "date", "time", "value"}}
+	for _, series := range stats {
+		for _, v := range series.Values {
+			rows = append(rows, []string{
+				series.Label,
+				v.Date.Format(AnalyticsTimeLayout),
+				strconv.FormatUint(v.Time, 10),
+				strconv.FormatFloat(float64(v.Value), 'f', -1, 32),
+			})
+		}
+	}
+	return writeAnalyticsCSV(w, rows)
+}
+
+// Writes distribution stats as CSV with the columns group, label, results, percentage, density
+func WriteAnalyticsDistributionCSV(w io.Writer, stats AnalyticsDistributionStats) error {
+	rows := [][]string{{"group", "label", "results", "percentage", "density"}}
+	for _, block := range stats.Distribution {
+		for _, v := range block.Values {
+			rows = append(rows, []string{
+				block.Label,
+				v.Label,
+				strconv.FormatUint(v.Results, 10),
+				strconv.FormatFloat(float64(v.Percentage), 'f', -1, 32),
+				strconv.FormatFloat(float64(v.Density), 'f', -1, 32),
+			})
+		}
+	}
+	return writeAnalyticsCSV(w, rows)
+}
+
+// Writes application risk as CSV with one column per severity
+func WriteAnalyticsApplicationRiskCSV(w io.Writer, risks []AnalyticsApplicationRisk) error {
+	severities := []string{"critical", "high", "medium", "low"}
+	rows := [][]string{append([]string{"application_id", "application", "criticality", "score", "total"}, severities...)}
+	for _, r := range risks {
+		row := []string{
+			r.ApplicationID,
+			r.Name,
+			strconv.FormatUint(uint64(r.Criticality), 10),
+			strconv.FormatFloat(r.Score, 'f', -1, 64),
+			strconv.FormatUint(r.Total, 10),
+		}
+		for _, severity := range severities {
+			row = append(row, strconv.FormatUint(r.Severities[severity], 10))
+		}
+		rows = append(rows, row)
+	}
+	return writeAnalyticsCSV(w, rows)
+}
+
+// Writes mean time targets as CSV with the columns severity, results, mean_time_days, target_days, within_target
+func WriteAnalyticsMeanTimeTargetsCSV(w io.Writer, targets []AnalyticsMeanTimeTarget) error {
+	rows := [][]string{{"severity", "results", "mean_time_days", "target_days", "within_target"}}
+	for _, s := range targets {
+		rows = append(rows, []string{
+			s.Severity,
+			strconv.FormatInt(s.Results, 10),
+			strconv.FormatInt(s.MeanTimeDays, 10),
+			strconv.FormatInt(s.TargetDays, 10),
+			strconv.FormatBool(s.WithinTarget),
+		})
+	}
+	return writeAnalyticsCSV(w, rows)
+}
+
+// Writes any analytics response or series as indented JSON
+func WriteAnalyticsJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeAnalyticsCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write csv: %s", err)
+	}
+	return nil
+}
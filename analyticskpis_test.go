@@ -0,0 +1,143 @@
+package Cx1ClientGo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestParseAnalyticsDateRange(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, time.March, 13, 15, 30, 0, 0, time.UTC)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expression string
+		from       time.Time
+		to         time.Time
+		invalid    bool
+	}{
+		{expression: "today", from: day(2024, time.March, 13), to: now},
+		{expression: "yesterday", from: day(2024, time.March, 12), to: day(2024, time.March, 13)},
+		{expression: "this week", from: day(2024, time.March, 11), to: now},
+		{expression: "this month", from: day(2024, time.March, 1), to: now},
+		{expression: "this year", from: day(2024, time.January, 1), to: now},
+		{expression: "Year To Date", from: day(2024, time.January, 1), to: now},
+		{expression: "ytd", from: day(2024, time.January, 1), to: now},
+		{expression: "last 90 days", from: day(2023, time.December, 14), to: now},
+		{expression: "  Last   7  Days ", from: day(2024, time.March, 6), to: now},
+		{expression: "last day", from: day(2024, time.March, 12), to: now},
+		{expression: "last 2 weeks", from: day(2024, time.February, 28), to: now},
+		{expression: "last month", from: day(2024, time.February, 13), to: now},
+		{expression: "last 3 months", from: day(2023, time.December, 13), to: now},
+		{expression: "last year", from: day(2023, time.March, 13), to: now},
+		{expression: "", invalid: true},
+		{expression: "last", invalid: true},
+		{expression: "next 3 days", invalid: true},
+		{expression: "last 0 days", invalid: true},
+		{expression: "last -1 days", invalid: true},
+		{expression: "last x days", invalid: true},
+		{expression: "last 3 fortnights", invalid: true},
+		{expression: "last 3 days ago", invalid: true},
+	}
+
+	for _, test := range tests {
+		from, to, err := ParseAnalyticsDateRange(test.expression, now)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParseAnalyticsDateRange(%q): expected an error, got %v - %v", test.expression, from, to)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAnalyticsDateRange(%q): unexpected error %s", test.expression, err)
+			continue
+		}
+		if !from.Equal(test.from) || !to.Equal(test.to) {
+			t.Errorf("ParseAnalyticsDateRange(%q) = %v - %v, expected %v - %v", test.expression, from, to, test.from, test.to)
+		}
+	}
+
+	// this week on a sunday starts on the previous monday
+	sunday := time.Date(2024, time.March, 17, 10, 0, 0, 0, time.UTC)
+	if from, _, err := ParseAnalyticsDateRange("this week", sunday); err != nil || !from.Equal(day(2024, time.March, 11)) {
+		t.Errorf("ParseAnalyticsDateRange(this week) on a sunday = %v, %v, expected %v", from, err, day(2024, time.March, 11))
+	}
+}
+
+func TestGetAnalyticsScanVolumeOvertimeQueries(t *testing.T) {
+	created := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	var queried []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/applications/app":
+			w.Write([]byte(`{"id": "app", "projectIds": ["p3", "p2"]}`))
+		case "/api/applications/empty":
+			w.Write([]byte(`{"id": "empty", "projectIds": []}`))
+		case "/api/scans":
+			projectID := r.URL.Query().Get("project-id")
+			queried = append(queried, projectID)
+			projects := []string{projectID}
+			if projectID == "" {
+				projects = []string{"p1", "p9"}
+			}
+			scans := []string{}
+			for _, id := range projects {
+				scans = append(scans, fmt.Sprintf(`{"id": "scan-%v", "status": "Completed", "createdAt": "%v", "projectId": "%v"}`, id, created, id))
+			}
+			fmt.Fprintf(w, `{"filteredTotalCount": %d, "scans": [%v]}`, len(scans), strings.Join(scans, ","))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := Cx1Client{
+		httpClient: server.Client(),
+		baseUrl:    server.URL,
+		logger:     testLogger{t},
+		auth:       Cx1ClientAuth{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name    string
+		filter  AnalyticsFilter
+		queried []string // project-id of each scans request, empty for the tenant-wide list
+		total   float32
+	}{
+		{"tenant", AnalyticsFilter{}, []string{""}, 2},
+		{"projects", AnalyticsFilter{Projects: []string{"p2", "p1"}}, []string{"p1", "p2"}, 2},
+		{"projects and application", AnalyticsFilter{Projects: []string{"p2"}, Applications: []string{"app"}}, []string{"p2", "p3"}, 2},
+		{"application without projects", AnalyticsFilter{Applications: []string{"empty"}}, nil, 0},
+	}
+
+	for _, test := range tests {
+		queried = nil
+		series, err := client.GetAnalyticsScanVolumeOvertime(test.filter)
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", test.name, err)
+			continue
+		}
+		if !slices.Equal(queried, test.queried) {
+			t.Errorf("%v: queried scans for projects %q, expected %q", test.name, queried, test.queried)
+		}
+		var total float32
+		for _, s := range series {
+			if s.Label == "Total" {
+				for _, v := range s.Values {
+					total += v.Value
+				}
+			}
+		}
+		if total != test.total {
+			t.Errorf("%v: counted %v scans, expected %v", test.name, total, test.total)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package Cx1ClientGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+/*
+	API Security risks are not part of the /results response, they are retrieved per scan from the API Security
+	service. Each risk is tied to an endpoint (HTTP method + URL) and may reference the SAST result it was derived
+	from via SASTRiskID. The platform does not currently expose triage for API Security risks, so there are no
+	predicate functions for them.
+*/
+
+const apisecRisksPath = "/apisec/static/api/risks"
+
+// Returns all API Security risks found in a scan
+func (c Cx1Client) GetScanAPISecResultsByID(scanID string) ([]ScanAPISecResult, error) {
+	c.logger.Debugf("Get all API Security risks for scan %v", scanID)
+
+	var response struct {
+		Entries      []ScanAPISecResult `json:"entries"`
+		TotalRecords uint64             `json:"total_records"`
+		HasNext      bool               `json:"has_next"`
+	}
+
+	results := []ScanAPISecResult{}
+	pageSize := c.pagination.Results
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	for page := uint64(1); ; page++ {
+		params := url.Values{
+			"page":     {strconv.FormatUint(page, 10)},
+			"per_page": {strconv.FormatUint(pageSize, 10)},
+		}
+		data, err := c.sendRequest(http.MethodGet, fmt.Sprintf("%v/%v?%v", apisecRisksPath, scanID, params.Encode()), nil, nil)
+		if err != nil {
+			return results, fmt.Errorf("failed to fetch API Security risks for scan %v: %s", scanID, err)
+		}
+
+		response.Entries = nil
+		response.HasNext = false
+		if err = json.Unmarshal(data, &response); err != nil {
+			return results, fmt.Errorf("failed to parse API Security risks for scan %v: %s", scanID, err)
+		}
+
+		for id := range response.Entries {
+			response.Entries[id].ScanID = scanID
+		}
+		results = append(results, response.Entries...)
+
+		if !response.HasNext || len(response.Entries) == 0 || uint64(len(results)) >= response.TotalRecords {
+			break
+		}
+	}
+
+	c.logger.Debugf("Retrieved %d API Security risks for scan %v", len(results), scanID)
+	return results, nil
+}
+
+// Returns the API Security risks which were derived from the given SAST result
+func (c Cx1Client) GetScanAPISecResultsBySASTResult(scanID string, result *ScanSASTResult) ([]ScanAPISecResult, error) {
+	risks, err := c.GetScanAPISecResultsByID(scanID)
+	if err != nil {
+		return risks, err
+	}
+
+	matching := []ScanAPISecResult{}
+	for _, r := range risks {
+		if r.SASTRiskID == result.SimilarityID {
+			matching = append(matching, r)
+		}
+	}
+	return matching, nil
+}
+
+// Returns the endpoint in the form "METHOD url"
+func (r ScanAPISecResult) Endpoint() string {
+	return fmt.Sprintf("%v %v", r.HTTPMethod, r.URL)
+}
+
+func (r ScanAPISecResult) String() string {
+	return fmt.Sprintf("%v (%v) - %v risk on %v", r.Name, ShortenGUID(r.RiskID), r.Severity, r.Endpoint())
+}
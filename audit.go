@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 /*
@@ -100,8 +102,11 @@ func (c Cx1Client) AuditCreateSession(engine, filter string) (AuditSession, erro
 
 }
 
-func (c Cx1Client) AuditCreateSessionByID(engine, projectId, scanId string) (AuditSession, error) {
+func (c Cx1Client) AuditCreateSessionByID(engine, projectId, scanId string) (session AuditSession, err error) {
 	engine = strings.ToLower(engine)
+	c, span := c.startOperation("AuditCreateSessionByID", attribute.String("cx1.audit.engine", engine), attribute.String("cx1.project.id", projectId), attribute.String("cx1.scan.id", scanId))
+	defer func() { endOperation(span, err) }()
+
 	c.logger.Debugf("Trying to create %v audit session for project %v scan %v", engine, projectId, scanId)
 	/*available, _, err := c.AuditFindSessionsByID(projectId, scanId)
 	if err != nil {
@@ -112,7 +117,6 @@ func (c Cx1Client) AuditCreateSessionByID(engine, projectId, scanId string) (Aud
 		return "", fmt.Errorf("audit session not available")
 	}*/
 
-	var session AuditSession
 	var appId string
 
 	if engine != "sast" && engine != "iac" {
@@ -177,6 +181,7 @@ func (c Cx1Client) AuditCreateSessionByID(engine, projectId, scanId string) (Aud
 
 	session.ProjectID = projectId
 	session.ApplicationID = appId
+	session.ScanID = scanId
 	session.CreatedAt = time.Now()
 	session.LastHeartbeat = time.Now()
 
@@ -253,6 +258,8 @@ func (c Cx1Client) AuditRequestStatusPollingByIDWithTimeout(auditSession *AuditS
 		}
 
 		if status {
+			// query changes are applied asynchronously, so cached queries/presets read while the request ran may be stale
+			c.dropCacheEntries(cacheQueryPrefixes...)
 			break
 		}
 
@@ -269,14 +276,18 @@ func (c Cx1Client) AuditSessionKeepAlive(auditSession *AuditSession) error {
 		c.logger.Tracef("Audit session last refreshed within 5 minutes ago, skipping")
 		return nil
 	}
-	_, err := c.sendRequest(http.MethodPatch, fmt.Sprintf("/query-editor/sessions/%v", auditSession.ID), nil, nil)
-	if err != nil {
+	if err := c.auditSessionHeartbeat(auditSession.ID); err != nil {
 		return err
 	}
 	auditSession.LastHeartbeat = time.Now()
 	return nil
 }
 
+func (c Cx1Client) auditSessionHeartbeat(sessionId string) error {
+	_, err := c.sendRequest(http.MethodPatch, fmt.Sprintf("/query-editor/sessions/%v", sessionId), nil, nil)
+	return err
+}
+
 // Convenience function
 func (c Cx1Client) GetAuditSessionByID(engine, projectId, scanId string) (AuditSession, error) {
 	// TODO: convert the audit session to an object that also does the polling/keepalive
@@ -1144,8 +1155,7 @@ func (c Cx1Client) ValidateSASTQuerySource(auditSession *AuditSession, query *SA
 
 /*
 The data returned by the query-editor api does not include the query ID, so it will be 0. Use "RunQuery" wrapper instead to address that.
-This will run the query, but Cx1ClientGo does not currently support retrieving the results - this function is a temporary substitute for
-ValidateQuerySource which does not return compilation errors (as of Cx1 version 3.25)
+This will run the query and return any compilation errors, the results of the run can then be retrieved with GetAuditSASTQueryResultsByKey
 */
 func (c Cx1Client) RunQueryByKey(auditSession *AuditSession, queryKey, source string) (QueryFailure, error) {
 	c.logger.Debugf("Running query by key: %v", queryKey)
@@ -1213,6 +1223,22 @@ func (c Cx1Client) RunSASTQuery(auditSession *AuditSession, query *SASTQuery, so
 	return c.RunQueryByKey(auditSession, query.EditorKey, source)
 }
 
+/*
+Returns the results of the last run of the query in this audit session (via RunQueryByKey/RunSASTQuery)
+*/
+func (c Cx1Client) GetAuditSASTQueryResultsByKey(auditSession *AuditSession, queryKey string) ([]AuditQueryResult, error) {
+	c.logger.Debugf("Get results for query %v under %v", ShortenGUID(queryKey), auditSession.String())
+	var results []AuditQueryResult
+
+	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/query-editor/sessions/%v/queries/%v/results", auditSession.ID, url.QueryEscape(queryKey)), nil, nil)
+	if err != nil {
+		return results, err
+	}
+
+	err = json.Unmarshal(response, &results)
+	return results, err
+}
+
 // This function will fill the metadata (severity etc) for all queries in the
 func (c Cx1Client) GetIACCollectionAuditMetadata(auditSession *AuditSession, collection *IACQueryCollection, customOnly bool) error {
 	for pid := range collection.Platforms {
@@ -1231,6 +1257,28 @@ func (c Cx1Client) GetIACCollectionAuditMetadata(auditSession *AuditSession, col
 	return nil
 }
 
+// This function will fill the source and metadata (executable, description etc) for all queries in the collection
+func (c Cx1Client) GetSASTCollectionAuditMetadata(auditSession *AuditSession, collection *SASTQueryCollection, customOnly bool) error {
+	for lid := range collection.QueryLanguages {
+		for gid := range collection.QueryLanguages[lid].QueryGroups {
+			for qid, query := range collection.QueryLanguages[lid].QueryGroups[gid].Queries {
+				if query.Custom || !customOnly {
+					if query.EditorKey == "" {
+						query.CalculateEditorKey()
+					}
+					q, err := c.GetAuditSASTQueryByKey(auditSession, query.EditorKey)
+					if err != nil {
+						return err
+					}
+					q.MergeQuery(query)
+					collection.QueryLanguages[lid].QueryGroups[gid].Queries[qid] = q
+				}
+			}
+		}
+	}
+	return nil
+}
+
 //misc functions
 
 func (q AuditSASTQuery) ToSASTQuery() SASTQuery {
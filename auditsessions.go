@@ -0,0 +1,325 @@
+package Cx1ClientGo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+	The AuditSessionManager keeps a pool of web-audit sessions so that multiple query edits can reuse a warm session
+	instead of allocating a new one each time. Sessions are kept alive with a background heartbeat, idle sessions are
+	removed after ClientVars.AuditSessionIdleMaxSeconds, and all sessions are deleted when the manager is closed or
+	the context passed to NewAuditSessionManager is cancelled.
+
+	Sessions are checked out with Acquire and must be returned with Release (or use WithSession).
+	While a session is checked out the manager keeps sending heartbeats, so callers do not need AuditSessionKeepAlive.
+*/
+
+type pooledAuditSession struct {
+	session       AuditSession
+	inUse         bool
+	lastUsed      time.Time
+	lastHeartbeat time.Time
+}
+
+// Creates a new manager holding at most maxSessions audit sessions at any time (0 = no limit)
+// The manager stops and deletes its sessions when ctx is cancelled or Close is called.
+func NewAuditSessionManager(ctx context.Context, client *Cx1Client, maxSessions int) *AuditSessionManager {
+	mctx, cancel := context.WithCancel(ctx)
+	m := &AuditSessionManager{
+		client:      client,
+		maxSessions: maxSessions,
+		changed:     make(chan struct{}),
+		ctx:         mctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+
+	go m.run()
+	return m
+}
+
+// Returns an audit session for the engine (sast or iac) and project, reusing an idle session if one is available.
+// If scanId is empty any session for the project can be reused, otherwise only a session on that scan.
+// If the maximum number of sessions is reached, an idle session for another project is deleted, or if none
+// are idle this call blocks until a session is released or ctx is cancelled.
+func (m *AuditSessionManager) Acquire(ctx context.Context, engine, projectId, scanId string) (*AuditSession, error) {
+	engine = strings.ToLower(engine)
+	for {
+		m.mutex.Lock()
+		if m.ctx.Err() != nil {
+			m.mutex.Unlock()
+			return nil, fmt.Errorf("audit session manager is closed")
+		}
+
+		if ps := m.findIdle(engine, projectId, scanId); ps != nil {
+			ps.inUse = true
+			ps.lastUsed = time.Now()
+			m.mutex.Unlock()
+			m.client.logger.Debugf("Reusing %v", ps.session.String())
+			return &ps.session, nil
+		}
+
+		if m.maxSessions == 0 || len(m.sessions)+m.pending < m.maxSessions {
+			m.pending++
+			m.mutex.Unlock()
+			return m.create(engine, projectId, scanId)
+		}
+
+		if evicted := m.evictIdle(); evicted != nil {
+			// the evicted session's slot is reserved for the new session so that other callers cannot take it
+			// while the delete is in progress, the network calls run without holding the mutex
+			m.pending++
+			m.mutex.Unlock()
+			m.client.logger.Debugf("Session limit %d reached, removing idle %v", m.maxSessions, evicted.session.String())
+			m.deleteSession(&evicted.session)
+			return m.create(engine, projectId, scanId)
+		}
+
+		changed := m.changed
+		m.mutex.Unlock()
+
+		m.client.logger.Debugf("Session limit %d reached and all sessions are in use, waiting", m.maxSessions)
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-m.ctx.Done():
+			return nil, fmt.Errorf("audit session manager is closed")
+		}
+	}
+}
+
+// Returns a session to the pool so that it can be reused
+func (m *AuditSessionManager) Release(session *AuditSession) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, ps := range m.sessions {
+		if &ps.session == session {
+			ps.inUse = false
+			ps.lastUsed = time.Now()
+			m.notify()
+			return
+		}
+	}
+	m.client.logger.Warnf("Attempt to release %v which is not managed by this audit session manager", session.String())
+}
+
+// Removes a session from the pool and deletes it, eg: if it is no longer usable
+func (m *AuditSessionManager) Discard(session *AuditSession) error {
+	m.mutex.Lock()
+	for id, ps := range m.sessions {
+		if &ps.session == session {
+			m.sessions = append(m.sessions[:id], m.sessions[id+1:]...)
+			m.notify()
+			break
+		}
+	}
+	m.mutex.Unlock()
+	return m.client.AuditDeleteSession(session)
+}
+
+// Convenience function: acquires a session, runs the function, and releases the session again.
+// If the function returns an error the session is discarded rather than reused.
+func (m *AuditSessionManager) WithSession(ctx context.Context, engine, projectId, scanId string, f func(*AuditSession) error) error {
+	session, err := m.Acquire(ctx, engine, projectId, scanId)
+	if err != nil {
+		return err
+	}
+
+	if err = f(session); err != nil {
+		if derr := m.Discard(session); derr != nil {
+			m.client.logger.Warnf("Failed to delete %v: %s", session.String(), derr)
+		}
+		return err
+	}
+
+	m.Release(session)
+	return nil
+}
+
+// Returns the number of sessions currently in the pool and how many of those are in use
+func (m *AuditSessionManager) Count() (total, inUse int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, ps := range m.sessions {
+		if ps.inUse {
+			inUse++
+		}
+	}
+	return len(m.sessions), inUse
+}
+
+// Stops the background heartbeat and deletes all sessions, including those still checked out
+func (m *AuditSessionManager) Close() {
+	m.cancel()
+	<-m.done
+}
+
+func (m *AuditSessionManager) String() string {
+	total, inUse := m.Count()
+	return fmt.Sprintf("Audit session manager: %d sessions (%d in use), limit %d", total, inUse, m.maxSessions)
+}
+
+// creates a session in a slot reserved by the caller (m.pending), the mutex is only held to update the pool
+func (m *AuditSessionManager) create(engine, projectId, scanId string) (*AuditSession, error) {
+	session, err := m.client.GetAuditSessionByID(engine, projectId, scanId)
+
+	m.mutex.Lock()
+	m.pending--
+	if err != nil {
+		m.notify()
+		m.mutex.Unlock()
+		if session.ID != "" {
+			if derr := m.client.AuditDeleteSession(&session); derr != nil {
+				m.client.logger.Warnf("Failed to delete partially-created %v: %s", session.String(), derr)
+			}
+		}
+		return nil, err
+	}
+
+	if m.ctx.Err() != nil { // closed while the session was being created
+		m.mutex.Unlock()
+		m.deleteSession(&session)
+		return nil, fmt.Errorf("audit session manager is closed")
+	}
+
+	ps := &pooledAuditSession{
+		session:       session,
+		inUse:         true,
+		lastUsed:      time.Now(),
+		lastHeartbeat: session.LastHeartbeat,
+	}
+	m.sessions = append(m.sessions, ps)
+	m.mutex.Unlock()
+	return &ps.session, nil
+}
+
+func (m *AuditSessionManager) findIdle(engine, projectId, scanId string) *pooledAuditSession {
+	for _, ps := range m.sessions {
+		if !ps.inUse && ps.session.Engine == engine && ps.session.ProjectID == projectId && (scanId == "" || ps.session.ScanID == scanId) {
+			return ps
+		}
+	}
+	return nil
+}
+
+// removes the least-recently used idle session from the pool and returns it
+func (m *AuditSessionManager) evictIdle() *pooledAuditSession {
+	index := -1
+	for id, ps := range m.sessions {
+		if !ps.inUse && (index == -1 || ps.lastUsed.Before(m.sessions[index].lastUsed)) {
+			index = id
+		}
+	}
+	if index == -1 {
+		return nil
+	}
+	ps := m.sessions[index]
+	m.sessions = append(m.sessions[:index], m.sessions[index+1:]...)
+	return ps
+}
+
+// wakes up any Acquire calls waiting for a free session, must hold the mutex
+func (m *AuditSessionManager) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *AuditSessionManager) deleteSession(session *AuditSession) {
+	if err := m.client.AuditDeleteSession(session); err != nil {
+		m.client.logger.Warnf("Failed to delete %v: %s", session.String(), err)
+	}
+}
+
+func (m *AuditSessionManager) run() {
+	defer close(m.done)
+
+	interval := time.Duration(m.client.consts.AuditSessionHeartbeatSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			m.shutdown()
+			return
+		case <-ticker.C:
+			m.heartbeat(interval)
+		}
+	}
+}
+
+func (m *AuditSessionManager) heartbeat(interval time.Duration) {
+	idleMax := time.Duration(m.client.consts.AuditSessionIdleMaxSeconds) * time.Second
+
+	m.mutex.Lock()
+	var expired, alive []*pooledAuditSession
+	for _, ps := range m.sessions {
+		if !ps.inUse && idleMax > 0 && time.Since(ps.lastUsed) > idleMax {
+			expired = append(expired, ps)
+		} else if time.Since(ps.lastHeartbeat) >= interval-interval/10 {
+			// the 10% slack keeps a session last beaten just after the previous tick from waiting a second interval
+			alive = append(alive, ps)
+		}
+	}
+	for _, ps := range expired {
+		m.remove(ps)
+	}
+	sessionIds := make([]string, len(alive))
+	for id, ps := range alive {
+		sessionIds[id] = ps.session.ID
+	}
+	m.mutex.Unlock()
+
+	for _, ps := range expired {
+		m.client.logger.Debugf("Removing %v after being idle for more than %v", ps.session.String(), idleMax)
+		m.deleteSession(&ps.session)
+	}
+
+	for id, ps := range alive {
+		err := m.client.auditSessionHeartbeat(sessionIds[id])
+
+		m.mutex.Lock()
+		if err == nil {
+			ps.lastHeartbeat = time.Now()
+		} else if !ps.inUse {
+			m.client.logger.Warnf("Heartbeat for idle audit session %v failed, removing from pool: %s", sessionIds[id], err)
+			m.remove(ps)
+		} else {
+			m.client.logger.Warnf("Heartbeat for in-use audit session %v failed: %s", sessionIds[id], err)
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// removes a session from the pool without deleting it, must hold the mutex
+func (m *AuditSessionManager) remove(session *pooledAuditSession) {
+	for id, ps := range m.sessions {
+		if ps == session {
+			m.sessions = append(m.sessions[:id], m.sessions[id+1:]...)
+			m.notify()
+			return
+		}
+	}
+}
+
+func (m *AuditSessionManager) shutdown() {
+	m.mutex.Lock()
+	sessions := m.sessions
+	m.sessions = []*pooledAuditSession{}
+	m.notify()
+	m.mutex.Unlock()
+
+	for _, ps := range sessions {
+		if ps.inUse {
+			m.client.logger.Warnf("Audit session manager closing while %v is still in use", ps.session.String())
+		}
+		m.deleteSession(&ps.session)
+	}
+}
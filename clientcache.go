@@ -0,0 +1,254 @@
+package Cx1ClientGo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Opt-in read-through cache for the Cx1Client.
+	When enabled via EnableCache, selected GET calls (projects, groups, roles, presets, users and the query collections)
+	are served from a shared in-memory cache. Entries expire after the configured TTL and are then revalidated
+	using the ETag returned by the server (If-None-Match) where available.
+	Any create/update/delete call sent by the same client invalidates the related cache entries automatically.
+
+	This is separate from the Cx1Cache struct in cx1cache.go, which is a manually-refreshed snapshot of the tenant.
+*/
+
+type clientCache struct {
+	mutex       sync.Mutex
+	ttl         time.Duration
+	entries     map[string]clientCacheEntry
+	generation  uint64            // incremented on every invalidation
+	invalidated map[string]uint64 // key prefix -> generation at which it was last invalidated
+	stats       ClientCacheStats
+}
+
+type clientCacheEntry struct {
+	data    []byte
+	etag    string
+	fetched time.Time
+}
+
+func newClientCache(ttl time.Duration) *clientCache {
+	return &clientCache{
+		ttl:         ttl,
+		entries:     make(map[string]clientCacheEntry),
+		invalidated: make(map[string]uint64),
+	}
+}
+
+// Enables the read-through cache with entries valid for the ttl duration.
+// Calling this again will reset the cache with the new ttl.
+func (c *Cx1Client) EnableCache(ttl time.Duration) {
+	c.logger.Debugf("Enabling Cx1Client cache with TTL %v", ttl)
+	c.cache = newClientCache(ttl)
+}
+
+func (c *Cx1Client) DisableCache() {
+	c.logger.Debugf("Disabling Cx1Client cache")
+	c.cache = nil
+}
+
+func (c Cx1Client) IsCacheEnabled() bool {
+	return c.cache != nil
+}
+
+// Removes all entries from the cache, the cache remains enabled
+func (c Cx1Client) ClearCache() {
+	if c.cache == nil {
+		return
+	}
+	c.cache.mutex.Lock()
+	defer c.cache.mutex.Unlock()
+	c.cache.entries = make(map[string]clientCacheEntry)
+	c.cache.generation++
+	c.cache.invalidated[""] = c.cache.generation // the empty prefix matches every key
+}
+
+func (c Cx1Client) GetCacheStats() ClientCacheStats {
+	if c.cache == nil {
+		return ClientCacheStats{}
+	}
+	c.cache.mutex.Lock()
+	defer c.cache.mutex.Unlock()
+	stats := c.cache.stats
+	stats.Entries = uint64(len(c.cache.entries))
+	return stats
+}
+
+func (s ClientCacheStats) String() string {
+	return fmt.Sprintf("%d entries, %d hits, %d misses, %d revalidated, %d invalidated", s.Entries, s.Hits, s.Misses, s.Revalidated, s.Invalidated)
+}
+
+// sendCachedRequest & sendCachedRequestIAM behave like sendRequest & sendRequestIAM for GET calls
+// but will use the cache if it is enabled.
+func (c Cx1Client) sendCachedRequest(url string) ([]byte, error) {
+	return c.sendCachedRequestInternal(c.baseUrl + "/api" + url)
+}
+
+func (c Cx1Client) sendCachedRequestIAM(base, url string) ([]byte, error) {
+	return c.sendCachedRequestInternal(c.iamUrl + base + "/realms/" + c.tenant + url)
+}
+
+func (c Cx1Client) sendCachedRequestInternal(fullurl string) ([]byte, error) {
+	if c.cache == nil {
+		return c.sendRequestInternal(http.MethodGet, fullurl, nil, nil)
+	}
+
+	key := c.cacheKey(fullurl)
+	entry, ok := c.cache.get(key)
+	if ok && time.Since(entry.fetched) < c.cache.ttl {
+		c.logger.Tracef("Cache hit for %v", key)
+		c.cache.count(func(s *ClientCacheStats) { s.Hits++ })
+		return entry.data, nil
+	}
+
+	// a change sent while this request is in flight invalidates the key before the response is stored
+	generation := c.cache.currentGeneration()
+
+	header := http.Header{}
+	if ok && entry.etag != "" {
+		header.Set("If-None-Match", entry.etag)
+	}
+
+	response, err := c.sendRequestRaw(http.MethodGet, fullurl, nil, header)
+	var resBody []byte
+	if response != nil && response.Body != nil {
+		resBody, _ = io.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	if err != nil {
+		return resBody, err
+	}
+
+	if ok && response.StatusCode == http.StatusNotModified {
+		c.logger.Tracef("Cache entry for %v revalidated", key)
+		c.cache.set(key, entry.data, entry.etag, generation)
+		c.cache.count(func(s *ClientCacheStats) { s.Revalidated++ })
+		return entry.data, nil
+	}
+
+	if !c.cache.set(key, resBody, response.Header.Get("ETag"), generation) {
+		c.logger.Tracef("Not caching %v, it was invalidated while the request was in flight", key)
+	}
+	c.cache.count(func(s *ClientCacheStats) { s.Misses++ })
+	return resBody, nil
+}
+
+// cache keys are the url path+query relative to the cx1 or iam base url.
+// IAM keys have the form /iam/<path-after-realm> eg: /iam/groups/<id>
+func (c Cx1Client) cacheKey(fullurl string) string {
+	realm := "/realms/" + c.tenant
+	if i := strings.Index(fullurl, realm); i >= 0 {
+		return "/iam" + fullurl[i+len(realm):]
+	}
+	return strings.TrimPrefix(fullurl, c.baseUrl)
+}
+
+// called for every non-GET request sent by the client
+func (c Cx1Client) invalidateCache(method, fullurl string) {
+	if c.cache == nil || method == http.MethodGet || method == http.MethodHead {
+		return
+	}
+
+	if prefixes := cacheInvalidationPrefixes(c.cacheKey(fullurl)); len(prefixes) > 0 {
+		c.logger.Tracef("Invalidating cache entries affected by %v %v", method, fullurl)
+		c.dropCacheEntries(prefixes...)
+	}
+}
+
+// removes all cache entries with keys starting with any of the prefixes
+func (c Cx1Client) dropCacheEntries(prefixes ...string) {
+	if c.cache == nil || len(prefixes) == 0 {
+		return
+	}
+
+	c.cache.mutex.Lock()
+	defer c.cache.mutex.Unlock()
+	c.cache.generation++
+	for _, prefix := range prefixes {
+		c.cache.invalidated[prefix] = c.cache.generation
+	}
+	for key := range c.cache.entries {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(c.cache.entries, key)
+				c.cache.stats.Invalidated++
+				break
+			}
+		}
+	}
+}
+
+// cache key prefixes affected by query changes, also invalidated when an asynchronous audit request completes
+var cacheQueryPrefixes = []string{"/api/cx-audit", "/api/preset-manager", "/api/presets", "/api/queries"}
+
+// returns the list of cache key prefixes that are affected by a change to the resource at key
+func cacheInvalidationPrefixes(key string) []string {
+	switch {
+	case strings.HasPrefix(key, "/api/projects"):
+		return []string{"/api/projects", "/api/configuration/project"}
+	case strings.HasPrefix(key, "/api/configuration/project"):
+		return []string{"/api/configuration/project"}
+	case strings.HasPrefix(key, "/api/applications"):
+		// application membership is reflected in the project's applicationIds
+		return []string{"/api/applications", "/api/projects"}
+	case strings.HasPrefix(key, "/api/preset-manager"), strings.HasPrefix(key, "/api/presets"):
+		return []string{"/api/preset-manager", "/api/presets"}
+	case strings.HasPrefix(key, "/api/cx-audit/queries"):
+		return cacheQueryPrefixes
+	case strings.HasPrefix(key, "/api/query-editor/sessions/"):
+		if strings.Contains(key, "/queries") && !strings.HasSuffix(key, "/queries/validate") && !strings.HasSuffix(key, "/queries/run") {
+			return cacheQueryPrefixes
+		}
+	case strings.HasPrefix(key, "/iam/groups"):
+		// group changes may affect parents, children and members
+		return []string{"/iam/groups", "/iam/users"}
+	case strings.HasPrefix(key, "/iam/users"):
+		return []string{"/iam/users", "/iam/groups"}
+	case strings.HasPrefix(key, "/iam/roles"), strings.HasPrefix(key, "/iam/clients"): // includes /roles-by-id
+		return []string{"/iam/roles", "/iam/clients"}
+	}
+	return []string{}
+}
+
+func (cc *clientCache) get(key string) (clientCacheEntry, bool) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	entry, ok := cc.entries[key]
+	return entry, ok
+}
+
+func (cc *clientCache) currentGeneration() uint64 {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	return cc.generation
+}
+
+// stores the entry unless the key was invalidated after generation, returns false if it was not stored
+func (cc *clientCache) set(key string, data []byte, etag string, generation uint64) bool {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	for prefix, invalidated := range cc.invalidated {
+		if invalidated > generation && strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	cc.entries[key] = clientCacheEntry{
+		data:    data,
+		etag:    etag,
+		fetched: time.Now(),
+	}
+	return true
+}
+
+func (cc *clientCache) count(update func(*ClientCacheStats)) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	update(&cc.stats)
+}
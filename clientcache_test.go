@@ -0,0 +1,261 @@
+package Cx1ClientGo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// counts GET requests per path, returns the path as the body and supports If-None-Match against a fixed ETag
+type testCacheServer struct {
+	mutex       sync.Mutex
+	gets        map[string]int
+	etag        string
+	onGet       func(path string) // called before responding to a GET
+	notModified int
+}
+
+func (s *testCacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.mutex.Lock()
+	s.gets[r.URL.Path]++
+	onGet := s.onGet
+	s.mutex.Unlock()
+
+	if onGet != nil {
+		onGet(r.URL.Path)
+	}
+
+	if s.etag != "" {
+		if r.Header.Get("If-None-Match") == s.etag {
+			s.mutex.Lock()
+			s.notModified++
+			s.mutex.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+	}
+	w.Write([]byte(r.URL.Path))
+}
+
+func (s *testCacheServer) count(path string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.gets[path]
+}
+
+// returns a client with a valid access token and the cache enabled, talking to the test server
+func newTestCacheClient(t *testing.T, ttl time.Duration) (Cx1Client, *testCacheServer) {
+	handler := &testCacheServer{gets: make(map[string]int)}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := Cx1Client{
+		httpClient: server.Client(),
+		baseUrl:    server.URL,
+		iamUrl:     server.URL,
+		tenant:     "test",
+		logger:     testLogger{t},
+		auth:       Cx1ClientAuth{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+	}
+	c.EnableCache(ttl)
+	return c, handler
+}
+
+func TestClientCacheWriteInvalidates(t *testing.T) {
+	c, server := newTestCacheClient(t, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.sendCachedRequest("/projects/1"); err != nil {
+			t.Fatalf("GET failed: %s", err)
+		}
+	}
+	if n := server.count("/api/projects/1"); n != 1 {
+		t.Fatalf("expected 1 request to the server before the update, got %d", n)
+	}
+
+	if _, err := c.sendRequest(http.MethodPut, "/projects/1", nil, http.Header{}); err != nil {
+		t.Fatalf("PUT failed: %s", err)
+	}
+	if _, err := c.sendCachedRequest("/projects/1"); err != nil {
+		t.Fatalf("GET failed: %s", err)
+	}
+	if n := server.count("/api/projects/1"); n != 2 {
+		t.Errorf("expected the PUT to invalidate the cached GET, got %d requests", n)
+	}
+
+	stats := c.GetCacheStats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Invalidated != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestClientCacheUnrelatedWriteKeepsEntry(t *testing.T) {
+	c, server := newTestCacheClient(t, time.Hour)
+
+	c.sendCachedRequest("/presets/1")
+	if _, err := c.sendRequest(http.MethodPut, "/projects/1", nil, http.Header{}); err != nil {
+		t.Fatalf("PUT failed: %s", err)
+	}
+	c.sendCachedRequest("/presets/1")
+	if n := server.count("/api/presets/1"); n != 1 {
+		t.Errorf("expected the preset to stay cached after a project update, got %d requests", n)
+	}
+}
+
+func TestClientCacheInFlightInvalidation(t *testing.T) {
+	c, server := newTestCacheClient(t, time.Hour)
+
+	// a project update is sent while the first GET is still in flight
+	var once sync.Once
+	server.onGet = func(path string) {
+		once.Do(func() {
+			if _, err := c.sendRequest(http.MethodPatch, "/projects/1", nil, http.Header{}); err != nil {
+				t.Errorf("PATCH failed: %s", err)
+			}
+		})
+	}
+
+	if _, err := c.sendCachedRequest("/projects/1"); err != nil {
+		t.Fatalf("GET failed: %s", err)
+	}
+	if stats := c.GetCacheStats(); stats.Entries != 0 {
+		t.Errorf("expected the in-flight response not to be cached, got %d entries", stats.Entries)
+	}
+
+	c.sendCachedRequest("/projects/1")
+	c.sendCachedRequest("/projects/1")
+	if n := server.count("/api/projects/1"); n != 2 {
+		t.Errorf("expected the second GET to repopulate the cache, got %d requests", n)
+	}
+}
+
+func TestClientCacheSetGeneration(t *testing.T) {
+	c, _ := newTestCacheClient(t, time.Hour)
+
+	generation := c.cache.currentGeneration()
+	c.dropCacheEntries("/api/projects")
+
+	if c.cache.set("/api/projects/1", []byte("stale"), "", generation) {
+		t.Errorf("expected a set from before the invalidation to be rejected")
+	}
+	if !c.cache.set("/api/presets/1", []byte("ok"), "", generation) {
+		t.Errorf("expected a set for an unaffected key to be stored")
+	}
+	if !c.cache.set("/api/projects/1", []byte("fresh"), "", c.cache.currentGeneration()) {
+		t.Errorf("expected a set from after the invalidation to be stored")
+	}
+
+	generation = c.cache.currentGeneration()
+	c.ClearCache()
+	if c.cache.set("/api/presets/1", []byte("stale"), "", generation) {
+		t.Errorf("expected a set from before ClearCache to be rejected")
+	}
+}
+
+func TestClientCacheTTLExpiry(t *testing.T) {
+	c, server := newTestCacheClient(t, time.Hour)
+
+	c.sendCachedRequest("/projects/1")
+	c.cache.mutex.Lock()
+	entry := c.cache.entries["/api/projects/1"]
+	entry.fetched = time.Now().Add(-2 * time.Hour)
+	c.cache.entries["/api/projects/1"] = entry
+	c.cache.mutex.Unlock()
+
+	data, err := c.sendCachedRequest("/projects/1")
+	if err != nil {
+		t.Fatalf("GET failed: %s", err)
+	}
+	if string(data) != "/api/projects/1" {
+		t.Errorf("unexpected response %q", string(data))
+	}
+	if n := server.count("/api/projects/1"); n != 2 {
+		t.Errorf("expected the expired entry to be fetched again, got %d requests", n)
+	}
+	if stats := c.GetCacheStats(); stats.Revalidated != 0 || stats.Misses != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestClientCacheETagRevalidation(t *testing.T) {
+	c, server := newTestCacheClient(t, time.Millisecond)
+	server.etag = `"v1"`
+
+	first, err := c.sendCachedRequest("/presets")
+	if err != nil {
+		t.Fatalf("GET failed: %s", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	second, err := c.sendCachedRequest("/presets")
+	if err != nil {
+		t.Fatalf("GET failed: %s", err)
+	}
+	if string(second) != string(first) {
+		t.Errorf("expected the revalidated entry %q, got %q", string(first), string(second))
+	}
+	if server.notModified != 1 {
+		t.Errorf("expected the expired request to send If-None-Match, got %d not modified responses", server.notModified)
+	}
+	if stats := c.GetCacheStats(); stats.Revalidated != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestClientCacheKey(t *testing.T) {
+	c := Cx1Client{baseUrl: "https://cx1", iamUrl: "https://iam", tenant: "test"}
+
+	tests := []struct {
+		url, key string
+	}{
+		{"https://cx1/api/projects/1?x=y", "/api/projects/1?x=y"},
+		{"https://iam/auth/admin/realms/test/groups/abc", "/iam/groups/abc"},
+		{"https://iam/auth/realms/test/users", "/iam/users"},
+	}
+
+	for _, test := range tests {
+		if key := c.cacheKey(test.url); key != test.key {
+			t.Errorf("cacheKey(%v) = %v, expected %v", test.url, key, test.key)
+		}
+	}
+}
+
+func TestCacheInvalidationPrefixes(t *testing.T) {
+	tests := []struct {
+		key      string
+		prefixes []string
+	}{
+		{"/api/projects/1", []string{"/api/projects", "/api/configuration/project"}},
+		{"/api/configuration/project?project-id=1", []string{"/api/configuration/project"}},
+		{"/api/applications/1/projects", []string{"/api/applications", "/api/projects"}},
+		{"/api/presets/1", []string{"/api/preset-manager", "/api/presets"}},
+		{"/api/preset-manager/presets/1", []string{"/api/preset-manager", "/api/presets"}},
+		{"/api/cx-audit/queries/1", cacheQueryPrefixes},
+		{"/api/query-editor/sessions/1/queries", cacheQueryPrefixes},
+		{"/api/query-editor/sessions/1/queries/abc", cacheQueryPrefixes},
+		{"/api/query-editor/sessions/1/queries/validate", []string{}},
+		{"/api/query-editor/sessions/1/queries/run", []string{}},
+		{"/api/query-editor/sessions/1", []string{}},
+		{"/iam/groups/abc/children", []string{"/iam/groups", "/iam/users"}},
+		{"/iam/users/abc/groups/def", []string{"/iam/users", "/iam/groups"}},
+		{"/iam/roles-by-id/abc", []string{"/iam/roles", "/iam/clients"}},
+		{"/iam/clients/abc/roles", []string{"/iam/roles", "/iam/clients"}},
+		{"/api/scans", []string{}},
+	}
+
+	for _, test := range tests {
+		if prefixes := cacheInvalidationPrefixes(test.key); !slices.Equal(prefixes, test.prefixes) {
+			t.Errorf("cacheInvalidationPrefixes(%v) = %v, expected %v", test.key, prefixes, test.prefixes)
+		}
+	}
+}
@@ -418,12 +418,24 @@ func (c *Cx1Client) GetCurrentUsername() string {
 	return c.claims.Username
 }
 
+// returns the username, or the OIDC client ID when authenticated as a client
+func (c Cx1Client) currentPrincipal() string {
+	if c.IsUser {
+		return c.claims.Username
+	}
+	return c.claims.ClientID
+}
+
 func (c *Cx1Client) SetLogger(logger Logger) {
 	c.logger = logger
 }
 
 // returns a copy of this client which can be used separately
 // they will not share access tokens or other data after the clone.
+// if caching is enabled, the clone starts with an empty cache using the same TTL.
 func (c Cx1Client) Clone() Cx1Client {
+	if c.cache != nil {
+		c.cache = newClientCache(c.cache.ttl)
+	}
 	return c
 }
@@ -251,7 +251,7 @@ func (c Cx1Client) GetGroupByID(groupID string) (Group, error) {
 		"briefRepresentation": {"true"},
 	}
 
-	data, err := c.sendRequestIAM(http.MethodGet, "/auth/admin", fmt.Sprintf("/groups/%v?%v", groupID, body.Encode()), nil, http.Header{})
+	data, err := c.sendCachedRequestIAM("/auth/admin", fmt.Sprintf("/groups/%v?%v", groupID, body.Encode()))
 	if err != nil {
 		c.logger.Tracef("Fetching group %v failed: %s", groupID, err)
 		return group, err
@@ -319,7 +319,7 @@ func (c Cx1Client) FillGroup(group *Group) error {
 // Used by GetGroupChildren
 func (c Cx1Client) GetGroupChildrenByID(groupID string, first, max uint64) ([]Group, error) {
 	var groups []Group
-	data, err := c.sendRequestIAM(http.MethodGet, "/auth/admin", fmt.Sprintf("/groups/%v/children?briefRepresentation=false&first=%d&max=%d", groupID, first, max), nil, http.Header{})
+	data, err := c.sendCachedRequestIAM("/auth/admin", fmt.Sprintf("/groups/%v/children?briefRepresentation=false&first=%d&max=%d", groupID, first, max))
 	if err != nil {
 		c.logger.Tracef("Fetching group %v children failed: %s", groupID, err)
 		return groups, err
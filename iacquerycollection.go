@@ -194,7 +194,7 @@ func (qc *IACQueryCollection) AddQuery(q IACQuery) {
 		if qgq != nil {
 			q.Key = qgq.Key
 		} else {
-			if q.QueryID[1] == ':' {
+			if len(q.QueryID) > 1 && q.QueryID[1] == ':' {
 				q.Key = q.QueryID[2:]
 			} else {
 				q.Key = q.QueryID
@@ -447,6 +447,48 @@ func (qc IACQueryCollection) Print(logger Logger) {
 	}
 }
 
+func (qc IACQueryCollection) GetQueries() []IACQuery {
+	queries := []IACQuery{}
+
+	for pid := range qc.Platforms {
+		for gid := range qc.Platforms[pid].QueryGroups {
+			queries = append(queries, qc.Platforms[pid].QueryGroups[gid].Queries...)
+		}
+	}
+
+	return queries
+}
+
+func (qc IACQueryCollection) GetDiffs(collection *IACQueryCollection) (missing IACQueryCollection, extra IACQueryCollection) {
+	return collection.GetExtraQueries(&qc), qc.GetExtraQueries(collection)
+}
+
+func (qc IACQueryCollection) GetExtraQueries(collection *IACQueryCollection) (extra IACQueryCollection) {
+	for _, platform := range qc.Platforms {
+		for _, group := range platform.QueryGroups {
+			for _, query := range group.Queries {
+				if q := collection.findQuery(query.Level, query.LevelID, query.Name, query.Key); q == nil {
+					extra.AddQuery(query)
+				}
+			}
+		}
+	}
+	return
+}
+
+func (qc IACQueryCollection) IsSubset(collection *IACQueryCollection) bool {
+	for _, platform := range qc.Platforms {
+		for _, group := range platform.QueryGroups {
+			for _, query := range group.Queries {
+				if q := collection.findQuery(query.Level, query.LevelID, query.Name, query.Key); q == nil {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
 /*
 // not in use, will be used later?
 func treeToIACQueries(querytree *[]AuditQueryTree) []IACQuery {
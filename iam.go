@@ -33,10 +33,16 @@ func (c Cx1Client) GetAuthenticationProviderByAlias(alias string) (Authenticatio
 }
 
 func (c Cx1Client) CreateAuthenticationProvider(alias, providerId string) (AuthenticationProvider, error) {
-	idp := AuthenticationProvider{
+	return c.CreateAuthenticationProviderWithConfig(AuthenticationProvider{
 		Alias:      alias,
 		ProviderID: providerId,
-	}
+		Enabled:    true,
+	})
+}
+
+// Creates the provider including the display name and config, use NewSAMLAuthenticationProvider/NewOIDCAuthenticationProvider to build it
+func (c Cx1Client) CreateAuthenticationProviderWithConfig(idp AuthenticationProvider) (AuthenticationProvider, error) {
+	c.logger.Debugf("Creating authentication provider %v", idp.String())
 	jsonBody, _ := json.Marshal(idp)
 
 	_, err := c.sendRequestIAM(http.MethodPost, "/auth/admin", "/identity-provider/instances", bytes.NewReader(jsonBody), nil)
@@ -44,7 +50,15 @@ func (c Cx1Client) CreateAuthenticationProvider(alias, providerId string) (Authe
 		return AuthenticationProvider{}, err
 	}
 
-	return c.GetAuthenticationProviderByAlias(alias)
+	return c.GetAuthenticationProviderByAlias(idp.Alias)
+}
+
+func (c Cx1Client) UpdateAuthenticationProvider(provider AuthenticationProvider) error {
+	c.logger.Debugf("Updating authentication provider %v", provider.String())
+	jsonBody, _ := json.Marshal(provider)
+
+	_, err := c.sendRequestIAM(http.MethodPut, "/auth/admin", fmt.Sprintf("/identity-provider/instances/%v", provider.Alias), bytes.NewReader(jsonBody), nil)
+	return err
 }
 
 func (c Cx1Client) DeleteAuthenticationProvider(provider AuthenticationProvider) error {
@@ -75,6 +89,13 @@ func (c Cx1Client) AddAuthenticationProviderMapper(mapper AuthenticationProvider
 	return err
 }
 
+func (c Cx1Client) UpdateAuthenticationProviderMapper(mapper AuthenticationProviderMapper) error {
+	jsonBody, _ := json.Marshal(mapper)
+
+	_, err := c.sendRequestIAM(http.MethodPut, "/auth/admin", fmt.Sprintf("/identity-provider/instances/%v/mappers/%v", mapper.Alias, mapper.ID), bytes.NewReader(jsonBody), nil)
+	return err
+}
+
 func (c Cx1Client) DeleteAuthenticationProviderMapper(mapper AuthenticationProviderMapper) error {
 	_, err := c.sendRequestIAM(http.MethodDelete, "/auth/admin", fmt.Sprintf("/identity-provider/instances/%v/mappers/%v", mapper.Alias, mapper.ID), nil, nil)
 	return err
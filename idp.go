@@ -0,0 +1,622 @@
+package Cx1ClientGo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Typed configuration for SAML and OIDC identity providers, and templates for the standard set of IdP mappers.
+	A provider can be built from the IdP's SAML metadata XML or OIDC discovery document:
+
+		config, _ := ParseSAMLMetadata(metadataXml)
+		idp, _ := cx1client.CreateAuthenticationProviderWithConfig(NewSAMLAuthenticationProvider("corp-sso", "Corporate SSO", config))
+		template := DefaultAuthenticationProviderMapperTemplate(idp.ProviderID)
+		changes, _ := cx1client.ApplyAuthenticationProviderMappers(idp, template.GetMappers(idp), false)
+
+	Config entries that are not covered by the typed configs are kept in AdditionalConfig so that a read-modify-write
+	does not lose them.
+*/
+
+const (
+	AuthenticationProviderMapperAdd    = "add"
+	AuthenticationProviderMapperUpdate = "update"
+	AuthenticationProviderMapperRemove = "remove"
+)
+
+const (
+	samlBindingPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlBindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	samlNameIDUnspec    = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	oidcDiscoveryPath   = "/.well-known/openid-configuration"
+)
+
+var samlConfigKeys = []string{"idpEntityId", "singleSignOnServiceUrl", "singleLogoutServiceUrl", "signingCertificate", "nameIDPolicyFormat",
+	"principalType", "principalAttribute", "entityId", "postBindingAuthnRequest", "postBindingResponse", "postBindingLogout",
+	"wantAuthnRequestsSigned", "wantAssertionsSigned", "wantAssertionsEncrypted", "validateSignature", "syncMode"}
+
+var oidcConfigKeys = []string{"issuer", "authorizationUrl", "tokenUrl", "userInfoUrl", "logoutUrl", "jwksUrl", "useJwksUrl", "clientId",
+	"clientSecret", "clientAuthMethod", "defaultScope", "validateSignature", "pkceEnabled", "pkceMethod", "syncMode", "disableUserInfo",
+	"backchannelSupported"}
+
+type samlEntityDescriptor struct {
+	XMLName          xml.Name               `xml:"EntityDescriptor"`
+	EntityID         string                 `xml:"entityID,attr"`
+	IDPSSODescriptor *samlIDPSSODescriptor  `xml:"IDPSSODescriptor"`
+	Entities         []samlEntityDescriptor `xml:"EntityDescriptor"` // when wrapped in EntitiesDescriptor
+}
+
+type samlIDPSSODescriptor struct {
+	WantAuthnRequestsSigned string `xml:"WantAuthnRequestsSigned,attr"`
+	KeyDescriptors          []struct {
+		Use          string `xml:"use,attr"`
+		Certificates []struct {
+			Value string `xml:",chardata"`
+		} `xml:"KeyInfo>X509Data>X509Certificate"`
+	} `xml:"KeyDescriptor"`
+	SingleSignOnServices []samlEndpoint `xml:"SingleSignOnService"`
+	SingleLogoutServices []samlEndpoint `xml:"SingleLogoutService"`
+	NameIDFormats        []string       `xml:"NameIDFormat"`
+}
+
+type samlEndpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// Parses the IdP's SAML 2.0 metadata. The POST binding is preferred over redirect when both are offered.
+func ParseSAMLMetadata(metadata []byte) (SAMLProviderConfig, error) {
+	config := SAMLProviderConfig{
+		PostBindingResponse: true,
+		ValidateSignature:   true,
+		PrincipalType:       "SUBJECT",
+		SyncMode:            "FORCE",
+	}
+
+	var doc samlEntityDescriptor
+	if err := xml.Unmarshal(metadata, &doc); err != nil {
+		var wrapper struct {
+			Entities []samlEntityDescriptor `xml:"EntityDescriptor"`
+		}
+		if err2 := xml.Unmarshal(metadata, &wrapper); err2 != nil {
+			return config, fmt.Errorf("failed to parse SAML metadata: %s", err)
+		}
+		doc.Entities = wrapper.Entities
+	}
+
+	entity := &doc
+	if entity.IDPSSODescriptor == nil {
+		entity = nil
+		for id := range doc.Entities {
+			if doc.Entities[id].IDPSSODescriptor != nil {
+				entity = &doc.Entities[id]
+				break
+			}
+		}
+	}
+	if entity == nil {
+		return config, fmt.Errorf("SAML metadata does not contain an IDPSSODescriptor")
+	}
+
+	idp := entity.IDPSSODescriptor
+	config.IdPEntityID = entity.EntityID
+	config.WantAuthnRequestsSigned = strings.EqualFold(idp.WantAuthnRequestsSigned, "true")
+
+	if sso := samlPreferredEndpoint(idp.SingleSignOnServices); sso != nil {
+		config.SingleSignOnServiceURL = sso.Location
+		config.PostBindingAuthnRequest = sso.Binding == samlBindingPOST
+	} else {
+		return config, fmt.Errorf("SAML metadata for %v does not contain a SingleSignOnService", entity.EntityID)
+	}
+	if slo := samlPreferredEndpoint(idp.SingleLogoutServices); slo != nil {
+		config.SingleLogoutServiceURL = slo.Location
+		config.PostBindingLogout = slo.Binding == samlBindingPOST
+	}
+
+	for _, kd := range idp.KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, cert := range kd.Certificates {
+			if c := strings.Join(strings.Fields(cert.Value), ""); c != "" {
+				config.SigningCertificates = append(config.SigningCertificates, c)
+			}
+		}
+	}
+	config.WantAssertionsSigned = len(config.SigningCertificates) > 0
+
+	config.NameIDPolicyFormat = samlNameIDUnspec
+	if len(idp.NameIDFormats) > 0 {
+		config.NameIDPolicyFormat = strings.TrimSpace(idp.NameIDFormats[0])
+	}
+
+	return config, nil
+}
+
+func samlPreferredEndpoint(endpoints []samlEndpoint) *samlEndpoint {
+	var found *samlEndpoint
+	for id := range endpoints {
+		switch endpoints[id].Binding {
+		case samlBindingPOST:
+			return &endpoints[id]
+		case samlBindingRedirect:
+			found = &endpoints[id]
+		}
+	}
+	return found
+}
+
+// Parses an OpenID Connect discovery document (/.well-known/openid-configuration)
+func ParseOIDCDiscoveryDocument(document []byte) (OIDCProviderConfig, error) {
+	var doc struct {
+		Issuer                string   `json:"issuer"`
+		AuthorizationEndpoint string   `json:"authorization_endpoint"`
+		TokenEndpoint         string   `json:"token_endpoint"`
+		UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+		EndSessionEndpoint    string   `json:"end_session_endpoint"`
+		JWKSURI               string   `json:"jwks_uri"`
+		ScopesSupported       []string `json:"scopes_supported"`
+		AuthMethods           []string `json:"token_endpoint_auth_methods_supported"`
+		PKCEMethods           []string `json:"code_challenge_methods_supported"`
+		BackchannelLogout     bool     `json:"backchannel_logout_supported"`
+	}
+	config := OIDCProviderConfig{
+		ValidateSignature: true,
+		SyncMode:          "FORCE",
+		DefaultScope:      "openid email profile",
+		ClientAuthMethod:  "client_secret_post",
+	}
+
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return config, fmt.Errorf("failed to parse OIDC discovery document: %s", err)
+	}
+	if doc.Issuer == "" || doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return config, fmt.Errorf("OIDC discovery document is missing the issuer, authorization_endpoint or token_endpoint")
+	}
+
+	config.Issuer = doc.Issuer
+	config.AuthorizationURL = doc.AuthorizationEndpoint
+	config.TokenURL = doc.TokenEndpoint
+	config.UserInfoURL = doc.UserInfoEndpoint
+	config.LogoutURL = doc.EndSessionEndpoint
+	config.JWKSURL = doc.JWKSURI
+	config.BackchannelSupported = doc.BackchannelLogout
+
+	if len(doc.AuthMethods) > 0 && !slices.Contains(doc.AuthMethods, config.ClientAuthMethod) {
+		config.ClientAuthMethod = doc.AuthMethods[0]
+	}
+	if slices.Contains(doc.PKCEMethods, "S256") {
+		config.PKCEEnabled = true
+		config.PKCEMethod = "S256"
+	}
+	if len(doc.ScopesSupported) > 0 {
+		scopes := []string{}
+		for _, s := range strings.Fields(config.DefaultScope) {
+			if slices.Contains(doc.ScopesSupported, s) {
+				scopes = append(scopes, s)
+			}
+		}
+		config.DefaultScope = strings.Join(scopes, " ")
+	}
+
+	return config, nil
+}
+
+// Fetches and parses the OIDC discovery document. The URL can be the issuer, in which case the well-known path is appended.
+func (c Cx1Client) GetOIDCDiscoveryDocument(discoveryUrl string) (OIDCProviderConfig, error) {
+	if !strings.HasSuffix(discoveryUrl, oidcDiscoveryPath) {
+		discoveryUrl = strings.TrimSuffix(discoveryUrl, "/") + oidcDiscoveryPath
+	}
+	c.logger.Debugf("Fetching OIDC discovery document from %v", discoveryUrl)
+
+	request, err := http.NewRequest(http.MethodGet, discoveryUrl, nil)
+	if err != nil {
+		return OIDCProviderConfig{}, err
+	}
+	request.Header.Set("User-Agent", c.cx1UserAgent)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.handleHTTPResponse(request)
+	if err != nil {
+		return OIDCProviderConfig{}, fmt.Errorf("failed to fetch OIDC discovery document: %s", err)
+	}
+	defer response.Body.Close()
+
+	document, err := io.ReadAll(response.Body)
+	if err != nil {
+		return OIDCProviderConfig{}, err
+	}
+	return ParseOIDCDiscoveryDocument(document)
+}
+
+func NewSAMLAuthenticationProvider(alias, displayName string, config SAMLProviderConfig) AuthenticationProvider {
+	return AuthenticationProvider{
+		Alias:       alias,
+		ProviderID:  "saml",
+		DisplayName: displayName,
+		Enabled:     true,
+		Config:      config.ToConfig(),
+	}
+}
+
+func NewOIDCAuthenticationProvider(alias, displayName string, config OIDCProviderConfig) AuthenticationProvider {
+	return AuthenticationProvider{
+		Alias:       alias,
+		ProviderID:  "oidc",
+		DisplayName: displayName,
+		Enabled:     true,
+		Config:      config.ToConfig(),
+	}
+}
+
+func (p AuthenticationProvider) IsSAML() bool {
+	return p.ProviderID == "saml"
+}
+
+func (p AuthenticationProvider) IsOIDC() bool {
+	return p.ProviderID == "oidc" || p.ProviderID == "keycloak-oidc"
+}
+
+func (p AuthenticationProvider) GetSAMLConfig() (SAMLProviderConfig, error) {
+	if !p.IsSAML() {
+		return SAMLProviderConfig{}, fmt.Errorf("provider %v is not a SAML provider", p.String())
+	}
+	cfg := p.Config
+	config := SAMLProviderConfig{
+		IdPEntityID:             cfg["idpEntityId"],
+		SingleSignOnServiceURL:  cfg["singleSignOnServiceUrl"],
+		SingleLogoutServiceURL:  cfg["singleLogoutServiceUrl"],
+		NameIDPolicyFormat:      cfg["nameIDPolicyFormat"],
+		PrincipalType:           cfg["principalType"],
+		PrincipalAttribute:      cfg["principalAttribute"],
+		SPEntityID:              cfg["entityId"],
+		PostBindingAuthnRequest: configBool(cfg, "postBindingAuthnRequest"),
+		PostBindingResponse:     configBool(cfg, "postBindingResponse"),
+		PostBindingLogout:       configBool(cfg, "postBindingLogout"),
+		WantAuthnRequestsSigned: configBool(cfg, "wantAuthnRequestsSigned"),
+		WantAssertionsSigned:    configBool(cfg, "wantAssertionsSigned"),
+		WantAssertionsEncrypted: configBool(cfg, "wantAssertionsEncrypted"),
+		ValidateSignature:       configBool(cfg, "validateSignature"),
+		SyncMode:                cfg["syncMode"],
+		AdditionalConfig:        additionalConfig(cfg, samlConfigKeys),
+	}
+	for _, cert := range strings.Split(cfg["signingCertificate"], ",") {
+		if cert = strings.TrimSpace(cert); cert != "" {
+			config.SigningCertificates = append(config.SigningCertificates, cert)
+		}
+	}
+	return config, nil
+}
+
+func (p AuthenticationProvider) GetOIDCConfig() (OIDCProviderConfig, error) {
+	if !p.IsOIDC() {
+		return OIDCProviderConfig{}, fmt.Errorf("provider %v is not an OIDC provider", p.String())
+	}
+	cfg := p.Config
+	return OIDCProviderConfig{
+		Issuer:               cfg["issuer"],
+		AuthorizationURL:     cfg["authorizationUrl"],
+		TokenURL:             cfg["tokenUrl"],
+		UserInfoURL:          cfg["userInfoUrl"],
+		LogoutURL:            cfg["logoutUrl"],
+		JWKSURL:              cfg["jwksUrl"],
+		ClientID:             cfg["clientId"],
+		ClientSecret:         cfg["clientSecret"],
+		ClientAuthMethod:     cfg["clientAuthMethod"],
+		DefaultScope:         cfg["defaultScope"],
+		ValidateSignature:    configBool(cfg, "validateSignature"),
+		PKCEEnabled:          configBool(cfg, "pkceEnabled"),
+		PKCEMethod:           cfg["pkceMethod"],
+		SyncMode:             cfg["syncMode"],
+		DisableUserInfo:      configBool(cfg, "disableUserInfo"),
+		BackchannelSupported: configBool(cfg, "backchannelSupported"),
+		AdditionalConfig:     additionalConfig(cfg, oidcConfigKeys),
+	}, nil
+}
+
+// Replaces the provider's config with the typed SAML config
+func (p *AuthenticationProvider) SetSAMLConfig(config SAMLProviderConfig) {
+	p.ProviderID = "saml"
+	p.Config = config.ToConfig()
+}
+
+// Replaces the provider's config with the typed OIDC config
+func (p *AuthenticationProvider) SetOIDCConfig(config OIDCProviderConfig) {
+	if !p.IsOIDC() {
+		p.ProviderID = "oidc"
+	}
+	p.Config = config.ToConfig()
+}
+
+// Returns the keycloak identity provider config map
+func (s SAMLProviderConfig) ToConfig() map[string]string {
+	cfg := make(map[string]string)
+	for k, v := range s.AdditionalConfig {
+		cfg[k] = v
+	}
+	setConfig(cfg, "idpEntityId", s.IdPEntityID)
+	setConfig(cfg, "singleSignOnServiceUrl", s.SingleSignOnServiceURL)
+	setConfig(cfg, "singleLogoutServiceUrl", s.SingleLogoutServiceURL)
+	setConfig(cfg, "signingCertificate", strings.Join(s.SigningCertificates, ","))
+	setConfig(cfg, "nameIDPolicyFormat", s.NameIDPolicyFormat)
+	setConfig(cfg, "principalType", s.PrincipalType)
+	setConfig(cfg, "principalAttribute", s.PrincipalAttribute)
+	setConfig(cfg, "entityId", s.SPEntityID)
+	setConfig(cfg, "syncMode", s.SyncMode)
+	cfg["postBindingAuthnRequest"] = strconv.FormatBool(s.PostBindingAuthnRequest)
+	cfg["postBindingResponse"] = strconv.FormatBool(s.PostBindingResponse)
+	cfg["postBindingLogout"] = strconv.FormatBool(s.PostBindingLogout)
+	cfg["wantAuthnRequestsSigned"] = strconv.FormatBool(s.WantAuthnRequestsSigned)
+	cfg["wantAssertionsSigned"] = strconv.FormatBool(s.WantAssertionsSigned)
+	cfg["wantAssertionsEncrypted"] = strconv.FormatBool(s.WantAssertionsEncrypted)
+	cfg["validateSignature"] = strconv.FormatBool(s.ValidateSignature)
+	return cfg
+}
+
+// Returns the keycloak identity provider config map
+func (o OIDCProviderConfig) ToConfig() map[string]string {
+	cfg := make(map[string]string)
+	for k, v := range o.AdditionalConfig {
+		cfg[k] = v
+	}
+	setConfig(cfg, "issuer", o.Issuer)
+	setConfig(cfg, "authorizationUrl", o.AuthorizationURL)
+	setConfig(cfg, "tokenUrl", o.TokenURL)
+	setConfig(cfg, "userInfoUrl", o.UserInfoURL)
+	setConfig(cfg, "logoutUrl", o.LogoutURL)
+	setConfig(cfg, "jwksUrl", o.JWKSURL)
+	setConfig(cfg, "clientId", o.ClientID)
+	setConfig(cfg, "clientSecret", o.ClientSecret)
+	setConfig(cfg, "clientAuthMethod", o.ClientAuthMethod)
+	setConfig(cfg, "defaultScope", o.DefaultScope)
+	setConfig(cfg, "pkceMethod", o.PKCEMethod)
+	setConfig(cfg, "syncMode", o.SyncMode)
+	cfg["useJwksUrl"] = strconv.FormatBool(o.JWKSURL != "")
+	cfg["validateSignature"] = strconv.FormatBool(o.ValidateSignature)
+	cfg["pkceEnabled"] = strconv.FormatBool(o.PKCEEnabled)
+	cfg["disableUserInfo"] = strconv.FormatBool(o.DisableUserInfo)
+	cfg["backchannelSupported"] = strconv.FormatBool(o.BackchannelSupported)
+	return cfg
+}
+
+func setConfig(cfg map[string]string, key, value string) {
+	if value != "" {
+		cfg[key] = value
+	}
+}
+
+func configBool(cfg map[string]string, key string) bool {
+	b, _ := strconv.ParseBool(cfg[key])
+	return b
+}
+
+func additionalConfig(cfg map[string]string, known []string) map[string]string {
+	extra := make(map[string]string)
+	for k, v := range cfg {
+		if !slices.Contains(known, k) {
+			extra[k] = v
+		}
+	}
+	return extra
+}
+
+// Returns a template matching the attribute names used by MakeDefaultMapper for SAML, or the standard OIDC claims
+func DefaultAuthenticationProviderMapperTemplate(providerId string) AuthenticationProviderMapperTemplate {
+	if providerId == "saml" {
+		return AuthenticationProviderMapperTemplate{
+			Email:         "Email",
+			FirstName:     "First name",
+			LastName:      "Last name",
+			Username:      "Username",
+			Groups:        "Groups",
+			RoleAttribute: "Role",
+			Roles:         map[string]string{"Scanner": "ast-app.ast-scanner"},
+		}
+	}
+	return AuthenticationProviderMapperTemplate{
+		Email:         "email",
+		FirstName:     "given_name",
+		LastName:      "family_name",
+		Username:      "preferred_username",
+		Groups:        "groups",
+		RoleAttribute: "roles",
+		Roles:         map[string]string{"Scanner": "ast-app.ast-scanner"},
+	}
+}
+
+// Returns the mappers described by the template, using the SAML or OIDC mapper types depending on the provider
+func (t AuthenticationProviderMapperTemplate) GetMappers(provider AuthenticationProvider) []AuthenticationProviderMapper {
+	mappers := []AuthenticationProviderMapper{}
+	saml := provider.IsSAML()
+
+	attribute := func(name, userAttribute, source string) {
+		if source == "" {
+			return
+		}
+		m := AuthenticationProviderMapper{Name: name, Alias: provider.Alias, Config: AuthenticationProviderMapperConfig{SyncMode: "FORCE", UserAttribute: userAttribute}}
+		if saml {
+			m.Mapper = "saml-user-attribute-idp-mapper"
+			m.Config.Format = "ATTRIBUTE_FORMAT_BASIC"
+			m.Config.Name = source
+		} else {
+			m.Mapper = "oidc-user-attribute-idp-mapper"
+			m.Config.Claim = source
+		}
+		mappers = append(mappers, m)
+	}
+	attribute("Email", "email", t.Email)
+	attribute("First Name", "firstName", t.FirstName)
+	attribute("Last Name", "lastName", t.LastName)
+
+	if t.Username != "" {
+		m := AuthenticationProviderMapper{Name: "Username", Alias: provider.Alias, Config: AuthenticationProviderMapperConfig{SyncMode: "FORCE", Target: "LOCAL"}}
+		if saml {
+			m.Mapper = "saml-username-idp-mapper"
+			m.Config.Template = fmt.Sprintf("${ATTRIBUTE.%v}", t.Username)
+		} else {
+			m.Mapper = "oidc-username-idp-mapper"
+			m.Config.Template = fmt.Sprintf("${CLAIM.%v}", t.Username)
+		}
+		mappers = append(mappers, m)
+	}
+
+	if t.Groups != "" {
+		m := AuthenticationProviderMapper{Name: "Groups", Alias: provider.Alias, Config: AuthenticationProviderMapperConfig{SyncMode: "FORCE"}}
+		if saml {
+			m.Mapper = "custom-group-saml-idp-mapper"
+			m.Config.Name = t.Groups
+		} else {
+			m.Mapper = "custom-group-oidc-idp-mapper"
+			m.Config.Claim = t.Groups
+		}
+		mappers = append(mappers, m)
+	}
+
+	if t.RoleAttribute != "" {
+		values := make([]string, 0, len(t.Roles))
+		for value := range t.Roles {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			m := AuthenticationProviderMapper{Name: fmt.Sprintf("%v Role", value), Alias: provider.Alias, Config: AuthenticationProviderMapperConfig{SyncMode: "FORCE"}}
+			if saml {
+				m.Mapper = "custom-roles-saml-idp-mapper"
+				m.Config.Name = t.RoleAttribute
+				m.Config.Value = value
+				m.Config.Role = t.Roles[value]
+			} else {
+				m.Mapper = "oidc-role-idp-mapper"
+				m.Config.Claim = t.RoleAttribute
+				m.Config.ClaimValue = value
+				m.Config.ClaimRole = t.Roles[value]
+			}
+			mappers = append(mappers, m)
+		}
+	}
+
+	return mappers
+}
+
+// Compares the current mappers with the desired mappers by name. Mappers that exist only in current are reported as removals.
+func DiffAuthenticationProviderMappers(current, desired []AuthenticationProviderMapper) []AuthenticationProviderMapperChange {
+	changes := []AuthenticationProviderMapperChange{}
+
+	for id := range desired {
+		d := &desired[id]
+		var cur *AuthenticationProviderMapper
+		for cid := range current {
+			if current[cid].Name == d.Name {
+				cur = &current[cid]
+				break
+			}
+		}
+
+		if cur == nil {
+			changes = append(changes, AuthenticationProviderMapperChange{Action: AuthenticationProviderMapperAdd, Name: d.Name, Desired: d})
+			continue
+		}
+
+		details := []string{}
+		if cur.Mapper != d.Mapper {
+			details = append(details, fmt.Sprintf("type: %v -> %v", cur.Mapper, d.Mapper))
+		}
+		curConfig, desiredConfig := mapperConfigMap(cur.Config), mapperConfigMap(d.Config)
+		keys := []string{}
+		for k := range curConfig {
+			keys = append(keys, k)
+		}
+		for k := range desiredConfig {
+			if _, ok := curConfig[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if curConfig[k] != desiredConfig[k] {
+				details = append(details, fmt.Sprintf("%v: '%v' -> '%v'", k, curConfig[k], desiredConfig[k]))
+			}
+		}
+
+		if len(details) > 0 {
+			changes = append(changes, AuthenticationProviderMapperChange{Action: AuthenticationProviderMapperUpdate, Name: d.Name, Current: cur, Desired: d, Details: details})
+		}
+	}
+
+	for id := range current {
+		found := false
+		for _, d := range desired {
+			if d.Name == current[id].Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			changes = append(changes, AuthenticationProviderMapperChange{Action: AuthenticationProviderMapperRemove, Name: current[id].Name, Current: &current[id]})
+		}
+	}
+
+	return changes
+}
+
+func mapperConfigMap(config AuthenticationProviderMapperConfig) map[string]string {
+	m := make(map[string]string)
+	data, _ := json.Marshal(config)
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+/*
+Brings the provider's mappers in line with the desired mappers and returns the changes made.
+Mappers that are not in the desired list are only deleted if removeExtra is true, otherwise they are left as-is
+and not included in the returned changes.
+*/
+func (c Cx1Client) ApplyAuthenticationProviderMappers(provider AuthenticationProvider, desired []AuthenticationProviderMapper, removeExtra bool) ([]AuthenticationProviderMapperChange, error) {
+	applied := []AuthenticationProviderMapperChange{}
+
+	current, err := c.GetAuthenticationProviderMappers(provider)
+	if err != nil {
+		return applied, fmt.Errorf("failed to get mappers for %v: %s", provider.String(), err)
+	}
+
+	for _, change := range DiffAuthenticationProviderMappers(current, desired) {
+		switch change.Action {
+		case AuthenticationProviderMapperAdd:
+			mapper := *change.Desired
+			mapper.Alias = provider.Alias
+			err = c.AddAuthenticationProviderMapper(mapper)
+		case AuthenticationProviderMapperUpdate:
+			mapper := *change.Desired
+			mapper.ID = change.Current.ID
+			mapper.Alias = provider.Alias
+			err = c.UpdateAuthenticationProviderMapper(mapper)
+		case AuthenticationProviderMapperRemove:
+			if !removeExtra {
+				continue
+			}
+			err = c.DeleteAuthenticationProviderMapper(*change.Current)
+		}
+		if err != nil {
+			return applied, fmt.Errorf("failed to %v mapper %v: %s", change.Action, change.Name, err)
+		}
+		c.logger.Debugf("Applied %v", change.String())
+		applied = append(applied, change)
+	}
+
+	return applied, nil
+}
+
+func (c AuthenticationProviderMapperChange) String() string {
+	if len(c.Details) == 0 {
+		return fmt.Sprintf("%v mapper %v", c.Action, c.Name)
+	}
+	return fmt.Sprintf("%v mapper %v: %v", c.Action, c.Name, strings.Join(c.Details, ", "))
+}
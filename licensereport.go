@@ -0,0 +1,265 @@
+package Cx1ClientGo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Compares the tenant license (from the access token claims) with actual usage:
+	- users who logged in within ActiveDays vs UsersCount
+	- peak number of concurrent scans in the scan history, including queued time, for comparison with MaxConcurrentScans
+	- engines used by scans in the period, per project, vs AllowedEngines
+	Warnings are raised when usage reaches WarnThreshold of a limit, when all scan slots are in use with scans queued,
+	or when scans used an engine that is not licensed.
+
+	The scan list only records when a scan was created and last updated, not when it started running, so a scan is
+	counted from creation (including any time spent queued) until its last update. Queued scans do not use a slot, so
+	this peak can exceed MaxConcurrentScans without the limit being reached, and no warning is raised for it.
+	Scans created before Since that are still queued or running are included, counted from Since.
+*/
+
+const (
+	LicenseUsageOK        = "ok"
+	LicenseUsageWarning   = "warning"
+	LicenseUsageExceeded  = "exceeded"
+	LicenseUsageUnlimited = "unlimited"
+)
+
+func (c Cx1Client) GetLicenseReport(options LicenseReportOptions) (LicenseReport, error) {
+	now := time.Now().UTC()
+	if options.Since.IsZero() {
+		options.Since = now.AddDate(0, 0, -30)
+	}
+	if options.ActiveDays == 0 {
+		options.ActiveDays = 30
+	}
+	if options.WarnThreshold == 0 {
+		options.WarnThreshold = 0.8
+	}
+
+	license := c.GetLicense()
+	report := LicenseReport{
+		Tenant:         c.tenant,
+		PackageName:    license.PackageName,
+		GeneratedAt:    now,
+		Since:          options.Since,
+		Engines:        []LicenseEngineUsage{},
+		UnusedEngines:  []string{},
+		ProjectEngines: make(map[string][]string),
+		Warnings:       []string{},
+	}
+	c.logger.Infof("Generating license report for tenant %v since %v", c.tenant, options.Since.Format(time.RFC3339))
+
+	users, err := c.GetAllUsers()
+	if err != nil {
+		return report, fmt.Errorf("failed to get users: %s", err)
+	}
+	activeSince := now.AddDate(0, 0, -options.ActiveDays)
+	active := 0
+	for _, u := range users {
+		if !u.Enabled {
+			continue
+		}
+		report.EnabledUsers++
+		if u.LastLogin.After(activeSince) {
+			active++
+		}
+	}
+	report.Users = newLicenseUsage(fmt.Sprintf("users active in the last %d days", options.ActiveDays), active, license.LicenseData.UsersCount, options.WarnThreshold)
+
+	summary, err := c.GetScansSummary()
+	if err != nil {
+		c.logger.Warnf("Failed to get scan summary: %s", err)
+	} else {
+		report.RunningScans = summary.Running
+		report.QueuedScans = summary.Queued
+	}
+
+	_, scans, err := c.GetAllScansFiltered(ScanFilter{
+		BaseFilter: BaseFilter{Limit: c.pagination.Scans},
+		FromDate:   options.Since,
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to get scans since %v: %s", options.Since.Format(time.RFC3339), err)
+	}
+
+	_, activeScans, err := c.GetAllScansFiltered(ScanFilter{
+		BaseFilter: BaseFilter{Limit: c.pagination.Scans},
+		Statuses:   []string{"Queued", "Running"},
+		ToDate:     options.Since,
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to get scans still active since %v: %s", options.Since.Format(time.RFC3339), err)
+	}
+
+	peak, peakAt := peakConcurrentScans(append(scans, activeScans...), options.Since, now)
+	report.ConcurrentScans = newLicenseUsage("peak concurrent scans incl. queued", peak, license.LicenseData.MaxConcurrentScans, options.WarnThreshold)
+	report.PeakConcurrentAt = peakAt
+
+	c.addLicenseEngineUsage(&report, scans)
+
+	report.addWarning(report.Users)
+	if license.LicenseData.MaxConcurrentScans > 0 && int(report.RunningScans) >= license.LicenseData.MaxConcurrentScans && report.QueuedScans > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("all %d scan slots are in use with %d scans queued", license.LicenseData.MaxConcurrentScans, report.QueuedScans))
+	}
+	for _, e := range report.Engines {
+		if !e.Allowed {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("engine %v was used by %d scans in %d projects but is not in the license", e.Engine, e.ScanCount, len(e.Projects)))
+		}
+	}
+
+	for _, w := range report.Warnings {
+		c.logger.Warnf("License: %v", w)
+	}
+	return report, nil
+}
+
+func newLicenseUsage(name string, used, limit int, threshold float64) LicenseUsage {
+	usage := LicenseUsage{Name: name, Used: used, Limit: limit, Status: LicenseUsageUnlimited}
+	if limit <= 0 {
+		return usage
+	}
+
+	usage.Ratio = float64(used) / float64(limit)
+	switch {
+	case used > limit:
+		usage.Status = LicenseUsageExceeded
+	case usage.Ratio >= threshold:
+		usage.Status = LicenseUsageWarning
+	default:
+		usage.Status = LicenseUsageOK
+	}
+	return usage
+}
+
+func (r *LicenseReport) addWarning(usage LicenseUsage) {
+	switch usage.Status {
+	case LicenseUsageExceeded:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%v: %d exceeds the licensed %d", usage.Name, usage.Used, usage.Limit))
+	case LicenseUsageWarning:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%v: %d is %.0f%% of the licensed %d", usage.Name, usage.Used, usage.Ratio*100, usage.Limit))
+	}
+}
+
+// returns the highest number of overlapping scans (from creation, starting no earlier than since) and when it was first reached
+func peakConcurrentScans(scans []Scan, since, now time.Time) (int, time.Time) {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	events := []event{}
+	seen := make(map[string]bool)
+
+	for _, s := range scans {
+		if seen[s.ScanID] {
+			continue
+		}
+		seen[s.ScanID] = true
+
+		start, err := time.Parse(time.RFC3339Nano, s.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if start.Before(since) {
+			start = since
+		}
+		end := now
+		if s.Status != "Running" && s.Status != "Queued" {
+			if end, err = time.Parse(time.RFC3339Nano, s.UpdatedAt); err != nil {
+				continue
+			}
+		}
+		if end.Before(start) {
+			continue
+		}
+		events = append(events, event{start, 1}, event{end, -1})
+	}
+
+	// scans ending at the same time as another starts are not concurrent
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+
+	peak, current := 0, 0
+	var peakAt time.Time
+	for _, e := range events {
+		current += e.delta
+		if current > peak {
+			peak = current
+			peakAt = e.at
+		}
+	}
+	return peak, peakAt
+}
+
+func (c Cx1Client) addLicenseEngineUsage(report *LicenseReport, scans []Scan) {
+	usage := make(map[string]*LicenseEngineUsage)
+	used := []string{}
+
+	for _, s := range scans {
+		for _, engine := range s.Engines {
+			e, ok := usage[engine]
+			if !ok {
+				licenseName, allowed := c.IsEngineAllowed(engine)
+				e = &LicenseEngineUsage{Engine: engine, License: licenseName, Allowed: allowed, Projects: []string{}}
+				if licenseName == "" {
+					// not mapped to a license engine, so it can't be checked
+					e.Allowed = true
+				}
+				usage[engine] = e
+			}
+			e.ScanCount++
+			if !slices.Contains(e.Projects, s.ProjectName) {
+				e.Projects = append(e.Projects, s.ProjectName)
+			}
+			if !slices.Contains(report.ProjectEngines[s.ProjectName], engine) {
+				report.ProjectEngines[s.ProjectName] = append(report.ProjectEngines[s.ProjectName], engine)
+			}
+			if e.License != "" && !slices.Contains(used, e.License) {
+				used = append(used, e.License)
+			}
+		}
+	}
+
+	for _, e := range usage {
+		sort.Strings(e.Projects)
+		report.Engines = append(report.Engines, *e)
+	}
+	sort.Slice(report.Engines, func(i, j int) bool { return report.Engines[i].Engine < report.Engines[j].Engine })
+	for _, engines := range report.ProjectEngines {
+		sort.Strings(engines)
+	}
+
+	for _, licensed := range c.GetLicense().LicenseData.AllowedEngines {
+		found := false
+		for _, u := range used {
+			if strings.EqualFold(u, licensed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report.UnusedEngines = append(report.UnusedEngines, licensed)
+		}
+	}
+}
+
+func (r LicenseReport) String() string {
+	return fmt.Sprintf("License %v for tenant %v: %d/%d active users, peak %d/%d concurrent scans, %d engines used, %d warnings",
+		r.PackageName, r.Tenant, r.Users.Used, r.Users.Limit, r.ConcurrentScans.Used, r.ConcurrentScans.Limit, len(r.Engines), len(r.Warnings))
+}
+
+func (u LicenseUsage) String() string {
+	if u.Limit <= 0 {
+		return fmt.Sprintf("%v: %d (no limit)", u.Name, u.Used)
+	}
+	return fmt.Sprintf("%v: %d/%d (%.0f%%, %v)", u.Name, u.Used, u.Limit, u.Ratio*100, u.Status)
+}
@@ -0,0 +1,60 @@
+package Cx1ClientGo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeakConcurrentScans(t *testing.T) {
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := since.Add(10 * time.Hour)
+	at := func(hours int) string { return since.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339) }
+
+	tests := []struct {
+		name   string
+		scans  []Scan
+		peak   int
+		peakAt time.Time
+	}{
+		{"none", []Scan{}, 0, time.Time{}},
+		{
+			name: "back to back scans do not overlap",
+			scans: []Scan{
+				{ScanID: "1", Status: "Completed", CreatedAt: at(1), UpdatedAt: at(2)},
+				{ScanID: "2", Status: "Completed", CreatedAt: at(2), UpdatedAt: at(3)},
+			},
+			peak: 1, peakAt: since.Add(time.Hour),
+		},
+		{
+			name: "running scans last until now",
+			scans: []Scan{
+				{ScanID: "1", Status: "Running", CreatedAt: at(1)},
+				{ScanID: "2", Status: "Completed", CreatedAt: at(5), UpdatedAt: at(6)},
+			},
+			peak: 2, peakAt: since.Add(5 * time.Hour),
+		},
+		{
+			name: "scans created before since are counted from since",
+			scans: []Scan{
+				{ScanID: "1", Status: "Queued", CreatedAt: at(-48)},
+				{ScanID: "2", Status: "Completed", CreatedAt: at(0), UpdatedAt: at(1)},
+			},
+			peak: 2, peakAt: since,
+		},
+		{
+			name: "duplicates are counted once",
+			scans: []Scan{
+				{ScanID: "1", Status: "Running", CreatedAt: at(-1)},
+				{ScanID: "1", Status: "Running", CreatedAt: at(-1)},
+			},
+			peak: 1, peakAt: since,
+		},
+	}
+
+	for _, test := range tests {
+		peak, peakAt := peakConcurrentScans(test.scans, since, now)
+		if peak != test.peak || !peakAt.Equal(test.peakAt) {
+			t.Errorf("%v: expected %d at %v, got %d at %v", test.name, test.peak, test.peakAt, peak, peakAt)
+		}
+	}
+}
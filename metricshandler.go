@@ -0,0 +1,522 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	MetricsHandler is an http.Handler serving tenant health metrics in the Prometheus text exposition format
+	(version 0.0.4), so it can be scraped directly without a custom collector. The metrics are collected in the
+	background every Interval and the last snapshot is served, so scrapes never call Cx1 themselves.
+
+	Collected are:
+	  - scan counts per status and the queue length (GetScansSummary)
+	  - results per severity and state of the last completed scan of each project, also summed per application
+	    (scan summary counters). After the first collection only projects with newly completed scans are refreshed.
+	  - license limits and usage (GetLicenseReport), collected every LicenseInterval as it lists users and scans
+	  - a histogram of the durations of scans which finished since the handler started. As in the license report,
+	    the duration runs from creation (including time spent queued) until the scan's last update.
+*/
+
+// upper bounds in seconds of the scan duration histogram buckets
+var metricsScanDurationBuckets = []float64{60, 300, 600, 1800, 3600, 7200, 14400, 28800}
+
+type metricsSample struct {
+	labels []string // name, value pairs
+	value  float64
+}
+
+type metricsFamily struct {
+	name    string
+	help    string
+	kind    string // gauge, counter or histogram
+	samples []metricsSample
+}
+
+type metricsProjectResults struct {
+	scanID  string
+	created time.Time
+	name    string
+	counts  map[[3]string]uint64 // engine, severity, state -> results
+}
+
+// Creates the handler and starts collecting, the handler returns 503 until the first collection has finished
+func NewMetricsHandler(ctx context.Context, client *Cx1Client, options MetricsHandlerOptions) *MetricsHandler {
+	if options.Interval <= 0 {
+		options.Interval = 5 * time.Minute
+	}
+	if options.LicenseInterval <= 0 {
+		options.LicenseInterval = time.Hour
+	}
+	if options.Prefix == "" {
+		options.Prefix = "cx1"
+	}
+
+	mctx, cancel := context.WithCancel(ctx)
+	h := &MetricsHandler{
+		client:         client,
+		options:        options,
+		projects:       make(map[string]*metricsProjectResults),
+		finishedScans:  make(map[string]time.Time),
+		durationCounts: make([]uint64, len(metricsScanDurationBuckets)),
+		ctx:            mctx,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	client.logger.Infof("Collecting metrics every %v", options.Interval)
+
+	go h.run()
+	return h
+}
+
+// Serves the last collected snapshot
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	snapshot := h.snapshot
+	h.mutex.Unlock()
+
+	if snapshot == nil {
+		http.Error(w, "metrics not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(snapshot)
+}
+
+// Stops collecting, the handler keeps serving the last snapshot
+func (h *MetricsHandler) Close() {
+	h.cancel()
+	<-h.done
+}
+
+// Returns the number of projects with result metrics and the number of scans in the duration histogram
+func (h *MetricsHandler) Count() (int, uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.projects), h.durationTotal
+}
+
+func (h *MetricsHandler) String() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return fmt.Sprintf("Metrics handler: %d projects, %d scan durations, last collected %v", len(h.projects), h.durationTotal, h.lastCollection.Format(time.RFC3339))
+}
+
+func (h *MetricsHandler) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.collect()
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collects all metrics and renders a new snapshot, errors are logged and counted but keep the previous values
+func (h *MetricsHandler) collect() {
+	start := time.Now()
+	h.mutex.Lock()
+	resultsSince, durationsSince, lastLicense := h.resultsSince, h.durationsSince, h.lastLicense
+	h.mutex.Unlock()
+
+	families := []metricsFamily{}
+	errors := 0
+
+	if f, err := h.collectScanStatus(); err != nil {
+		h.client.logger.Warnf("Failed to collect scan status metrics: %s", err)
+		errors++
+	} else {
+		families = append(families, f...)
+	}
+
+	if err := h.collectResults(resultsSince); err != nil {
+		h.client.logger.Warnf("Failed to collect result metrics: %s", err)
+		errors++
+	} else {
+		resultsSince = start
+	}
+
+	if err := h.collectScanDurations(durationsSince, start); err != nil {
+		h.client.logger.Warnf("Failed to collect scan duration metrics: %s", err)
+		errors++
+	} else {
+		durationsSince = start
+	}
+
+	var license []metricsFamily
+	if lastLicense.IsZero() || start.Sub(lastLicense) >= h.options.LicenseInterval {
+		if report, err := h.client.GetLicenseReport(LicenseReportOptions{}); err != nil {
+			h.client.logger.Warnf("Failed to collect license metrics: %s", err)
+			errors++
+		} else {
+			license = h.licenseFamilies(report)
+			lastLicense = start
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if license != nil {
+		h.license = license
+	}
+	h.resultsSince, h.durationsSince, h.lastLicense = resultsSince, durationsSince, lastLicense
+	h.errors += uint64(errors)
+	h.lastCollection = start
+
+	families = append(families, h.license...)
+	families = append(families, h.resultFamilies()...)
+	families = append(families, h.durationFamily())
+	families = append(families,
+		metricsFamily{name: "metrics_collection_duration_seconds", help: "Duration of the last metrics collection", kind: "gauge",
+			samples: []metricsSample{{value: time.Since(start).Seconds()}}},
+		metricsFamily{name: "metrics_collection_timestamp_seconds", help: "Time of the last metrics collection", kind: "gauge",
+			samples: []metricsSample{{value: float64(start.Unix())}}},
+		metricsFamily{name: "metrics_collection_errors_total", help: "Failed metric collections", kind: "counter",
+			samples: []metricsSample{{value: float64(h.errors)}}},
+	)
+
+	var b bytes.Buffer
+	writeMetricsFamilies(&b, h.options.Prefix, families)
+	h.snapshot = b.Bytes()
+	h.client.logger.Debugf("Collected metrics in %v with %d errors", time.Since(start), errors)
+}
+
+func (h *MetricsHandler) collectScanStatus() ([]metricsFamily, error) {
+	summary, err := h.client.GetScansSummary()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := metricsFamily{name: "scans", help: "Number of scans per status", kind: "gauge"}
+	for _, s := range []struct {
+		status string
+		count  uint64
+	}{
+		{"Canceled", summary.Canceled},
+		{"Completed", summary.Completed},
+		{"Failed", summary.Failed},
+		{"Partial", summary.Partial},
+		{"Queued", summary.Queued},
+		{"Running", summary.Running},
+	} {
+		statuses.samples = append(statuses.samples, metricsSample{labels: []string{"status", s.status}, value: float64(s.count)})
+	}
+
+	return []metricsFamily{
+		statuses,
+		{name: "scan_queue_length", help: "Number of queued scans", kind: "gauge", samples: []metricsSample{{value: float64(summary.Queued)}}},
+		{name: "scans_running", help: "Number of running scans", kind: "gauge", samples: []metricsSample{{value: float64(summary.Running)}}},
+	}, nil
+}
+
+// refreshes the result counts of projects, on the first collection for every project and afterwards only for
+// projects with scans completed since the previous collection. The scan list is filtered by creation time, so
+// scans created up to a day earlier are listed and those which completed since the previous collection are used.
+func (h *MetricsHandler) collectResults(since time.Time) error {
+	projects, err := h.client.GetAllProjects()
+	if err != nil {
+		return fmt.Errorf("failed to get projects: %s", err)
+	}
+	applications, err := h.client.GetAllApplications()
+	if err != nil {
+		return fmt.Errorf("failed to get applications: %s", err)
+	}
+	applicationNames := make(map[string]string, len(applications))
+	for _, a := range applications {
+		applicationNames[a.ApplicationID] = a.Name
+	}
+
+	lastScans := make(map[string]Scan) // project ID -> last completed scan
+	if since.IsZero() {
+		for _, p := range projects {
+			scans, err := h.client.GetLastScansByStatusAndID(p.ProjectID, 1, []string{"Completed"})
+			if err != nil {
+				return fmt.Errorf("failed to get last scan of project %v: %s", p.String(), err)
+			}
+			if len(scans) > 0 {
+				lastScans[p.ProjectID] = scans[0]
+			}
+		}
+	} else {
+		_, scans, err := h.client.GetAllScansFiltered(ScanFilter{
+			BaseFilter: BaseFilter{Limit: h.client.pagination.Scans},
+			Statuses:   []string{"Completed"},
+			Sort:       []string{ScanSortCreatedDescending},
+			FromDate:   since.Add(-24 * time.Hour), // scans completed since may have been created a while ago
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get scans since %v: %s", since.Format(time.RFC3339), err)
+		}
+		for _, s := range scans {
+			if updated, err := time.Parse(time.RFC3339Nano, s.UpdatedAt); err != nil || updated.Before(since) {
+				continue
+			}
+			if _, ok := lastScans[s.ProjectID]; !ok {
+				lastScans[s.ProjectID] = s
+			}
+		}
+	}
+
+	// a scan which completed late may be older than the scan the counts were taken from
+	h.mutex.Lock()
+	for projectID, scan := range lastScans {
+		if current, ok := h.projects[projectID]; ok {
+			created, _ := time.Parse(time.RFC3339Nano, scan.CreatedAt)
+			if current.scanID == scan.ScanID || created.Before(current.created) {
+				delete(lastScans, projectID)
+			}
+		}
+	}
+	h.mutex.Unlock()
+
+	scanIDs := make([]string, 0, len(lastScans))
+	for _, scan := range lastScans {
+		scanIDs = append(scanIDs, scan.ScanID)
+	}
+	summaries := make(map[string]*ScanSummary, len(scanIDs))
+	for start := 0; start < len(scanIDs); start += 50 {
+		end := start + 50
+		if end > len(scanIDs) {
+			end = len(scanIDs)
+		}
+		batch, err := h.client.GetScanSummariesByID(scanIDs[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to get scan summaries: %s", err)
+		}
+		for id := range batch {
+			summaries[batch[id].ScanID] = &batch[id]
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	existing := make(map[string]bool, len(projects))
+	h.applications = make(map[string][]string)
+	for _, p := range projects {
+		existing[p.ProjectID] = true
+		if p.Applications != nil {
+			for _, id := range *p.Applications {
+				if name, ok := applicationNames[id]; ok {
+					h.applications[name] = append(h.applications[name], p.ProjectID)
+				}
+			}
+		}
+
+		if scan, updated := lastScans[p.ProjectID]; updated && summaries[scan.ScanID] != nil {
+			h.projects[p.ProjectID] = newMetricsProjectResults(&scan, summaries[scan.ScanID])
+		}
+		if results, ok := h.projects[p.ProjectID]; ok {
+			results.name = p.Name
+		}
+	}
+	for id := range h.projects {
+		if !existing[id] {
+			delete(h.projects, id)
+		}
+	}
+	return nil
+}
+
+func newMetricsProjectResults(scan *Scan, summary *ScanSummary) *metricsProjectResults {
+	results := &metricsProjectResults{scanID: scan.ScanID, counts: make(map[[3]string]uint64)}
+	results.created, _ = time.Parse(time.RFC3339Nano, scan.CreatedAt)
+	add := func(engine string, severities []ScanSummarySeverityCounter, states []ScanSummaryStateCounter) {
+		for _, s := range severities {
+			results.counts[[3]string{engine, s.Severity, ""}] += s.Counter
+		}
+		for _, s := range states {
+			results.counts[[3]string{engine, "", s.State}] += s.Counter
+		}
+	}
+	add("sast", summary.SASTCounters.SeverityCounters, summary.SASTCounters.StateCounters)
+	add("kics", summary.IACCounters.SeverityCounters, summary.IACCounters.StateCounters)
+	add("sca", summary.SCACounters.SeverityCounters, summary.SCACounters.StateCounters)
+	add("containers", summary.SCAContainersCounters.SeverityVulnerabilitiesCounters, summary.SCAContainersCounters.StateVulnerabilityCounters)
+	add("apisec", summary.APISecCounters.SeverityCounters, summary.APISecCounters.StateCounters)
+	return results
+}
+
+// adds the durations of scans which finished since the previous collection to the histogram
+func (h *MetricsHandler) collectScanDurations(since, now time.Time) error {
+	if since.IsZero() {
+		since = now.Add(-h.options.Interval)
+	}
+
+	_, scans, err := h.client.GetAllScansFiltered(ScanFilter{
+		BaseFilter: BaseFilter{Limit: h.client.pagination.Scans},
+		Statuses:   []string{"Completed", "Partial", "Failed", "Canceled"},
+		FromDate:   since.Add(-24 * time.Hour), // scans finishing now may have been created a while ago
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get finished scans: %s", err)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, s := range scans {
+		if _, ok := h.finishedScans[s.ScanID]; ok {
+			continue
+		}
+		created, err1 := time.Parse(time.RFC3339Nano, s.CreatedAt)
+		updated, err2 := time.Parse(time.RFC3339Nano, s.UpdatedAt)
+		if err1 != nil || err2 != nil || updated.Before(since) {
+			continue
+		}
+		h.finishedScans[s.ScanID] = updated
+
+		seconds := updated.Sub(created).Seconds()
+		for id, bound := range metricsScanDurationBuckets {
+			if seconds <= bound {
+				h.durationCounts[id]++
+			}
+		}
+		h.durationSum += seconds
+		h.durationTotal++
+	}
+
+	for scanID, updated := range h.finishedScans {
+		if updated.Before(since.Add(-48 * time.Hour)) {
+			delete(h.finishedScans, scanID)
+		}
+	}
+	return nil
+}
+
+func (h *MetricsHandler) resultFamilies() []metricsFamily {
+	bySeverity := metricsFamily{name: "project_results", help: "Results per severity in the last completed scan of the project", kind: "gauge"}
+	byState := metricsFamily{name: "project_results_by_state", help: "Results per state in the last completed scan of the project", kind: "gauge"}
+	appSeverity := metricsFamily{name: "application_results", help: "Results per severity in the last completed scans of the application's projects", kind: "gauge"}
+	appState := metricsFamily{name: "application_results_by_state", help: "Results per state in the last completed scans of the application's projects", kind: "gauge"}
+
+	for _, results := range h.projects {
+		for key, count := range results.counts {
+			if key[1] != "" {
+				bySeverity.samples = append(bySeverity.samples, metricsSample{labels: []string{"project", results.name, "engine", key[0], "severity", key[1]}, value: float64(count)})
+			} else {
+				byState.samples = append(byState.samples, metricsSample{labels: []string{"project", results.name, "engine", key[0], "state", key[2]}, value: float64(count)})
+			}
+		}
+	}
+
+	for application, projectIDs := range h.applications {
+		counts := make(map[[3]string]uint64)
+		for _, id := range projectIDs {
+			if results, ok := h.projects[id]; ok {
+				for key, count := range results.counts {
+					counts[key] += count
+				}
+			}
+		}
+		for key, count := range counts {
+			if key[1] != "" {
+				appSeverity.samples = append(appSeverity.samples, metricsSample{labels: []string{"application", application, "engine", key[0], "severity", key[1]}, value: float64(count)})
+			} else {
+				appState.samples = append(appState.samples, metricsSample{labels: []string{"application", application, "engine", key[0], "state", key[2]}, value: float64(count)})
+			}
+		}
+	}
+
+	return []metricsFamily{bySeverity, byState, appSeverity, appState}
+}
+
+func (h *MetricsHandler) licenseFamilies(report LicenseReport) []metricsFamily {
+	used := metricsFamily{name: "license_used", help: "License usage (active users, peak concurrent scans including queued time)", kind: "gauge"}
+	limit := metricsFamily{name: "license_limit", help: "License limit, 0 if unlimited", kind: "gauge"}
+	for _, u := range []LicenseUsage{report.Users, report.ConcurrentScans} {
+		used.samples = append(used.samples, metricsSample{labels: []string{"name", u.Name}, value: float64(u.Used)})
+		limit.samples = append(limit.samples, metricsSample{labels: []string{"name", u.Name}, value: float64(u.Limit)})
+	}
+
+	engines := metricsFamily{name: "license_engine_scans", help: "Scans per engine in the license report period", kind: "gauge"}
+	for _, e := range report.Engines {
+		allowed := "false"
+		if e.Allowed {
+			allowed = "true"
+		}
+		engines.samples = append(engines.samples, metricsSample{labels: []string{"engine", e.Engine, "allowed", allowed}, value: float64(e.ScanCount)})
+	}
+
+	return []metricsFamily{used, limit, engines,
+		{name: "license_warnings", help: "Number of license warnings", kind: "gauge", samples: []metricsSample{{value: float64(len(report.Warnings))}}},
+	}
+}
+
+func (h *MetricsHandler) durationFamily() metricsFamily {
+	family := metricsFamily{name: "scan_duration_seconds", help: "Duration of finished scans from creation until last update", kind: "histogram"}
+	for id, bound := range metricsScanDurationBuckets {
+		family.samples = append(family.samples, metricsSample{labels: []string{"le", formatMetricsValue(bound)}, value: float64(h.durationCounts[id])})
+	}
+	family.samples = append(family.samples,
+		metricsSample{labels: []string{"le", "+Inf"}, value: float64(h.durationTotal)},
+		metricsSample{labels: []string{"__suffix", "_sum"}, value: h.durationSum},
+		metricsSample{labels: []string{"__suffix", "_count"}, value: float64(h.durationTotal)},
+	)
+	return family
+}
+
+// writes the families in the text exposition format, samples are sorted by labels for stable output
+func writeMetricsFamilies(w io.Writer, prefix string, families []metricsFamily) {
+	for _, f := range families {
+		name := prefix + "_" + f.name
+		fmt.Fprintf(w, "# HELP %v %v\n", name, f.help)
+		fmt.Fprintf(w, "# TYPE %v %v\n", name, f.kind)
+
+		lines := make([]string, 0, len(f.samples))
+		for _, s := range f.samples {
+			sampleName := name
+			if f.kind == "histogram" {
+				sampleName += "_bucket"
+			}
+			labels := []string{}
+			for i := 0; i+1 < len(s.labels); i += 2 {
+				if s.labels[i] == "__suffix" {
+					sampleName = name + s.labels[i+1]
+					continue
+				}
+				labels = append(labels, fmt.Sprintf("%v=\"%v\"", s.labels[i], escapeMetricsLabel(s.labels[i+1])))
+			}
+			line := sampleName
+			if len(labels) > 0 {
+				line += "{" + strings.Join(labels, ",") + "}"
+			}
+			lines = append(lines, line+" "+formatMetricsValue(s.value))
+		}
+		if f.kind != "histogram" { // buckets must stay in order
+			sort.Strings(lines)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func escapeMetricsLabel(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "\"", "\\\"")
+	return strings.ReplaceAll(value, "\n", "\\n")
+}
+
+func formatMetricsValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,113 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestEscapeMetricsLabel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "plain", expected: "plain"},
+		{value: "", expected: ""},
+		{value: `say "hi"`, expected: `say \"hi\"`},
+		{value: `C:\path`, expected: `C:\\path`},
+		{value: "two\nlines", expected: `two\nlines`},
+		{value: `\"`, expected: `\\\"`},
+		{value: "tab\there", expected: "tab\there"},
+	}
+
+	for _, test := range tests {
+		if escaped := escapeMetricsLabel(test.value); escaped != test.expected {
+			t.Errorf("escapeMetricsLabel(%q) = %q, expected %q", test.value, escaped, test.expected)
+		}
+	}
+}
+
+func TestFormatMetricsValue(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{value: 0, expected: "0"},
+		{value: 42, expected: "42"},
+		{value: 1.5, expected: "1.5"},
+		{value: 1e21, expected: "1e+21"},
+		{value: math.Inf(1), expected: "+Inf"},
+	}
+
+	for _, test := range tests {
+		if formatted := formatMetricsValue(test.value); formatted != test.expected {
+			t.Errorf("formatMetricsValue(%v) = %q, expected %q", test.value, formatted, test.expected)
+		}
+	}
+}
+
+func TestWriteMetricsFamilies(t *testing.T) {
+	tests := []struct {
+		name     string
+		families []metricsFamily
+		expected string
+	}{
+		{
+			name: "gauge without labels",
+			families: []metricsFamily{
+				{name: "scans_running", help: "Number of running scans", kind: "gauge", samples: []metricsSample{{value: 3}}},
+			},
+			expected: "# HELP cx1_scans_running Number of running scans\n" +
+				"# TYPE cx1_scans_running gauge\n" +
+				"cx1_scans_running 3\n",
+		},
+		{
+			name: "samples are sorted and labels escaped",
+			families: []metricsFamily{
+				{name: "project_results", help: "Results", kind: "gauge", samples: []metricsSample{
+					{labels: []string{"project", "web \"app\"", "severity", "High"}, value: 2},
+					{labels: []string{"project", "api\\v2", "severity", "Low"}, value: 5},
+				}},
+			},
+			expected: "# HELP cx1_project_results Results\n" +
+				"# TYPE cx1_project_results gauge\n" +
+				"cx1_project_results{project=\"api\\\\v2\",severity=\"Low\"} 5\n" +
+				"cx1_project_results{project=\"web \\\"app\\\"\",severity=\"High\"} 2\n",
+		},
+		{
+			name: "histogram buckets keep their order",
+			families: []metricsFamily{
+				{name: "scan_duration_seconds", help: "Durations", kind: "histogram", samples: []metricsSample{
+					{labels: []string{"le", "60"}, value: 1},
+					{labels: []string{"le", "300"}, value: 4},
+					{labels: []string{"le", "+Inf"}, value: 5},
+					{labels: []string{"__suffix", "_sum"}, value: 1234.5},
+					{labels: []string{"__suffix", "_count"}, value: 5},
+				}},
+			},
+			expected: "# HELP cx1_scan_duration_seconds Durations\n" +
+				"# TYPE cx1_scan_duration_seconds histogram\n" +
+				"cx1_scan_duration_seconds_bucket{le=\"60\"} 1\n" +
+				"cx1_scan_duration_seconds_bucket{le=\"300\"} 4\n" +
+				"cx1_scan_duration_seconds_bucket{le=\"+Inf\"} 5\n" +
+				"cx1_scan_duration_seconds_sum 1234.5\n" +
+				"cx1_scan_duration_seconds_count 5\n",
+		},
+		{
+			name: "family without samples",
+			families: []metricsFamily{
+				{name: "license_warnings", help: "Warnings", kind: "gauge"},
+			},
+			expected: "# HELP cx1_license_warnings Warnings\n" +
+				"# TYPE cx1_license_warnings gauge\n",
+		},
+	}
+
+	for _, test := range tests {
+		var b bytes.Buffer
+		writeMetricsFamilies(&b, "cx1", test.families)
+		if b.String() != test.expected {
+			t.Errorf("%v: got\n%v\nexpected\n%v", test.name, b.String(), test.expected)
+		}
+	}
+}
@@ -28,6 +28,8 @@ func (c *Cx1Client) InitializeClientVars() {
 		AuditCompilePollingDelaySeconds:           30,
 		AuditLanguagePollingMaxSeconds:            300,
 		AuditLanguagePollingDelaySeconds:          30,
+		AuditSessionHeartbeatSeconds:              60,
+		AuditSessionIdleMaxSeconds:                600,
 		ReportPollingMaxSeconds:                   300,
 		ReportPollingDelaySeconds:                 30,
 		ScanPollingMaxSeconds:                     0,
@@ -0,0 +1,541 @@
+package Cx1ClientGo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Calculates the effective permissions of users and service accounts on a tenant, application or project, and
+	explains where each permission came from. Permissions are granted by:
+	- roles assigned directly to the user (tenant-wide)
+	- client roles of the user's groups for applications and projects assigned to those groups (directly or through
+	  an application), including roles inherited from parent groups of an assigned group
+	- access-management assignments to the user or its groups, on the tenant, an application (including its projects) or a project
+	Composite roles are expanded so that each permission is listed along with the chain of roles that granted it.
+*/
+
+const (
+	PermissionViaUserRole   = "user role"
+	PermissionViaGroupRole  = "group role"
+	PermissionViaAssignment = "access assignment"
+)
+
+var permissionResourceTypes = []string{"tenant", "application", "project"}
+
+type permissionScope struct {
+	resourceType   string
+	resourceId     string
+	applicationIds []string // applications containing the project
+	groupIds       []string // groups assigned to the resource, or to an application containing the project
+}
+
+func NewPermissionResolver(client *Cx1Client) *PermissionResolver {
+	return &PermissionResolver{
+		client:            client,
+		composites:        make(map[string][]Role),
+		roles:             make(map[string]Role),
+		clientIDs:         make(map[string]string),
+		groups:            make(map[string]Group),
+		groupsByPath:      make(map[string]Group),
+		projects:          make(map[string]Project),
+		ancestors:         make(map[string][]Group),
+		applicationGroups: make(map[string][]string),
+	}
+}
+
+// Returns the permissions of the user on the resource, resourceType is one of tenant, application or project
+func (r *PermissionResolver) GetUserPermissions(user *User, resourceType, resourceId string) (EffectivePermissions, error) {
+	c := r.client
+	c.logger.Debugf("Resolving permissions of user %v on %v %v", user.String(), resourceType, resourceId)
+	perms := EffectivePermissions{
+		EntityID:     user.UserID,
+		EntityName:   user.UserName,
+		ResourceType: resourceType,
+		ResourceID:   resourceId,
+	}
+
+	scope, err := r.getScope(resourceType, resourceId)
+	if err != nil {
+		return perms, err
+	}
+
+	roles, err := c.GetUserRoles(user)
+	if err != nil {
+		return perms, fmt.Errorf("failed to get roles for user %v: %s", user.String(), err)
+	}
+	for _, role := range roles {
+		if err = r.addRoleGrants(&perms, role, PermissionViaUserRole, user.UserName, []string{}); err != nil {
+			return perms, err
+		}
+	}
+
+	if err = r.addAssignmentGrants(&perms, user.UserID, "user", user.UserName, scope); err != nil {
+		return perms, err
+	}
+
+	groups, err := c.GetUserGroups(user)
+	if err != nil {
+		return perms, fmt.Errorf("failed to get groups for user %v: %s", user.String(), err)
+	}
+
+	granted, assigned := []string{}, []string{}
+	for _, g := range groups {
+		ancestors, err := r.getAncestors(g)
+		if err != nil {
+			return perms, err
+		}
+
+		for _, group := range groupsWithAssignedRoles(ancestors, scope.groupIds) {
+			if slices.Contains(granted, group.GroupID) {
+				continue
+			}
+			granted = append(granted, group.GroupID)
+
+			for clientName, roleNames := range group.ClientRoles {
+				for _, roleName := range roleNames {
+					role, err := r.getRoleByName(clientName, roleName)
+					if err != nil {
+						return perms, err
+					}
+					if err = r.addRoleGrants(&perms, role, PermissionViaGroupRole, group.Path, []string{}); err != nil {
+						return perms, err
+					}
+				}
+			}
+		}
+
+		for _, group := range ancestors {
+			if slices.Contains(assigned, group.GroupID) {
+				continue
+			}
+			assigned = append(assigned, group.GroupID)
+
+			if err = r.addAssignmentGrants(&perms, group.GroupID, "group", group.Path, scope); err != nil {
+				return perms, err
+			}
+		}
+	}
+
+	sort.SliceStable(perms.Grants, func(i, j int) bool {
+		return perms.Grants[i].Permission < perms.Grants[j].Permission
+	})
+	return perms, nil
+}
+
+// Returns the permissions of the service account behind the OIDC client on the resource
+func (r *PermissionResolver) GetClientPermissions(client *OIDCClient, resourceType, resourceId string) (EffectivePermissions, error) {
+	user, err := r.client.GetServiceAccountByID(client.ID)
+	if err != nil {
+		return EffectivePermissions{}, fmt.Errorf("failed to get service account for client %v: %s", client.String(), err)
+	}
+	perms, err := r.GetUserPermissions(&user, resourceType, resourceId)
+	perms.EntityName = client.ClientID
+	return perms, err
+}
+
+/*
+Returns all users (including service accounts) that hold the permission on the resource.
+The grants in each result are limited to those explaining the requested permission.
+This checks every user in the tenant, so it can take a while on large tenants.
+*/
+func (r *PermissionResolver) WhoCan(permission, resourceType, resourceId string) ([]EffectivePermissions, error) {
+	var results []EffectivePermissions
+
+	users, err := r.client.GetAllUsers()
+	if err != nil {
+		return results, err
+	}
+
+	for _, user := range users {
+		perms, err := r.GetUserPermissions(&user, resourceType, resourceId)
+		if err != nil {
+			return results, err
+		}
+		if grants := perms.Explain(permission); len(grants) > 0 {
+			perms.Grants = grants
+			results = append(results, perms)
+		}
+	}
+
+	return results, nil
+}
+
+func (r *PermissionResolver) getScope(resourceType, resourceId string) (permissionScope, error) {
+	scope := permissionScope{resourceType: strings.ToLower(resourceType), resourceId: resourceId}
+
+	switch scope.resourceType {
+	case "tenant":
+	case "application":
+		groupIds, err := r.getApplicationGroups(resourceId)
+		if err != nil {
+			return scope, err
+		}
+		scope.groupIds = groupIds
+	case "project":
+		project, err := r.getProject(resourceId)
+		if err != nil {
+			return scope, err
+		}
+		scope.groupIds = append([]string{}, project.Groups...)
+		if project.Applications != nil {
+			scope.applicationIds = *project.Applications
+		}
+		for _, applicationId := range scope.applicationIds {
+			groupIds, err := r.getApplicationGroups(applicationId)
+			if err != nil {
+				return scope, err
+			}
+			for _, id := range groupIds {
+				if !slices.Contains(scope.groupIds, id) {
+					scope.groupIds = append(scope.groupIds, id)
+				}
+			}
+		}
+	default:
+		return scope, fmt.Errorf("invalid resource type %v, options are: %v", resourceType, strings.Join(permissionResourceTypes, ", "))
+	}
+	return scope, nil
+}
+
+/*
+Returns the groups in the chain (a group followed by its ancestors, as returned by getAncestors) whose client roles
+apply to a resource assigned to groupIds. Subgroups inherit the role mappings of their parents, so the roles of an
+ancestor apply when it or any group below it in the chain is assigned to the resource.
+*/
+func groupsWithAssignedRoles(chain []Group, groupIds []string) []Group {
+	groups := []Group{}
+	assigned := false
+	for _, group := range chain {
+		assigned = assigned || slices.Contains(groupIds, group.GroupID)
+		if assigned {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func (s permissionScope) includes(assignment AccessAssignment) bool {
+	switch assignment.ResourceType {
+	case "tenant":
+		return true
+	case "application":
+		return (s.resourceType == "application" && s.resourceId == assignment.ResourceID) || slices.Contains(s.applicationIds, assignment.ResourceID)
+	case "project":
+		return s.resourceType == "project" && s.resourceId == assignment.ResourceID
+	}
+	return false
+}
+
+func (r *PermissionResolver) addAssignmentGrants(perms *EffectivePermissions, entityId, entityType, entityName string, scope permissionScope) error {
+	assignments, err := r.client.GetResourcesAccessibleToEntityByID(entityId, entityType, permissionResourceTypes)
+	if err != nil {
+		return fmt.Errorf("failed to get access assignments for %v %v: %s", entityType, entityName, err)
+	}
+
+	for _, a := range assignments {
+		if !scope.includes(a) {
+			continue
+		}
+		source := fmt.Sprintf("%v %v on %v %v", entityType, entityName, a.ResourceType, a.ResourceName)
+		for _, ar := range a.EntityRoles {
+			role, err := r.getAssignedRole(ar)
+			if err != nil {
+				return err
+			}
+			if err = r.addRoleGrants(perms, role, PermissionViaAssignment, source, []string{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// adds a grant for the role and recursively for each of its composites
+func (r *PermissionResolver) addRoleGrants(perms *EffectivePermissions, role Role, via, source string, path []string) error {
+	path = append(append([]string{}, path...), role.Name)
+	perms.Grants = append(perms.Grants, PermissionGrant{
+		Permission: role.Name,
+		Via:        via,
+		Source:     source,
+		RolePath:   path,
+	})
+
+	if !role.Composite {
+		return nil
+	}
+
+	composites, err := r.getComposites(role)
+	if err != nil {
+		return err
+	}
+	for _, sub := range composites {
+		if slices.Contains(path, sub.Name) {
+			continue
+		}
+		if err = r.addRoleGrants(perms, sub, via, source, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PermissionResolver) getComposites(role Role) ([]Role, error) {
+	r.mutex.Lock()
+	composites, ok := r.composites[role.RoleID]
+	r.mutex.Unlock()
+	if ok {
+		return composites, nil
+	}
+
+	composites, err := r.client.GetRoleComposites(&role)
+	if err != nil {
+		return composites, fmt.Errorf("failed to get composites of role %v: %s", role.String(), err)
+	}
+
+	r.mutex.Lock()
+	r.composites[role.RoleID] = composites
+	r.mutex.Unlock()
+	return composites, nil
+}
+
+func (r *PermissionResolver) getRoleByName(clientName, roleName string) (Role, error) {
+	key := clientName + "/" + roleName
+	r.mutex.Lock()
+	role, ok := r.roles[key]
+	r.mutex.Unlock()
+	if ok {
+		return role, nil
+	}
+
+	clientId, err := r.getClientID(clientName)
+	if err != nil {
+		return role, err
+	}
+	role, err = r.client.GetRoleByClientIDAndName(clientId, roleName)
+	if err != nil {
+		return role, fmt.Errorf("failed to get role %v of client %v: %s", roleName, clientName, err)
+	}
+
+	r.mutex.Lock()
+	r.roles[key] = role
+	r.mutex.Unlock()
+	return role, nil
+}
+
+func (r *PermissionResolver) getAssignedRole(ar AccessAssignedRole) (Role, error) {
+	if ar.Id == "" {
+		return r.getRoleByName("ast-app", ar.Name)
+	}
+
+	key := "id/" + ar.Id
+	r.mutex.Lock()
+	role, ok := r.roles[key]
+	r.mutex.Unlock()
+	if ok {
+		return role, nil
+	}
+
+	role, err := r.client.GetRoleByID(ar.Id)
+	if err != nil {
+		return role, fmt.Errorf("failed to get role %v (%v): %s", ar.Name, ar.Id, err)
+	}
+
+	r.mutex.Lock()
+	r.roles[key] = role
+	r.mutex.Unlock()
+	return role, nil
+}
+
+func (r *PermissionResolver) getClientID(clientName string) (string, error) {
+	if clientName == "ast-app" {
+		return r.client.GetASTAppID(), nil
+	}
+
+	r.mutex.Lock()
+	id, ok := r.clientIDs[clientName]
+	r.mutex.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	client, err := r.client.GetClientByName(clientName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get client %v: %s", clientName, err)
+	}
+
+	r.mutex.Lock()
+	r.clientIDs[clientName] = client.ID
+	r.mutex.Unlock()
+	return client.ID, nil
+}
+
+// returns the group (including its roles) followed by its parents
+func (r *PermissionResolver) getAncestors(group Group) ([]Group, error) {
+	r.mutex.Lock()
+	ancestors, ok := r.ancestors[group.GroupID]
+	r.mutex.Unlock()
+	if ok {
+		return ancestors, nil
+	}
+
+	full, err := r.getGroup(group.GroupID)
+	if err != nil {
+		return ancestors, err
+	}
+	ancestors = []Group{full}
+
+	var parent Group
+	if full.ParentID != "" {
+		parent, err = r.getGroup(full.ParentID)
+	} else if i := strings.LastIndex(full.Path, "/"); i > 0 {
+		parent, err = r.getGroupByPath(full.Path[:i])
+	} else {
+		parent.GroupID = ""
+	}
+	if err != nil {
+		return ancestors, err
+	}
+
+	if parent.GroupID != "" {
+		parentAncestors, err := r.getAncestors(parent)
+		if err != nil {
+			return ancestors, err
+		}
+		ancestors = append(ancestors, parentAncestors...)
+	}
+
+	r.mutex.Lock()
+	r.ancestors[group.GroupID] = ancestors
+	r.mutex.Unlock()
+	return ancestors, nil
+}
+
+func (r *PermissionResolver) getGroup(groupId string) (Group, error) {
+	r.mutex.Lock()
+	group, ok := r.groups[groupId]
+	r.mutex.Unlock()
+	if ok {
+		return group, nil
+	}
+
+	group, err := r.client.GetGroupByID(groupId)
+	if err != nil {
+		return group, fmt.Errorf("failed to get group %v: %s", groupId, err)
+	}
+
+	r.mutex.Lock()
+	r.groups[groupId] = group
+	r.groupsByPath[group.Path] = group
+	r.mutex.Unlock()
+	return group, nil
+}
+
+func (r *PermissionResolver) getGroupByPath(path string) (Group, error) {
+	r.mutex.Lock()
+	group, ok := r.groupsByPath[path]
+	r.mutex.Unlock()
+	if ok {
+		return group, nil
+	}
+
+	group, err := r.client.GetGroupByPath(path)
+	if err != nil {
+		return group, fmt.Errorf("failed to get group %v: %s", path, err)
+	}
+
+	r.mutex.Lock()
+	r.groups[group.GroupID] = group
+	r.groupsByPath[path] = group
+	r.mutex.Unlock()
+	return group, nil
+}
+
+// returns the IDs of the groups with an access assignment to the application
+func (r *PermissionResolver) getApplicationGroups(applicationId string) ([]string, error) {
+	r.mutex.Lock()
+	groupIds, ok := r.applicationGroups[applicationId]
+	r.mutex.Unlock()
+	if ok {
+		return groupIds, nil
+	}
+
+	assignments, err := r.client.GetEntitiesAccessToResourceByID(applicationId, "application")
+	if err != nil {
+		return groupIds, fmt.Errorf("failed to get access assignments for application %v: %s", applicationId, err)
+	}
+	groupIds = []string{}
+	for _, a := range assignments {
+		if a.EntityType == "group" {
+			groupIds = append(groupIds, a.EntityID)
+		}
+	}
+
+	r.mutex.Lock()
+	r.applicationGroups[applicationId] = groupIds
+	r.mutex.Unlock()
+	return groupIds, nil
+}
+
+func (r *PermissionResolver) getProject(projectId string) (Project, error) {
+	r.mutex.Lock()
+	project, ok := r.projects[projectId]
+	r.mutex.Unlock()
+	if ok {
+		return project, nil
+	}
+
+	project, err := r.client.GetProjectByID(projectId)
+	if err != nil {
+		return project, fmt.Errorf("failed to get project %v: %s", projectId, err)
+	}
+
+	r.mutex.Lock()
+	r.projects[projectId] = project
+	r.mutex.Unlock()
+	return project, nil
+}
+
+// Returns true if the permission (or role) was granted by any source
+func (p EffectivePermissions) Has(permission string) bool {
+	for _, g := range p.Grants {
+		if g.Permission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the sorted list of distinct permissions and roles held
+func (p EffectivePermissions) GetPermissions() []string {
+	permissions := []string{}
+	for _, g := range p.Grants {
+		if !slices.Contains(permissions, g.Permission) {
+			permissions = append(permissions, g.Permission)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Returns all grants for the permission, each describing one way it was granted
+func (p EffectivePermissions) Explain(permission string) []PermissionGrant {
+	grants := []PermissionGrant{}
+	for _, g := range p.Grants {
+		if g.Permission == permission {
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+func (p EffectivePermissions) String() string {
+	return fmt.Sprintf("%v on %v %v: %d permissions", p.EntityName, p.ResourceType, p.ResourceID, len(p.GetPermissions()))
+}
+
+func (g PermissionGrant) String() string {
+	return fmt.Sprintf("%v via %v %v (%v)", g.Permission, g.Via, strings.Join(g.RolePath, " -> "), g.Source)
+}
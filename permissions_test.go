@@ -0,0 +1,67 @@
+package Cx1ClientGo
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestGroupsWithAssignedRoles(t *testing.T) {
+	// chain for a user in /parent/child/grandchild, as returned by getAncestors
+	chain := []Group{
+		{GroupID: "grandchild", Path: "/parent/child/grandchild"},
+		{GroupID: "child", Path: "/parent/child"},
+		{GroupID: "parent", Path: "/parent"},
+	}
+
+	tests := []struct {
+		name     string
+		groupIds []string
+		expected []string
+	}{
+		{"not assigned", []string{"other"}, []string{}},
+		{"no groups", nil, []string{}},
+		{"member group assigned inherits all parents", []string{"grandchild"}, []string{"grandchild", "child", "parent"}},
+		{"middle group assigned", []string{"child"}, []string{"child", "parent"}},
+		{"top group assigned does not grant child roles", []string{"parent"}, []string{"parent"}},
+		{"several assigned", []string{"parent", "child"}, []string{"child", "parent"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids := []string{}
+			for _, g := range groupsWithAssignedRoles(chain, test.groupIds) {
+				ids = append(ids, g.GroupID)
+			}
+			if !slices.Equal(ids, test.expected) {
+				t.Errorf("expected roles of %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestPermissionScopeIncludes(t *testing.T) {
+	project := permissionScope{resourceType: "project", resourceId: "p1", applicationIds: []string{"a1"}}
+	application := permissionScope{resourceType: "application", resourceId: "a1"}
+
+	tests := []struct {
+		name       string
+		scope      permissionScope
+		assignment AccessAssignment
+		expected   bool
+	}{
+		{"tenant on project", project, AccessAssignment{ResourceType: "tenant"}, true},
+		{"containing application on project", project, AccessAssignment{ResourceType: "application", ResourceID: "a1"}, true},
+		{"other application on project", project, AccessAssignment{ResourceType: "application", ResourceID: "a2"}, false},
+		{"same project", project, AccessAssignment{ResourceType: "project", ResourceID: "p1"}, true},
+		{"other project", project, AccessAssignment{ResourceType: "project", ResourceID: "p2"}, false},
+		{"same application", application, AccessAssignment{ResourceType: "application", ResourceID: "a1"}, true},
+		{"project on application", application, AccessAssignment{ResourceType: "project", ResourceID: "p1"}, false},
+	}
+
+	for _, test := range tests {
+		if included := test.scope.includes(test.assignment); included != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, included)
+		}
+	}
+}
@@ -152,13 +152,20 @@ func (c Cx1Client) sendRequestRaw(method, url string, body io.Reader, header htt
 		return nil, err
 	}
 
-	return c.handleHTTPResponse(request)
+	response, err := c.handleHTTPResponse(request)
+	c.invalidateCache(method, url)
+	return response, err
 }
 
-func (c Cx1Client) handleHTTPResponse(request *http.Request) (*http.Response, error) {
-	response, err := c.httpClient.Do(request)
+func (c Cx1Client) handleHTTPResponse(request *http.Request) (response *http.Response, err error) {
+	start := time.Now()
+	retries := 0
+	span := c.startRequestSpan(request)
+	defer func() { c.endRequestSpan(span, request, response, retries, err, start) }()
+
+	response, err = c.httpClient.Do(request)
 	if err != nil {
-		response, err = c.handleRetries(request, response, err)
+		response, retries, err = c.handleRetries(request, response, err)
 	}
 
 	if err != nil {
@@ -208,10 +215,11 @@ func (c Cx1Client) handleHTTPResponse(request *http.Request) (*http.Response, er
 	return response, nil
 }
 
-func (c Cx1Client) handleRetries(request *http.Request, response *http.Response, err error) (*http.Response, error) {
+// returns the response of the last attempt and the number of retries
+func (c Cx1Client) handleRetries(request *http.Request, response *http.Response, err error) (*http.Response, int, error) {
 	if err == nil || (strings.Contains(err.Error(), "tls: user canceled") && request.Method == http.MethodGet) { // tls: user canceled can be due to proxies
 		c.logger.Warnf("Potentially benign error from HTTP connection: %s", err)
-		return response, nil
+		return response, 0, nil
 	}
 
 	delay := c.retryDelay
@@ -225,7 +233,7 @@ func (c Cx1Client) handleRetries(request *http.Request, response *http.Response,
 		delay *= 2
 	}
 
-	return response, err
+	return response, attempt - 1, err
 }
 
 func isRetryableError(err error) bool {
@@ -0,0 +1,309 @@
+package Cx1ClientGo
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Scan preflight validation: checks the parameters for ScanProjectZipByID/ScanProjectGitByID before the scan is
+	submitted, so that problems that would otherwise only show up as a failed scan are all reported at once:
+	- each engine in the scan configuration is known and allowed by the license (IsEngineAllowed)
+	- presets (scan-level, or the project's configured preset) exist (GetPresetByName)
+	- scan configuration keys exist in the project configuration and values match the ValueType/ValueTypeParams
+	- the branch name is valid, and whether it is new to the project
+	- the repository or upload URL is well-formed
+
+	Errors are problems which will prevent the scan from running, warnings are for things that may not be intended.
+*/
+
+var scanPreflightSCPLikeURL = regexp.MustCompile(`^[\w.\-]+@[\w.\-]+:[^/].*$`)
+
+// Returns a preflight check result for a zip scan. The error is non-nil if any problems were found.
+func (c Cx1Client) PreflightScanProjectZipByID(projectID, sourceUrl, branch string, settings []ScanConfiguration) (ScanPreflightResult, error) {
+	result := c.preflightScan(projectID, "upload", branch, settings)
+
+	if sourceUrl == "" {
+		result.addError("repository", sourceUrl, "no upload URL provided, use UploadBytes to get one")
+	} else if u, err := url.Parse(sourceUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		result.addError("repository", sourceUrl, "upload URL is not a valid http(s) URL")
+	}
+
+	return result, result.Err()
+}
+
+// Returns a preflight check result for a git scan. The error is non-nil if any problems were found.
+func (c Cx1Client) PreflightScanProjectGitByID(projectID, repoUrl, branch string, settings []ScanConfiguration) (ScanPreflightResult, error) {
+	result := c.preflightScan(projectID, "git", branch, settings)
+
+	if branch == "" {
+		result.addError("branch", branch, "a branch is required for git scans")
+	}
+
+	if repoUrl == "" {
+		result.addError("repository", repoUrl, "no repository URL provided")
+	} else if !scanPreflightSCPLikeURL.MatchString(repoUrl) {
+		u, err := url.Parse(repoUrl)
+		if err != nil {
+			result.addError("repository", repoUrl, fmt.Sprintf("repository URL is not valid: %s", err))
+		} else if u.Host == "" || !slices.Contains([]string{"http", "https", "ssh", "git"}, strings.ToLower(u.Scheme)) {
+			result.addError("repository", repoUrl, "repository URL must be http(s), ssh, git or user@host:path")
+		} else if u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				result.addWarning("repository", u.Redacted(), "repository URL contains credentials, use a ScanHandler with Credentials instead")
+			}
+		}
+	}
+
+	return result, result.Err()
+}
+
+// convenience function matching ScanProjectByID
+func (c Cx1Client) PreflightScanProjectByID(projectID, sourceUrl, branch, scanType string, settings []ScanConfiguration) (ScanPreflightResult, error) {
+	if scanType == "upload" {
+		return c.PreflightScanProjectZipByID(projectID, sourceUrl, branch, settings)
+	} else if scanType == "git" {
+		return c.PreflightScanProjectGitByID(projectID, sourceUrl, branch, settings)
+	}
+
+	return ScanPreflightResult{}, fmt.Errorf("invalid scanType provided, must be 'upload' or 'git'")
+}
+
+func (c Cx1Client) preflightScan(projectID, scanType, branch string, settings []ScanConfiguration) ScanPreflightResult {
+	result := ScanPreflightResult{
+		ProjectID: projectID,
+		ScanType:  scanType,
+		Engines:   []string{},
+		Errors:    []ScanPreflightIssue{},
+		Warnings:  []ScanPreflightIssue{},
+	}
+	c.logger.Debugf("Running %v scan preflight checks for project %v", scanType, projectID)
+
+	if _, err := c.GetProjectByID(projectID); err != nil {
+		result.addError("project", projectID, fmt.Sprintf("project not found: %s", err))
+		return result
+	}
+
+	config, err := c.GetProjectConfigurationByID(projectID)
+	if err != nil {
+		result.addWarning("project", projectID, fmt.Sprintf("unable to get project configuration, scan configuration will not be validated: %s", err))
+		config = nil
+	}
+
+	if len(settings) == 0 {
+		result.addError("engine", "", "no engines configured for the scan")
+	}
+
+	for _, setting := range settings {
+		engine := strings.ToLower(setting.ScanType)
+		if slices.Contains(result.Engines, engine) {
+			result.addWarning("engine", setting.ScanType, "engine is configured more than once, later values may override earlier ones")
+		} else {
+			result.Engines = append(result.Engines, engine)
+		}
+		c.preflightEngine(&result, engine)
+
+		if config == nil {
+			continue
+		}
+		for key, value := range setting.Values {
+			configKey := fmt.Sprintf("scan.config.%v.%v", engine, key)
+			c.preflightConfigValue(&result, &config, configKey, value)
+		}
+	}
+
+	if config != nil {
+		c.preflightPresets(&result, &config, settings)
+	}
+
+	c.preflightBranch(&result, projectID, branch)
+
+	for _, issue := range result.Errors {
+		c.logger.Debugf("Preflight error for %v scan of project %v: %v", scanType, projectID, issue.String())
+	}
+	return result
+}
+
+func (c Cx1Client) preflightEngine(result *ScanPreflightResult, engine string) {
+	if _, ok := scanEngineLicenseMap[engine]; !ok {
+		result.addWarning("engine", engine, "unknown engine, the license can't be checked")
+		return
+	}
+	if _, allowed := c.IsEngineAllowed(engine); !allowed {
+		result.addError("engine", engine, fmt.Sprintf("engine is not allowed by the %v license", c.GetLicense().PackageName))
+	}
+}
+
+func (c Cx1Client) preflightConfigValue(result *ScanPreflightResult, config *[]ConfigurationSetting, key, value string) {
+	setting := c.GetConfigurationByName(config, key)
+	if setting == nil {
+		result.addError(key, value, "unknown configuration key")
+		return
+	}
+
+	if !setting.AllowOverride && setting.Value != value {
+		result.addWarning(key, value, fmt.Sprintf("the %v-level value %v does not allow override", setting.OriginLevel, setting.Value))
+	}
+
+	if err := validateConfigurationValue(setting, value); err != nil {
+		result.addError(key, value, err.Error())
+	}
+}
+
+// Returns an error if the value does not match the setting's ValueType/ValueTypeParams
+func validateConfigurationValue(setting *ConfigurationSetting, value string) error {
+	params := []string{}
+	for _, p := range strings.Split(setting.ValueTypeParams, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			params = append(params, p)
+		}
+	}
+
+	switch strings.ToLower(setting.ValueType) {
+	case "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("value must be true or false")
+		}
+	case "number", "integer", "int":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("value must be a number")
+		}
+	case "list":
+		if len(params) > 0 && value != "" && !slices.Contains(params, value) {
+			return fmt.Errorf("value must be one of: %v", strings.Join(params, ", "))
+		}
+	case "multilist":
+		if len(params) == 0 || value == "" {
+			return nil
+		}
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); !slices.Contains(params, v) {
+				return fmt.Errorf("value %v must be one of: %v", v, strings.Join(params, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func (c Cx1Client) preflightPresets(result *ScanPreflightResult, config *[]ConfigurationSetting, settings []ScanConfiguration) {
+	for _, engine := range result.Engines {
+		presetEngine := engine
+		if engine == "kics" {
+			presetEngine = "iac"
+		}
+		if presetEngine != "sast" && presetEngine != "iac" {
+			continue
+		}
+
+		key := fmt.Sprintf("scan.config.%v.presetName", engine)
+		presetName := ""
+		scanLevel := false
+		for _, s := range settings {
+			if strings.EqualFold(s.ScanType, engine) {
+				if name, ok := s.Values["presetName"]; ok {
+					presetName = name
+					scanLevel = true
+				}
+			}
+		}
+		if !scanLevel {
+			if setting := c.GetConfigurationByName(config, key); setting != nil {
+				presetName = setting.Value
+			}
+		}
+
+		if presetName == "" {
+			if engine == "sast" {
+				result.addError(key, presetName, "no preset is configured for the project or the scan")
+			}
+			continue
+		}
+
+		if _, err := c.GetPresetByName(presetEngine, presetName); err != nil {
+			if scanLevel {
+				result.addError(key, presetName, fmt.Sprintf("preset not found: %s", err))
+			} else {
+				result.addError(key, presetName, fmt.Sprintf("project preset not found: %s", err))
+			}
+		}
+	}
+}
+
+func (c Cx1Client) preflightBranch(result *ScanPreflightResult, projectID, branch string) {
+	if branch == "" {
+		return
+	}
+	if err := validateBranchName(branch); err != nil {
+		result.addError("branch", branch, err.Error())
+		return
+	}
+
+	branches, err := c.GetProjectBranchesFiltered(ProjectBranchFilter{
+		BaseFilter: BaseFilter{Limit: c.pagination.Branches},
+		ProjectID:  projectID,
+		Name:       branch,
+	})
+	if err != nil {
+		result.addWarning("branch", branch, fmt.Sprintf("unable to check existing branches: %s", err))
+	} else if !slices.Contains(branches, branch) {
+		result.addWarning("branch", branch, "branch has not been scanned before in this project, the scan will not be incremental")
+	}
+}
+
+// Returns an error if the name is not a valid git branch name (a subset of the git check-ref-format rules)
+func validateBranchName(branch string) error {
+	if strings.TrimSpace(branch) != branch {
+		return fmt.Errorf("branch name has leading or trailing whitespace")
+	}
+	if strings.HasPrefix(branch, "refs/") {
+		return fmt.Errorf("branch should be a name, not a ref")
+	}
+	if strings.HasPrefix(branch, "-") || strings.HasPrefix(branch, "/") || strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".") || strings.HasSuffix(branch, ".lock") {
+		return fmt.Errorf("branch name has an invalid start or end")
+	}
+	if strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.Contains(branch, "@{") || branch == "@" {
+		return fmt.Errorf("branch name contains an invalid sequence")
+	}
+	for _, r := range branch {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("branch name contains invalid character %q", r)
+		}
+	}
+	return nil
+}
+
+func (r *ScanPreflightResult) addError(field, value, message string) {
+	r.Errors = append(r.Errors, ScanPreflightIssue{Field: field, Value: value, Message: message})
+}
+
+func (r *ScanPreflightResult) addWarning(field, value, message string) {
+	r.Warnings = append(r.Warnings, ScanPreflightIssue{Field: field, Value: value, Message: message})
+}
+
+// Returns an error listing all preflight errors, or nil if there were none
+func (r ScanPreflightResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	messages := make([]string, len(r.Errors))
+	for id, issue := range r.Errors {
+		messages[id] = issue.String()
+	}
+	return fmt.Errorf("%d preflight errors for %v scan of project %v: %v", len(r.Errors), r.ScanType, r.ProjectID, strings.Join(messages, "; "))
+}
+
+func (r ScanPreflightResult) OK() bool {
+	return len(r.Errors) == 0
+}
+
+func (i ScanPreflightIssue) String() string {
+	if i.Value == "" {
+		return fmt.Sprintf("%v: %v", i.Field, i.Message)
+	}
+	return fmt.Sprintf("%v [%v]: %v", i.Field, i.Value, i.Message)
+}
@@ -0,0 +1,82 @@
+package Cx1ClientGo
+
+import "testing"
+
+func TestValidateBranchName(t *testing.T) {
+	tests := []struct {
+		branch string
+		valid  bool
+	}{
+		{branch: "main", valid: true},
+		{branch: "feature/login-page", valid: true},
+		{branch: "release-1.2.3", valid: true},
+		{branch: "user@host", valid: true},
+		{branch: " main", valid: false},
+		{branch: "main ", valid: false},
+		{branch: "refs/heads/main", valid: false},
+		{branch: "-main", valid: false},
+		{branch: "/main", valid: false},
+		{branch: "main/", valid: false},
+		{branch: "main.", valid: false},
+		{branch: "main.lock", valid: false},
+		{branch: "feature..x", valid: false},
+		{branch: "feature//x", valid: false},
+		{branch: "main@{1}", valid: false},
+		{branch: "@", valid: false},
+		{branch: "my branch", valid: false},
+		{branch: "main~1", valid: false},
+		{branch: "main^", valid: false},
+		{branch: "a:b", valid: false},
+		{branch: "what?", valid: false},
+		{branch: "feat*", valid: false},
+		{branch: "feat[1]", valid: false},
+		{branch: "back\\slash", valid: false},
+		{branch: "tab\there", valid: false},
+		{branch: "del\x7f", valid: false},
+	}
+
+	for _, test := range tests {
+		err := validateBranchName(test.branch)
+		if test.valid && err != nil {
+			t.Errorf("validateBranchName(%q): unexpected error %s", test.branch, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("validateBranchName(%q): expected an error", test.branch)
+		}
+	}
+}
+
+func TestValidateConfigurationValue(t *testing.T) {
+	tests := []struct {
+		valueType string
+		params    string
+		value     string
+		valid     bool
+	}{
+		{valueType: "Bool", value: "true", valid: true},
+		{valueType: "bool", value: "false", valid: true},
+		{valueType: "bool", value: "yes", valid: false},
+		{valueType: "Number", value: "42", valid: true},
+		{valueType: "integer", value: "-1", valid: true},
+		{valueType: "int", value: "4.2", valid: false},
+		{valueType: "number", value: "", valid: false},
+		{valueType: "List", params: "Fast, Accurate", value: "Fast", valid: true},
+		{valueType: "list", params: "Fast, Accurate", value: "", valid: true},
+		{valueType: "list", params: "Fast,Accurate", value: "fast", valid: false},
+		{valueType: "list", params: "", value: "anything", valid: true},
+		{valueType: "MultiList", params: "Java,CSharp,Go", value: "Java, Go", valid: true},
+		{valueType: "multilist", params: "Java,CSharp,Go", value: "Java,Python", valid: false},
+		{valueType: "multilist", params: "Java,CSharp,Go", value: "", valid: true},
+		{valueType: "multilist", params: "", value: "Python", valid: true},
+		{valueType: "String", value: "anything", valid: true},
+	}
+
+	for _, test := range tests {
+		setting := ConfigurationSetting{ValueType: test.valueType, ValueTypeParams: test.params}
+		err := validateConfigurationValue(&setting, test.value)
+		if test.valid && err != nil {
+			t.Errorf("validateConfigurationValue(%v [%v], %q): unexpected error %s", test.valueType, test.params, test.value, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("validateConfigurationValue(%v [%v], %q): expected an error", test.valueType, test.params, test.value)
+		}
+	}
+}
@@ -161,7 +161,7 @@ func (c Cx1Client) GetPresetByName(engine, name string) (Preset, error) {
 		"search-term":     {name},
 	}
 
-	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/preset-manager/%v/presets?%v", engine, params.Encode()), nil, nil)
+	response, err := c.sendCachedRequest(fmt.Sprintf("/preset-manager/%v/presets?%v", engine, params.Encode()))
 	if err != nil {
 		return Preset{}, err
 	}
@@ -270,7 +270,7 @@ func (c Cx1Client) GetSASTQueryFamilies() ([]string, error) {
 }
 func (c Cx1Client) GetQueryFamilies(engine string) ([]string, error) {
 	var families []string
-	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/preset-manager/%v/query-families", engine), nil, nil)
+	response, err := c.sendCachedRequest(fmt.Sprintf("/preset-manager/%v/query-families", engine))
 	if err != nil {
 		return families, err
 	}
@@ -303,7 +303,7 @@ func (c Cx1Client) GetSASTQueryFamilyContents(family string) (SASTQueryCollectio
 }
 func (c Cx1Client) getQueryFamilyContents(engine, family string) ([]AuditQueryTree, error) {
 	var families []AuditQueryTree
-	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/preset-manager/%v/query-families/%v/queries", engine, family), nil, nil)
+	response, err := c.sendCachedRequest(fmt.Sprintf("/preset-manager/%v/query-families/%v/queries", engine, family))
 	if err != nil {
 		return families, err
 	}
@@ -69,7 +69,7 @@ func (c Cx1Client) GetPresetByName_v330(name string) (Preset_v330, error) {
 		"name":            {name},
 	}
 
-	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/presets?%v", params.Encode()), nil, nil)
+	response, err := c.sendCachedRequest(fmt.Sprintf("/presets?%v", params.Encode()))
 	if err != nil {
 		return Preset_v330{}, err
 	}
@@ -244,7 +244,7 @@ func (c Cx1Client) GetPresetQueries_v330() (SASTQueryCollection, error) {
 	queries := []SASTQuery{}
 
 	collection := SASTQueryCollection{}
-	response, err := c.sendRequest(http.MethodGet, "/presets/queries", nil, nil)
+	response, err := c.sendCachedRequest("/presets/queries")
 	if err != nil {
 		return collection, err
 	}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/google/go-querystring/query"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/slices"
 )
 
@@ -136,6 +137,7 @@ func (c Cx1Client) ProjectInApplicationPollingByIDWithTimeout(projectId, applica
 		}
 		c.logger.Debugf("Project is not yet assigned to the application, polling")
 		time.Sleep(time.Duration(delaySeconds) * time.Second)
+		c.dropCacheEntries(fmt.Sprintf("/api/projects/%v", projectId))
 		project, err = c.GetProjectByID(projectId)
 		pollingCounter += delaySeconds
 	}
@@ -168,7 +170,7 @@ func (c Cx1Client) GetProjectByID(projectID string) (Project, error) {
 	c.logger.Debugf("Getting Project with ID %v...", projectID)
 	var project Project
 
-	data, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/projects/%v", projectID), nil, nil)
+	data, err := c.sendCachedRequest(fmt.Sprintf("/projects/%v", projectID))
 	if err != nil {
 		return project, fmt.Errorf("failed to fetch project %v: %s", projectID, err)
 	}
@@ -258,13 +260,17 @@ func (c Cx1Client) GetProjectsFiltered(filter ProjectFilter) (uint64, []Project,
 
 // Retrieves all projects matching the filter
 func (c Cx1Client) GetAllProjectsFiltered(filter ProjectFilter) (uint64, []Project, error) {
+	c, span := c.startOperation("GetAllProjectsFiltered")
 	var projects []Project
 
 	count, err := c.GetProjectCountFiltered(filter)
 	if err != nil {
+		endOperation(span, err)
 		return count, projects, err
 	}
 	_, projects, err = c.GetXProjectsFiltered(filter, count)
+	span.SetAttributes(attribute.Int64("cx1.project.count", int64(count)))
+	endOperation(span, err)
 	return count, projects, err
 }
 
@@ -335,7 +341,7 @@ func (c Cx1Client) GetProjectConfigurationByID(projectID string) ([]Configuratio
 	params := url.Values{
 		"project-id": {projectID},
 	}
-	data, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/configuration/project?%v", params.Encode()), nil, nil)
+	data, err := c.sendCachedRequest(fmt.Sprintf("/configuration/project?%v", params.Encode()))
 
 	if err != nil {
 		c.logger.Tracef("Failed to get project configuration for project ID %v: %s", projectID, err)
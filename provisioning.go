@@ -0,0 +1,629 @@
+package Cx1ClientGo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	User lifecycle provisioning from a source of truth (CSV, JSON or a SCIM 2.0 service).
+	PlanProvisioning compares the source users with the Cx1 users and returns the changes needed:
+	- joiners are created (as SAML/OIDC-federated users if the IdP alias is provided) and added to their groups
+	- movers have their details, enabled state and group memberships updated
+	- leavers (Cx1 users missing from the source) are disabled, or deleted if DeleteUsers is set
+	Only memberships of managed groups are removed, so that groups maintained by hand are not affected.
+	Likewise only members of the managed groups, or users linked to the configured IdP, can be leavers. The tenant
+	owner and the calling user are never changed, and planning fails if more than MaxLeaverPercent of the Cx1 users
+	would be leavers unless AllowMassLeavers is set - an empty or truncated source must not disable the tenant.
+	ApplyProvisioning makes the changes and writes one audit record per change, a dry-run writes the records without applying.
+*/
+
+const scimContentType = "application/scim+json"
+
+const provisioningDefaultMaxLeaverPercent = 10.0
+
+const (
+	ProvisioningCreate      = "create"
+	ProvisioningUpdate      = "update"
+	ProvisioningEnable      = "enable"
+	ProvisioningDisable     = "disable"
+	ProvisioningDelete      = "delete"
+	ProvisioningAddGroup    = "add-group"
+	ProvisioningRemoveGroup = "remove-group"
+)
+
+// Gets the users from the source, plans the changes and applies them (or only records them, for a dry-run)
+func (c Cx1Client) Provision(source ProvisioningSource, options ProvisioningOptions) ([]ProvisioningChange, error) {
+	c.logger.Infof("Provisioning users from %v", source.String())
+	users, err := source.GetUsers()
+	if err != nil {
+		return []ProvisioningChange{}, fmt.Errorf("failed to get users from %v: %s", source.String(), err)
+	}
+
+	changes, err := c.PlanProvisioning(users, options)
+	if err != nil {
+		return changes, err
+	}
+	return c.ApplyProvisioning(changes, options)
+}
+
+// Returns the changes needed to bring Cx1 in line with the source users, nothing is changed
+func (c Cx1Client) PlanProvisioning(users []ProvisioningUser, options ProvisioningOptions) ([]ProvisioningChange, error) {
+	changes := []ProvisioningChange{}
+
+	source := make(map[string]*ProvisioningUser)
+	for id := range users {
+		u := &users[id]
+		key := strings.ToLower(strings.TrimSpace(u.UserName))
+		if key == "" {
+			return changes, fmt.Errorf("source user %d has no username", id)
+		}
+		if _, ok := source[key]; ok {
+			return changes, fmt.Errorf("source contains user %v more than once", u.UserName)
+		}
+		source[key] = u
+	}
+
+	groups := make(map[string]*Group)
+	for _, u := range users {
+		for _, ref := range u.Groups {
+			if _, ok := groups[ref]; ok {
+				continue
+			}
+			group, err := c.getProvisioningGroup(ref)
+			if err != nil {
+				c.logger.Warnf("Provisioning source group %v not found: %s", ref, err)
+				groups[ref] = nil
+			} else {
+				groups[ref] = &group
+			}
+		}
+	}
+
+	managed := []string{}
+	for _, path := range options.ManagedGroups {
+		managed = append(managed, "/"+strings.TrimPrefix(path, "/"))
+	}
+	if len(options.ManagedGroups) == 0 {
+		for _, g := range groups {
+			if g != nil {
+				managed = append(managed, g.Path)
+			}
+		}
+	}
+
+	ignored := []string{}
+	for _, name := range options.IgnoreUsers {
+		ignored = append(ignored, strings.ToLower(name))
+	}
+	if owner, err := c.GetTenantOwner(); err != nil {
+		c.logger.Warnf("Failed to get tenant owner, it will not be excluded from provisioning: %s", err)
+	} else {
+		ignored = append(ignored, strings.ToLower(owner.Username))
+	}
+	ignored = append(ignored, c.provisioningCaller())
+
+	existing, err := c.GetAllUsers()
+	if err != nil {
+		return changes, fmt.Errorf("failed to get users: %s", err)
+	}
+	current := make(map[string]*User)
+	for id := range existing {
+		current[strings.ToLower(existing[id].UserName)] = &existing[id]
+	}
+
+	keys := make([]string, 0, len(source))
+	for key := range source {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if slices.Contains(ignored, key) {
+			continue
+		}
+		su := source[key]
+		user, ok := current[key]
+		if !ok {
+			changes = append(changes, ProvisioningChange{Action: ProvisioningCreate, UserName: su.UserName, source: su, Details: su.describe()})
+			for _, ref := range su.Groups {
+				changes = append(changes, newProvisioningGroupChange(ProvisioningAddGroup, su.UserName, nil, ref, groups[ref]))
+			}
+			continue
+		}
+
+		userChanges, err := c.planProvisioningUser(user, su, groups, managed)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, userChanges...)
+	}
+
+	inScope, err := c.getProvisioningScope(managed, options.IdPAlias)
+	if err != nil {
+		return changes, err
+	}
+
+	leavers := []string{}
+	for key := range current {
+		if _, ok := source[key]; !ok && inScope[key] && !slices.Contains(ignored, key) {
+			leavers = append(leavers, key)
+		}
+	}
+	sort.Strings(leavers)
+
+	if !options.AllowMassLeavers && len(leavers) > 0 {
+		limit := options.MaxLeaverPercent
+		if limit <= 0 {
+			limit = provisioningDefaultMaxLeaverPercent
+		}
+		if percent := 100 * float64(len(leavers)) / float64(len(existing)); percent > limit {
+			return changes, fmt.Errorf("%d of %d users (%.1f%%) would be leavers, more than the %.1f%% limit - check the source or set AllowMassLeavers", len(leavers), len(existing), percent, limit)
+		}
+	}
+
+	for _, key := range leavers {
+		user := current[key]
+		if options.DeleteUsers {
+			changes = append(changes, ProvisioningChange{Action: ProvisioningDelete, UserName: user.UserName, UserID: user.UserID, user: user})
+		} else if user.Enabled {
+			changes = append(changes, ProvisioningChange{Action: ProvisioningDisable, UserName: user.UserName, UserID: user.UserID, user: user, Details: []string{"missing from source"}})
+		}
+	}
+
+	c.logger.Infof("Planned %d provisioning changes for %d source users and %d Cx1 users", len(changes), len(users), len(existing))
+	return changes, nil
+}
+
+// returns the usernames (lower-case) of the members of the managed groups and of the users linked to the IdP
+func (c Cx1Client) getProvisioningScope(managed []string, idpAlias string) (map[string]bool, error) {
+	scope := make(map[string]bool)
+
+	for _, path := range managed {
+		group, err := c.GetGroupByPath(path)
+		if err != nil {
+			c.logger.Warnf("Managed group %v not found: %s", path, err)
+			continue
+		}
+		members, err := c.GetGroupMembers(&group)
+		if err != nil {
+			return scope, fmt.Errorf("failed to get members of group %v: %s", path, err)
+		}
+		for _, u := range members {
+			scope[strings.ToLower(u.UserName)] = true
+		}
+	}
+
+	if idpAlias != "" {
+		_, users, err := c.GetAllUsersFiltered(UserFilter{
+			BaseIAMFilter:       BaseIAMFilter{Max: c.pagination.Users},
+			BriefRepresentation: true,
+			IDPAlias:            idpAlias,
+		})
+		if err != nil {
+			return scope, fmt.Errorf("failed to get users linked to identity provider %v: %s", idpAlias, err)
+		}
+		for _, u := range users {
+			scope[strings.ToLower(u.UserName)] = true
+		}
+	}
+
+	return scope, nil
+}
+
+// the username (lower-case) of the user or OIDC client service account making the changes
+func (c Cx1Client) provisioningCaller() string {
+	if c.IsUser {
+		return strings.ToLower(c.claims.Username)
+	}
+	return "service-account-" + strings.ToLower(c.claims.ClientID)
+}
+
+func (c Cx1Client) planProvisioningUser(user *User, su *ProvisioningUser, groups map[string]*Group, managed []string) ([]ProvisioningChange, error) {
+	changes := []ProvisioningChange{}
+
+	details := []string{}
+	if su.Email != "" && !strings.EqualFold(su.Email, user.Email) {
+		details = append(details, fmt.Sprintf("email: '%v' -> '%v'", user.Email, su.Email))
+	}
+	if su.FirstName != "" && su.FirstName != user.FirstName {
+		details = append(details, fmt.Sprintf("firstName: '%v' -> '%v'", user.FirstName, su.FirstName))
+	}
+	if su.LastName != "" && su.LastName != user.LastName {
+		details = append(details, fmt.Sprintf("lastName: '%v' -> '%v'", user.LastName, su.LastName))
+	}
+	if len(details) > 0 {
+		changes = append(changes, ProvisioningChange{Action: ProvisioningUpdate, UserName: user.UserName, UserID: user.UserID, user: user, source: su, Details: details})
+	}
+
+	if enabled := su.IsEnabled(); enabled != user.Enabled {
+		action := ProvisioningDisable
+		if enabled {
+			action = ProvisioningEnable
+		}
+		changes = append(changes, ProvisioningChange{Action: action, UserName: user.UserName, UserID: user.UserID, user: user})
+	}
+
+	currentGroups, err := c.GetUserGroups(user)
+	if err != nil {
+		return changes, fmt.Errorf("failed to get groups for user %v: %s", user.String(), err)
+	}
+
+	desired := []string{}
+	for _, ref := range su.Groups {
+		group := groups[ref]
+		if group != nil {
+			desired = append(desired, group.GroupID)
+			if inGroup, _ := user.IsInGroupByID(group.GroupID); inGroup {
+				continue
+			}
+		}
+		changes = append(changes, newProvisioningGroupChange(ProvisioningAddGroup, user.UserName, user, ref, group))
+	}
+
+	for id := range currentGroups {
+		g := &currentGroups[id]
+		if !slices.Contains(desired, g.GroupID) && slices.Contains(managed, g.Path) {
+			changes = append(changes, newProvisioningGroupChange(ProvisioningRemoveGroup, user.UserName, user, g.Path, g))
+		}
+	}
+
+	return changes, nil
+}
+
+func newProvisioningGroupChange(action, username string, user *User, ref string, group *Group) ProvisioningChange {
+	change := ProvisioningChange{Action: action, UserName: username, GroupPath: ref, user: user}
+	if user != nil {
+		change.UserID = user.UserID
+	}
+	if group == nil {
+		change.Error = fmt.Sprintf("group %v does not exist", ref)
+	} else {
+		change.GroupPath = group.Path
+		change.groupID = group.GroupID
+	}
+	return change
+}
+
+// groups can be referenced by path (starting with /) or by name
+func (c Cx1Client) getProvisioningGroup(ref string) (Group, error) {
+	if strings.HasPrefix(ref, "/") {
+		return c.GetGroupByPath(ref)
+	}
+	return c.GetGroupByName(ref)
+}
+
+/*
+Applies the planned changes in order and writes an audit record for each to options.AuditLog.
+Changes that could not be planned (eg: unknown group) are recorded with their error and skipped.
+For a dry-run nothing is changed and the records are marked as such.
+*/
+func (c Cx1Client) ApplyProvisioning(changes []ProvisioningChange, options ProvisioningOptions) ([]ProvisioningChange, error) {
+	created := make(map[string]*User)
+	failed := 0
+
+	for id := range changes {
+		change := &changes[id]
+		change.Timestamp = time.Now().UTC()
+		change.DryRun = options.DryRun
+
+		if change.Error == "" && !options.DryRun {
+			if err := c.applyProvisioningChange(change, created); err != nil {
+				change.Error = err.Error()
+			} else {
+				change.Applied = true
+			}
+		}
+
+		if change.Error != "" {
+			failed++
+			c.logger.Errorf("Provisioning %v failed: %v", change.String(), change.Error)
+		} else {
+			c.logger.Infof("Provisioning %v", change.String())
+		}
+
+		if options.AuditLog != nil {
+			if err := json.NewEncoder(options.AuditLog).Encode(change); err != nil {
+				c.logger.Errorf("Failed to write audit record for %v: %s", change.String(), err)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return changes, fmt.Errorf("%d of %d provisioning changes failed", failed, len(changes))
+	}
+	return changes, nil
+}
+
+func (c Cx1Client) applyProvisioningChange(change *ProvisioningChange, created map[string]*User) error {
+	user := change.user
+	if user == nil && change.Action != ProvisioningCreate {
+		user = created[strings.ToLower(change.UserName)]
+		if user == nil {
+			return fmt.Errorf("user %v was not created", change.UserName)
+		}
+		change.UserID = user.UserID
+	}
+
+	switch change.Action {
+	case ProvisioningCreate:
+		su := change.source
+		newUser := User{
+			UserName:  su.UserName,
+			Email:     su.Email,
+			FirstName: su.FirstName,
+			LastName:  su.LastName,
+			Enabled:   su.IsEnabled(),
+		}
+		var err error
+		if su.IdPAlias != "" {
+			idpUserName := su.IdPUserName
+			if idpUserName == "" {
+				idpUserName = su.UserName
+			}
+			newUser, err = c.CreateSAMLUser(newUser, su.IdPAlias, su.IdPUserID, idpUserName)
+		} else {
+			newUser, err = c.CreateUser(newUser)
+		}
+		if err != nil {
+			return err
+		}
+		newUser.FilledGroups = true // a new user has no groups
+		created[strings.ToLower(su.UserName)] = &newUser
+		change.UserID = newUser.UserID
+		return nil
+	case ProvisioningUpdate:
+		su := change.source
+		if su.Email != "" {
+			user.Email = su.Email
+		}
+		if su.FirstName != "" {
+			user.FirstName = su.FirstName
+		}
+		if su.LastName != "" {
+			user.LastName = su.LastName
+		}
+		return c.UpdateUser(user)
+	case ProvisioningEnable, ProvisioningDisable:
+		user.Enabled = change.Action == ProvisioningEnable
+		return c.UpdateUser(user)
+	case ProvisioningDelete:
+		return c.DeleteUser(user)
+	case ProvisioningAddGroup:
+		return c.AssignUserToGroupByID(user, change.groupID)
+	case ProvisioningRemoveGroup:
+		return c.RemoveUserFromGroupByID(user, change.groupID)
+	}
+	return fmt.Errorf("unknown provisioning action %v", change.Action)
+}
+
+func (c ProvisioningChange) String() string {
+	s := fmt.Sprintf("%v user %v", c.Action, c.UserName)
+	if c.GroupPath != "" {
+		s = fmt.Sprintf("%v group %v", s, c.GroupPath)
+	}
+	if len(c.Details) > 0 {
+		s = fmt.Sprintf("%v: %v", s, strings.Join(c.Details, ", "))
+	}
+	if c.DryRun {
+		s += " (dry-run)"
+	}
+	return s
+}
+
+func (u ProvisioningUser) IsEnabled() bool {
+	return u.Enabled == nil || *u.Enabled
+}
+
+func (u ProvisioningUser) describe() []string {
+	details := []string{fmt.Sprintf("email: %v", u.Email), fmt.Sprintf("name: %v %v", u.FirstName, u.LastName)}
+	if u.IdPAlias != "" {
+		details = append(details, fmt.Sprintf("idp: %v", u.IdPAlias))
+	}
+	if !u.IsEnabled() {
+		details = append(details, "disabled")
+	}
+	return details
+}
+
+/*
+Reads users from a CSV file with a header row. Recognized columns (case-insensitive) are:
+username, email, firstname, lastname, enabled, groups (separated by ;), idpalias, idpuserid, idpusername
+*/
+func (s CSVProvisioningSource) GetUsers() ([]ProvisioningUser, error) {
+	users := []ProvisioningUser{}
+
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return users, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return users, fmt.Errorf("failed to read header: %s", err)
+	}
+
+	columns := make(map[string]int)
+	for id, name := range header {
+		name = strings.ToLower(strings.NewReplacer("_", "", " ", "", "-", "").Replace(strings.TrimSpace(name)))
+		columns[name] = id
+	}
+	if _, ok := columns["username"]; !ok {
+		return users, fmt.Errorf("CSV %v has no username column", s.Path)
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return users, err
+		}
+
+		get := func(column string) string {
+			if id, ok := columns[column]; ok && id < len(record) {
+				return strings.TrimSpace(record[id])
+			}
+			return ""
+		}
+
+		user := ProvisioningUser{
+			UserName:    get("username"),
+			Email:       get("email"),
+			FirstName:   get("firstname"),
+			LastName:    get("lastname"),
+			IdPAlias:    get("idpalias"),
+			IdPUserID:   get("idpuserid"),
+			IdPUserName: get("idpusername"),
+			Groups:      []string{},
+		}
+		if enabled := get("enabled"); enabled != "" {
+			b, err := strconv.ParseBool(enabled)
+			if err != nil {
+				return users, fmt.Errorf("line %d: invalid enabled value %v", line, enabled)
+			}
+			user.Enabled = &b
+		}
+		for _, g := range strings.Split(get("groups"), ";") {
+			if g = strings.TrimSpace(g); g != "" {
+				user.Groups = append(user.Groups, g)
+			}
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (s CSVProvisioningSource) String() string {
+	return fmt.Sprintf("CSV file %v", s.Path)
+}
+
+// Reads a JSON array of ProvisioningUser
+func (s JSONProvisioningSource) GetUsers() ([]ProvisioningUser, error) {
+	users := []ProvisioningUser{}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return users, err
+	}
+	err = json.Unmarshal(data, &users)
+	return users, err
+}
+
+func (s JSONProvisioningSource) String() string {
+	return fmt.Sprintf("JSON file %v", s.Path)
+}
+
+type scimUserListResponse struct {
+	TotalResults int        `json:"totalResults"`
+	StartIndex   int        `json:"startIndex"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	Resources    []SCIMUser `json:"Resources"`
+}
+
+// Reads all users from the SCIM 2.0 /Users endpoint, group memberships are taken from the users' groups attribute
+func (s SCIMProvisioningSource) GetUsers() ([]ProvisioningUser, error) {
+	users := []ProvisioningUser{}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	startIndex := 1
+	for {
+		params := url.Values{
+			"startIndex": {strconv.Itoa(startIndex)},
+			"count":      {"100"},
+		}
+		request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%v/Users?%v", strings.TrimSuffix(s.URL, "/"), params.Encode()), nil)
+		if err != nil {
+			return users, err
+		}
+		request.Header.Set("Accept", scimContentType)
+		if s.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+s.Token)
+		}
+
+		response, err := client.Do(request)
+		if err != nil {
+			return users, err
+		}
+		var page scimUserListResponse
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return users, fmt.Errorf("SCIM request returned HTTP %v", response.Status)
+		}
+		err = json.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return users, fmt.Errorf("failed to parse SCIM response: %s", err)
+		}
+
+		for _, r := range page.Resources {
+			users = append(users, r.toProvisioningUser())
+		}
+
+		startIndex += len(page.Resources)
+		if len(page.Resources) == 0 || startIndex > page.TotalResults {
+			break
+		}
+	}
+
+	return users, nil
+}
+
+func (s SCIMProvisioningSource) String() string {
+	return fmt.Sprintf("SCIM service %v", s.URL)
+}
+
+// Returns the primary email, or the first email if none is marked primary
+func (u SCIMUser) GetEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// Returns true unless active is explicitly false, SCIM services may omit active for enabled users
+func (u SCIMUser) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// an omitted active attribute leaves Enabled nil, so the user is not disabled
+func (u SCIMUser) toProvisioningUser() ProvisioningUser {
+	user := ProvisioningUser{
+		UserName:  u.UserName,
+		FirstName: u.Name.GivenName,
+		LastName:  u.Name.FamilyName,
+		Email:     u.GetEmail(),
+		Enabled:   u.Active,
+		Groups:    []string{},
+	}
+	for _, g := range u.Groups {
+		user.Groups = append(user.Groups, g.Display)
+	}
+	return user
+}
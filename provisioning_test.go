@@ -0,0 +1,64 @@
+package Cx1ClientGo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSCIMProvisioningSourceActive(t *testing.T) {
+	pages := map[string]string{
+		"1": `{"totalResults": 3, "startIndex": 1, "itemsPerPage": 2, "Resources": [
+			{"userName": "alice", "emails": [{"value": "alice@example.com"}], "groups": [{"display": "dev"}]},
+			{"userName": "bob", "active": false}
+		]}`,
+		"3": `{"totalResults": 3, "startIndex": 3, "itemsPerPage": 2, "Resources": [
+			{"userName": "carol", "active": true}
+		]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scim/v2/Users" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(pages[r.URL.Query().Get("startIndex")]))
+	}))
+	defer server.Close()
+
+	source := SCIMProvisioningSource{URL: server.URL + "/scim/v2/", Token: "secret", Client: server.Client()}
+	users, err := source.GetUsers()
+	if err != nil {
+		t.Fatalf("failed to get users: %s", err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users over two pages, got %d", len(users))
+	}
+
+	enabled, disabled := true, false
+	tests := []struct {
+		userName string
+		enabled  *bool // nil when active was omitted
+	}{
+		{"alice", nil},
+		{"bob", &disabled},
+		{"carol", &enabled},
+	}
+
+	for id, test := range tests {
+		user := users[id]
+		if user.UserName != test.userName {
+			t.Errorf("expected user %v at %d, got %v", test.userName, id, user.UserName)
+			continue
+		}
+		if (user.Enabled == nil) != (test.enabled == nil) || (user.Enabled != nil && *user.Enabled != *test.enabled) {
+			t.Errorf("user %v: unexpected enabled value %v", user.UserName, user.Enabled)
+		}
+		if user.IsEnabled() != (test.userName != "bob") {
+			t.Errorf("user %v: unexpected IsEnabled %v", user.UserName, user.IsEnabled())
+		}
+	}
+
+	if users[0].Email != "alice@example.com" || len(users[0].Groups) != 1 || users[0].Groups[0] != "dev" {
+		t.Errorf("unexpected details for alice: %+v", users[0])
+	}
+}
@@ -71,7 +71,7 @@ func (c Cx1Client) GetQueriesByLevelID(level, levelId string) (SASTQueryCollecti
 		return collection, fmt.Errorf("invalid level %v, options are currently: Corp or Project", level)
 	}
 
-	response, err := c.sendRequest(http.MethodGet, url, nil, nil)
+	response, err := c.sendCachedRequest(url)
 	if err != nil {
 		return collection, err
 	}
@@ -170,6 +170,15 @@ func GetSeverityID(severity string) uint {
 	return 0
 }
 
+// returns true if the severity is one of the names accepted by GetSeverityID, which maps anything else to Info
+func isKnownSeverity(severity string) bool {
+	switch strings.ToUpper(severity) {
+	case "INFO", "INFORMATION", "LOW", "MEDIUM", "HIGH", "CRITICAL":
+		return true
+	}
+	return false
+}
+
 func (c Cx1Client) GetSeverity(severity uint) string {
 	return GetSeverity(severity)
 }
@@ -0,0 +1,415 @@
+package Cx1ClientGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+	Export and import of SAST and IAC query collections to a stable JSON format, so that collections can be stored
+	(eg: in git) and compared between two tenants or two points in time.
+	Queries are written as a flat list sorted by language/platform, group, name and level so that the output for an
+	unchanged collection is identical. The query source is only included if it was retrieved, eg: by calling
+	GetSASTCollectionAuditMetadata or GetIACCollectionAuditMetadata before exporting.
+
+	Loaded collections can be compared with GetDiffs/IsSubset, merged with UpdateFromCollection, and
+	GetChangelog returns the list of added, removed and modified custom queries.
+*/
+
+const queryCollectionFileVersion = 1
+
+const (
+	QueryChangeAdded    = "added"
+	QueryChangeRemoved  = "removed"
+	QueryChangeModified = "modified"
+)
+
+type queryCollectionFile struct {
+	Version     int              `json:"version"`
+	Engine      string           `json:"engine"`
+	SASTQueries []sastQueryEntry `json:"sastQueries,omitempty"`
+	IACQueries  []iacQueryEntry  `json:"iacQueries,omitempty"`
+}
+
+type sastQueryEntry struct {
+	Language           string `json:"language"`
+	Group              string `json:"group"`
+	Name               string `json:"name"`
+	Level              string `json:"level"`
+	LevelID            string `json:"levelId"`
+	QueryID            uint64 `json:"queryId,string"`
+	SastID             uint64 `json:"sastId,omitempty"`
+	EditorKey          string `json:"key,omitempty"`
+	Path               string `json:"path,omitempty"`
+	Severity           string `json:"severity"`
+	CweID              int64  `json:"cwe"`
+	IsExecutable       bool   `json:"executable"`
+	QueryDescriptionId int64  `json:"descriptionId"`
+	Custom             bool   `json:"custom"`
+	Modified           string `json:"modified,omitempty"`
+	Source             string `json:"source,omitempty"`
+}
+
+type iacQueryEntry struct {
+	Platform       string `json:"platform"`
+	Group          string `json:"group"`
+	Name           string `json:"name"`
+	Level          string `json:"level"`
+	LevelID        string `json:"levelId"`
+	QueryID        string `json:"queryId"`
+	Key            string `json:"key"`
+	Path           string `json:"path,omitempty"`
+	Severity       string `json:"severity"`
+	CWE            string `json:"cwe"`
+	Category       string `json:"category"`
+	Description    string `json:"description"`
+	DescriptionID  string `json:"descriptionId"`
+	DescriptionURL string `json:"descriptionUrl"`
+	Custom         bool   `json:"custom"`
+	Source         string `json:"source,omitempty"`
+}
+
+// Writes the collection as JSON, including sources where available
+func (qc SASTQueryCollection) Export(w io.Writer) error {
+	queries := qc.GetQueries()
+	sort.SliceStable(queries, func(i, j int) bool {
+		a, b := queries[i], queries[j]
+		return compareQueryOrder([]string{a.Language, a.Group, a.Name}, a.Level, a.LevelID, []string{b.Language, b.Group, b.Name}, b.Level, b.LevelID)
+	})
+
+	file := queryCollectionFile{Version: queryCollectionFileVersion, Engine: "sast", SASTQueries: make([]sastQueryEntry, len(queries))}
+	for id, q := range queries {
+		file.SASTQueries[id] = sastQueryEntry{
+			Language:           q.Language,
+			Group:              q.Group,
+			Name:               q.Name,
+			Level:              q.Level,
+			LevelID:            q.LevelID,
+			QueryID:            q.QueryID,
+			SastID:             q.SastID,
+			EditorKey:          q.EditorKey,
+			Path:               q.Path,
+			Severity:           q.Severity,
+			CweID:              q.CweID,
+			IsExecutable:       q.IsExecutable,
+			QueryDescriptionId: q.QueryDescriptionId,
+			Custom:             q.Custom,
+			Modified:           q.Modified,
+			Source:             q.Source,
+		}
+	}
+
+	return writeQueryCollectionFile(w, &file)
+}
+
+// Writes the collection as JSON, including sources where available
+func (qc IACQueryCollection) Export(w io.Writer) error {
+	queries := qc.GetQueries()
+	sort.SliceStable(queries, func(i, j int) bool {
+		a, b := queries[i], queries[j]
+		return compareQueryOrder([]string{a.Platform, a.Group, a.Name}, a.Level, a.LevelID, []string{b.Platform, b.Group, b.Name}, b.Level, b.LevelID)
+	})
+
+	file := queryCollectionFile{Version: queryCollectionFileVersion, Engine: "iac", IACQueries: make([]iacQueryEntry, len(queries))}
+	for id, q := range queries {
+		file.IACQueries[id] = iacQueryEntry{
+			Platform:       q.Platform,
+			Group:          q.Group,
+			Name:           q.Name,
+			Level:          q.Level,
+			LevelID:        q.LevelID,
+			QueryID:        q.QueryID,
+			Key:            q.Key,
+			Path:           q.Path,
+			Severity:       q.Severity,
+			CWE:            q.CWE,
+			Category:       q.Category,
+			Description:    q.Description,
+			DescriptionID:  q.DescriptionID,
+			DescriptionURL: q.DescriptionURL,
+			Custom:         q.Custom,
+			Source:         q.Source,
+		}
+	}
+
+	return writeQueryCollectionFile(w, &file)
+}
+
+// Reads a collection written by SASTQueryCollection.Export
+// Query levels are converted to the naming used by the current Cx1 version (eg: Corp -> Tenant)
+func ImportSASTQueryCollection(r io.Reader) (SASTQueryCollection, error) {
+	var qc SASTQueryCollection
+	file, err := readQueryCollectionFile(r, "sast")
+	if err != nil {
+		return qc, err
+	}
+
+	for id, e := range file.SASTQueries {
+		if e.Language == "" || e.Group == "" || e.Name == "" {
+			return qc, fmt.Errorf("sast query entry %d (%v) is missing the language, group or name", id, e.Name)
+		}
+		if !isKnownSeverity(e.Severity) {
+			return qc, fmt.Errorf("sast query entry %d (%v) has unknown severity '%v'", id, e.Name, e.Severity)
+		}
+		level, levelId := normalizeQueryLevel(e.Level, e.LevelID)
+		qc.AddQuery(SASTQuery{
+			QueryID:            e.QueryID,
+			Level:              level,
+			LevelID:            levelId,
+			Path:               e.Path,
+			Modified:           e.Modified,
+			Source:             e.Source,
+			Name:               e.Name,
+			Group:              e.Group,
+			Language:           e.Language,
+			Severity:           e.Severity,
+			CweID:              e.CweID,
+			IsExecutable:       e.IsExecutable,
+			QueryDescriptionId: e.QueryDescriptionId,
+			Custom:             e.Custom,
+			EditorKey:          e.EditorKey,
+			SastID:             e.SastID,
+		})
+	}
+	return qc, nil
+}
+
+// Reads a collection written by IACQueryCollection.Export
+// Query levels are converted to the naming used by the current Cx1 version (eg: Corp -> Tenant)
+func ImportIACQueryCollection(r io.Reader) (IACQueryCollection, error) {
+	var qc IACQueryCollection
+	file, err := readQueryCollectionFile(r, "iac")
+	if err != nil {
+		return qc, err
+	}
+
+	for id, e := range file.IACQueries {
+		if e.Key == "" && e.QueryID == "" {
+			return qc, fmt.Errorf("iac query entry %d (%v) has neither a key nor a query ID", id, e.Name)
+		}
+		if e.Platform == "" || e.Name == "" {
+			return qc, fmt.Errorf("iac query entry %d (%v) is missing the platform or name", id, e.Name)
+		}
+		level, levelId := normalizeQueryLevel(e.Level, e.LevelID)
+		qc.AddQuery(IACQuery{
+			QueryID:        e.QueryID,
+			Name:           e.Name,
+			Description:    e.Description,
+			DescriptionID:  e.DescriptionID,
+			DescriptionURL: e.DescriptionURL,
+			Platform:       e.Platform,
+			Group:          e.Group,
+			Category:       e.Category,
+			Severity:       e.Severity,
+			CWE:            e.CWE,
+			Level:          level,
+			LevelID:        levelId,
+			Custom:         e.Custom,
+			Key:            e.Key,
+			Path:           e.Path,
+			Source:         e.Source,
+		})
+	}
+	return qc, nil
+}
+
+func writeQueryCollectionFile(w io.Writer, file *queryCollectionFile) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false) // query sources contain < and >
+	return encoder.Encode(file)
+}
+
+func readQueryCollectionFile(r io.Reader, engine string) (queryCollectionFile, error) {
+	var file queryCollectionFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return file, fmt.Errorf("failed to parse query collection: %s", err)
+	}
+	if file.Version > queryCollectionFileVersion {
+		return file, fmt.Errorf("query collection file version %d is not supported, maximum is %d", file.Version, queryCollectionFileVersion)
+	}
+	if file.Engine != engine {
+		return file, fmt.Errorf("query collection file contains %v queries, expected %v", file.Engine, engine)
+	}
+	return file, nil
+}
+
+func queryLevelOrder(level string) int {
+	switch level {
+	case AUDIT_QUERY_PRODUCT:
+		return 0
+	case AUDIT_QUERY_TENANT, "Corp", "Tenant":
+		return 1
+	case AUDIT_QUERY_APPLICATION, "Team", "Application":
+		return 2
+	case AUDIT_QUERY_PROJECT:
+		return 3
+	}
+	return 4
+}
+
+func compareQueryOrder(a []string, aLevel, aLevelID string, b []string, bLevel, bLevelID string) bool {
+	for id := range a {
+		if a[id] != b[id] {
+			return a[id] < b[id]
+		}
+	}
+	if queryLevelOrder(aLevel) != queryLevelOrder(bLevel) {
+		return queryLevelOrder(aLevel) < queryLevelOrder(bLevel)
+	}
+	return aLevelID < bLevelID
+}
+
+// tenant and application levels were renamed in Cx1 3.12.7, the tenant level uses the level as ID
+func normalizeQueryLevel(level, levelId string) (string, string) {
+	switch level {
+	case "Corp", "Tenant":
+		if levelId == level {
+			levelId = AUDIT_QUERY_TENANT
+		}
+		level = AUDIT_QUERY_TENANT
+	case "Team", "Application":
+		level = AUDIT_QUERY_APPLICATION
+	}
+	return level, levelId
+}
+
+func (c QueryCollectionChange) String() string {
+	if len(c.Details) == 0 {
+		return fmt.Sprintf("%v %v", c.Change, c.Query)
+	}
+	return fmt.Sprintf("%v %v: %v", c.Change, c.Query, strings.Join(c.Details, ", "))
+}
+
+/*
+Returns the custom queries (overrides and new queries) that were added, removed or modified compared to the previous collection.
+Sources are only compared if they are available in both collections.
+*/
+func (qc SASTQueryCollection) GetChangelog(previous *SASTQueryCollection) []QueryCollectionChange {
+	current := qc.GetCustomQueryCollection()
+	prior := previous.GetCustomQueryCollection()
+	changes := []QueryCollectionChange{}
+
+	removed, added := current.GetDiffs(&prior)
+	for _, q := range added.GetQueries() {
+		changes = append(changes, QueryCollectionChange{Change: QueryChangeAdded, Query: q.StringDetailed()})
+	}
+	for _, q := range removed.GetQueries() {
+		changes = append(changes, QueryCollectionChange{Change: QueryChangeRemoved, Query: q.StringDetailed()})
+	}
+
+	for _, q := range current.GetQueries() {
+		old := prior.findQuery(q.Level, q.LevelID, q.Name, q.QueryID)
+		if old == nil {
+			continue
+		}
+		var details []string
+		details = appendQueryChange(details, "severity", old.Severity, q.Severity)
+		details = appendQueryChange(details, "cwe", old.CweID, q.CweID)
+		details = appendQueryChange(details, "executable", old.IsExecutable, q.IsExecutable)
+		details = appendQueryChange(details, "description", old.QueryDescriptionId, q.QueryDescriptionId)
+		details = appendSourceChange(details, old.Source, q.Source)
+		if len(details) > 0 {
+			changes = append(changes, QueryCollectionChange{Change: QueryChangeModified, Query: q.StringDetailed(), Details: details})
+		}
+	}
+
+	sortQueryChangelog(changes)
+	return changes
+}
+
+/*
+Returns the custom queries (overrides and new queries) that were added, removed or modified compared to the previous collection.
+Sources are only compared if they are available in both collections.
+*/
+func (qc IACQueryCollection) GetChangelog(previous *IACQueryCollection) []QueryCollectionChange {
+	current := qc.GetCustomQueryCollection()
+	prior := previous.GetCustomQueryCollection()
+	changes := []QueryCollectionChange{}
+
+	removed, added := current.GetDiffs(&prior)
+	for _, q := range added.GetQueries() {
+		changes = append(changes, QueryCollectionChange{Change: QueryChangeAdded, Query: q.StringDetailed()})
+	}
+	for _, q := range removed.GetQueries() {
+		changes = append(changes, QueryCollectionChange{Change: QueryChangeRemoved, Query: q.StringDetailed()})
+	}
+
+	for _, q := range current.GetQueries() {
+		old := prior.findQuery(q.Level, q.LevelID, q.Name, q.Key)
+		if old == nil {
+			continue
+		}
+		var details []string
+		details = appendQueryChange(details, "severity", old.Severity, q.Severity)
+		details = appendQueryChange(details, "cwe", old.CWE, q.CWE)
+		details = appendQueryChange(details, "category", old.Category, q.Category)
+		details = appendQueryChange(details, "description", old.Description, q.Description)
+		details = appendQueryChange(details, "descriptionId", old.DescriptionID, q.DescriptionID)
+		details = appendQueryChange(details, "descriptionUrl", old.DescriptionURL, q.DescriptionURL)
+		details = appendSourceChange(details, old.Source, q.Source)
+		if len(details) > 0 {
+			changes = append(changes, QueryCollectionChange{Change: QueryChangeModified, Query: q.StringDetailed(), Details: details})
+		}
+	}
+
+	sortQueryChangelog(changes)
+	return changes
+}
+
+// Writes the changelog as a plain-text list, one change per line
+func WriteQueryChangelog(w io.Writer, changes []QueryCollectionChange) error {
+	if len(changes) == 0 {
+		_, err := io.WriteString(w, "No changes to custom queries\n")
+		return err
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintf(w, "- %v\n", c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendQueryChange[T comparable](details []string, field string, old, new T) []string {
+	if old != new {
+		return append(details, fmt.Sprintf("%v %v -> %v", field, old, new))
+	}
+	return details
+}
+
+func appendSourceChange(details []string, old, new string) []string {
+	if old == "" || new == "" || querySourceEqual(old, new) {
+		return details
+	}
+
+	counts := make(map[string]int)
+	for _, line := range strings.Split(strings.ReplaceAll(old, "\r\n", "\n"), "\n") {
+		counts[line]++
+	}
+	added := 0
+	for _, line := range strings.Split(strings.ReplaceAll(new, "\r\n", "\n"), "\n") {
+		if counts[line] > 0 {
+			counts[line]--
+		} else {
+			added++
+		}
+	}
+	removed := 0
+	for _, count := range counts {
+		removed += count
+	}
+	return append(details, fmt.Sprintf("source changed (+%d/-%d lines)", added, removed))
+}
+
+func sortQueryChangelog(changes []QueryCollectionChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Query != changes[j].Query {
+			return changes[i].Query < changes[j].Query
+		}
+		return changes[i].Change < changes[j].Change
+	})
+}
@@ -0,0 +1,484 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Synchronizes a local query repository (eg: a git checkout) with the SAST queries in Cx1.
+	The repository has one directory per level:
+
+		tenant/<language>/<group>/<query>.cs
+		application/<application name or ID>/<language>/<group>/<query>.cs
+		project/<project name or ID>/<language>/<group>/<query>.cs
+
+	Each query can have a <query>.yaml file next to the source with the metadata:
+
+		severity: High
+		cwe: 79
+		description: 12345
+		executable: true
+
+	Metadata fields that are not set are inherited from the query being overridden.
+	Application- and project-level queries are applied through an audit session for a project, so only the
+	application and project matching the session are synchronized in one call; tenant-level queries are always included.
+*/
+
+const (
+	QuerySyncCreate   = "create"   // new query at tenant level
+	QuerySyncOverride = "override" // new override of an existing query at a lower level
+	QuerySyncUpdate   = "update"   // update source and/or metadata of an existing query
+)
+
+var querySyncScopes = []string{"tenant", "application", "project"}
+
+// Reads all queries from the repository directory, see the description at the top of querysync.go for the layout
+func LoadQueryRepository(path string) (QueryRepository, error) {
+	repo := QueryRepository{Path: path}
+
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if file != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(file), ".cs") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		scope := strings.ToLower(parts[0])
+		if !slices.Contains(querySyncScopes, scope) {
+			return nil
+		}
+
+		query := QueryRepositoryQuery{
+			Scope:      scope,
+			SourceFile: file,
+		}
+		switch {
+		case scope == "tenant" && len(parts) == 4:
+			query.Language, query.Group = parts[1], parts[2]
+		case scope != "tenant" && len(parts) == 5:
+			query.ScopeName, query.Language, query.Group = parts[1], parts[2], parts[3]
+		default:
+			return fmt.Errorf("query file %v does not match the expected layout %v", rel, queryRepositoryLayout(scope))
+		}
+		query.Name = strings.TrimSuffix(parts[len(parts)-1], filepath.Ext(file))
+
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read query source %v: %s", rel, err)
+		}
+		query.Source = string(source)
+
+		query.Metadata, err = loadQueryRepositoryMetadata(strings.TrimSuffix(file, filepath.Ext(file)))
+		if err != nil {
+			return fmt.Errorf("failed to read metadata for query %v: %s", rel, err)
+		}
+
+		repo.Queries = append(repo.Queries, query)
+		return nil
+	})
+
+	return repo, err
+}
+
+func queryRepositoryLayout(scope string) string {
+	if scope == "tenant" {
+		return "tenant/<language>/<group>/<query>.cs"
+	}
+	return fmt.Sprintf("%v/<name or id>/<language>/<group>/<query>.cs", scope)
+}
+
+func loadQueryRepositoryMetadata(basename string) (QueryRepositoryMetadata, error) {
+	var metadata QueryRepositoryMetadata
+	for _, ext := range []string{".yaml", ".yml"} {
+		data, err := os.ReadFile(basename + ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return metadata, err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&metadata); err != nil && !errors.Is(err, io.EOF) {
+			return metadata, err
+		}
+		if metadata.Severity != "" && !isKnownSeverity(metadata.Severity) {
+			return metadata, fmt.Errorf("unknown severity '%v', expected Info, Low, Medium, High or Critical", metadata.Severity)
+		}
+		return metadata, nil
+	}
+	return metadata, nil
+}
+
+func (r QueryRepository) String() string {
+	return fmt.Sprintf("Query repository %v with %d queries", r.Path, len(r.Queries))
+}
+
+func (q QueryRepositoryQuery) String() string {
+	if q.ScopeName == "" {
+		return fmt.Sprintf("%v: %v -> %v -> %v", q.Scope, q.Language, q.Group, q.Name)
+	}
+	return fmt.Sprintf("%v %v: %v -> %v -> %v", q.Scope, q.ScopeName, q.Language, q.Group, q.Name)
+}
+
+func (s QuerySyncChange) String() string {
+	var changes []string
+	if s.SourceChanged {
+		changes = append(changes, "source")
+	}
+	if s.MetadataChanged {
+		changes = append(changes, "metadata")
+	}
+	str := fmt.Sprintf("%v %v", s.Action, s.Query.String())
+	if len(changes) > 0 {
+		str += fmt.Sprintf(" (%v)", strings.Join(changes, ", "))
+	}
+	if len(s.Failures) > 0 {
+		str += fmt.Sprintf(" - %d compilation failures", len(s.Failures))
+	}
+	if s.Error != nil {
+		str += fmt.Sprintf(" - error: %s", s.Error)
+	}
+	return str
+}
+
+/*
+Compares the repository against the queries available in the audit session and returns the list of changes required.
+Sources for existing queries are compiled via ValidateSASTQuerySource, any compilation errors are returned in the
+change's Failures. Nothing is changed in Cx1.
+*/
+func (c Cx1Client) PlanQuerySync(auditSession *AuditSession, repo *QueryRepository) ([]QuerySyncChange, error) {
+	c.logger.Debugf("Planning sync of %v under %v", repo.String(), auditSession.String())
+	changes := []QuerySyncChange{}
+
+	collection, err := c.getQuerySyncCollection(auditSession)
+	if err != nil {
+		return changes, err
+	}
+
+	applicationName := ""
+	if auditSession.ApplicationID != "" {
+		if app, err := c.GetApplicationByID(auditSession.ApplicationID); err != nil {
+			c.logger.Warnf("Failed to get application %v, application-level queries must be referenced by ID: %s", auditSession.ApplicationID, err)
+		} else {
+			applicationName = app.Name
+		}
+	}
+
+	queries := make([]QueryRepositoryQuery, len(repo.Queries))
+	copy(queries, repo.Queries)
+	sort.SliceStable(queries, func(i, j int) bool {
+		return slices.Index(querySyncScopes, queries[i].Scope) < slices.Index(querySyncScopes, queries[j].Scope)
+	})
+
+	created := make(map[string]bool) // queries that will be created by this sync, keyed by language/group/name
+
+	for _, lq := range queries {
+		change := QuerySyncChange{Query: lq}
+
+		switch lq.Scope {
+		case "tenant":
+			change.Level, change.LevelID = c.QueryTypeTenant(), c.QueryTypeTenant()
+		case "application":
+			if auditSession.ApplicationID == "" || (lq.ScopeName != auditSession.ApplicationID && lq.ScopeName != applicationName) {
+				c.logger.Tracef("Skipping %v: not the application of %v", lq.String(), auditSession.String())
+				continue
+			}
+			change.Level, change.LevelID = c.QueryTypeApplication(), auditSession.ApplicationID
+		case "project":
+			if lq.ScopeName != auditSession.ProjectID && lq.ScopeName != auditSession.ProjectName {
+				c.logger.Tracef("Skipping %v: not the project of %v", lq.String(), auditSession.String())
+				continue
+			}
+			change.Level, change.LevelID = c.QueryTypeProject(), auditSession.ProjectID
+		}
+
+		queryKey := strings.Join([]string{lq.Language, lq.Group, lq.Name}, "/")
+
+		if current := collection.GetQueryByLevelAndName(change.Level, change.LevelID, lq.Language, lq.Group, lq.Name); current != nil {
+			query, err := c.getQuerySyncQuery(auditSession, current)
+			if err != nil {
+				return changes, err
+			}
+			change.Action = QuerySyncUpdate
+			change.Current = &query
+			change.Metadata = lq.Metadata.apply(query.GetMetadata())
+			change.SourceChanged = !querySourceEqual(query.Source, lq.Source)
+			change.MetadataChanged = query.MetadataDifferent(change.Metadata)
+			if !change.SourceChanged && !change.MetadataChanged {
+				c.logger.Tracef("Query %v is unchanged", lq.String())
+				continue
+			}
+			if change.SourceChanged {
+				change.Failures, change.Error = c.ValidateSASTQuerySource(auditSession, &query, lq.Source)
+			}
+		} else if base := c.getQuerySyncBase(&collection, auditSession, change.Level, lq.Language, lq.Group, lq.Name); base != nil {
+			query, err := c.getQuerySyncQuery(auditSession, base)
+			if err != nil {
+				return changes, err
+			}
+			change.Action = QuerySyncOverride
+			change.Metadata = lq.Metadata.apply(query.GetMetadata())
+			change.SourceChanged = !querySourceEqual(query.Source, lq.Source)
+			change.MetadataChanged = query.MetadataDifferent(change.Metadata)
+			if change.SourceChanged {
+				change.Failures, change.Error = c.ValidateSASTQuerySource(auditSession, &query, lq.Source)
+			}
+		} else if created[queryKey] {
+			// overrides a query created earlier in this sync, it can only be validated once that exists
+			change.Action = QuerySyncOverride
+			change.SourceChanged = true
+			change.MetadataChanged = true
+		} else if lq.Scope == "tenant" {
+			change.Action = QuerySyncCreate
+			change.Metadata = lq.Metadata.apply(AuditSASTQueryMetadata{
+				Language:     lq.Language,
+				Group:        lq.Group,
+				Name:         lq.Name,
+				Severity:     "Medium",
+				IsExecutable: true,
+			})
+			change.SourceChanged = true
+			change.MetadataChanged = true
+			created[queryKey] = true
+		} else {
+			change.Action = QuerySyncOverride
+			change.Error = fmt.Errorf("query %v does not exist at a lower level, new queries must first be created at the tenant level", lq.String())
+		}
+
+		if change.Error != nil {
+			c.logger.Warnf("Planned change %v", change.String())
+		} else {
+			c.logger.Debugf("Planned change %v", change.String())
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+/*
+Applies the changes returned by PlanQuerySync. If any of the planned changes has an error or compilation failures,
+nothing is applied. The changes are updated with the result of each step.
+*/
+func (c Cx1Client) ApplyQuerySync(auditSession *AuditSession, changes []QuerySyncChange) error {
+	invalid := 0
+	for _, change := range changes {
+		if change.Error != nil || len(change.Failures) > 0 {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d planned query changes have errors, nothing was applied", invalid, len(changes))
+	}
+
+	created := make(map[string]SASTQuery)
+	failed := 0
+	for id := range changes {
+		change := &changes[id]
+		c.logger.Infof("Applying %v", change.String())
+
+		var query SASTQuery
+		var err error
+		switch change.Action {
+		case QuerySyncCreate:
+			query, change.Failures, err = c.CreateNewSASTQuery(auditSession, SASTQuery{
+				Name:               change.Query.Name,
+				Language:           change.Query.Language,
+				Group:              change.Query.Group,
+				Severity:           change.Metadata.Severity,
+				IsExecutable:       change.Metadata.IsExecutable,
+				CweID:              change.Metadata.Cwe,
+				QueryDescriptionId: change.Metadata.CxDescriptionID,
+				Source:             change.Query.Source,
+			})
+			if err == nil {
+				query.Level, query.LevelID = change.Level, change.LevelID
+				created[strings.Join([]string{query.Language, query.Group, query.Name}, "/")] = query
+			}
+		case QuerySyncOverride:
+			query, err = c.applyQuerySyncOverride(auditSession, change, created)
+		case QuerySyncUpdate:
+			query, err = c.applyQuerySyncUpdate(auditSession, change)
+		default:
+			err = fmt.Errorf("unknown action '%v'", change.Action)
+		}
+
+		if err == nil && len(change.Failures) > 0 {
+			err = fmt.Errorf("query source failed to compile")
+		}
+		if err != nil {
+			change.Error = err
+			failed++
+			c.logger.Errorf("Failed to apply %v", change.String())
+			continue
+		}
+		change.Current = &query
+		change.Applied = true
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d query changes failed", failed, len(changes))
+	}
+	return nil
+}
+
+// Convenience function: plans the changes and applies them unless dryRun is set. Returns the planned changes.
+func (c Cx1Client) SyncQueryRepository(auditSession *AuditSession, repo *QueryRepository, dryRun bool) ([]QuerySyncChange, error) {
+	changes, err := c.PlanQuerySync(auditSession, repo)
+	if err != nil || dryRun {
+		return changes, err
+	}
+	return changes, c.ApplyQuerySync(auditSession, changes)
+}
+
+func (c Cx1Client) applyQuerySyncOverride(auditSession *AuditSession, change *QuerySyncChange, created map[string]SASTQuery) (SASTQuery, error) {
+	var base *SASTQuery
+	if q, ok := created[strings.Join([]string{change.Query.Language, change.Query.Group, change.Query.Name}, "/")]; ok {
+		base = &q
+	} else {
+		collection, err := c.getQuerySyncCollection(auditSession)
+		if err != nil {
+			return SASTQuery{}, err
+		}
+		base = c.getQuerySyncBase(&collection, auditSession, change.Level, change.Query.Language, change.Query.Group, change.Query.Name)
+		if base == nil {
+			return SASTQuery{}, fmt.Errorf("no base query found for %v", change.Query.String())
+		}
+	}
+
+	baseQuery, err := c.getQuerySyncQuery(auditSession, base)
+	if err != nil {
+		return SASTQuery{}, err
+	}
+	query, err := c.CreateSASTQueryOverride(auditSession, change.Level, &baseQuery)
+	if err != nil {
+		return query, err
+	}
+
+	// for overrides of queries created in this sync the metadata is only known now
+	if change.Metadata.Name == "" {
+		change.Metadata = change.Query.Metadata.apply(query.GetMetadata())
+	}
+
+	query, change.Failures, err = c.UpdateSASTQuerySource(auditSession, query, change.Query.Source)
+	if err != nil {
+		return query, err
+	}
+	return c.UpdateSASTQueryMetadata(auditSession, query, change.Metadata)
+}
+
+func (c Cx1Client) applyQuerySyncUpdate(auditSession *AuditSession, change *QuerySyncChange) (SASTQuery, error) {
+	query := *change.Current
+	var err error
+	if change.SourceChanged {
+		query, change.Failures, err = c.UpdateSASTQuerySource(auditSession, query, change.Query.Source)
+		if err != nil {
+			return query, err
+		}
+	}
+	if change.MetadataChanged {
+		return c.UpdateSASTQueryMetadata(auditSession, query, change.Metadata)
+	}
+	return query, nil
+}
+
+func (c Cx1Client) getQuerySyncCollection(auditSession *AuditSession) (SASTQueryCollection, error) {
+	var collection SASTQueryCollection
+	var err error
+	if auditSession.ProjectID != "" {
+		collection, err = c.GetAuditSASTQueriesByLevelID(auditSession, c.QueryTypeProject(), auditSession.ProjectID)
+	} else {
+		collection, err = c.GetAuditSASTQueriesByLevelID(auditSession, c.QueryTypeTenant(), c.QueryTypeTenant())
+	}
+	if err != nil {
+		return collection, fmt.Errorf("failed to get queries for %v: %s", auditSession.String(), err)
+	}
+	return collection, nil
+}
+
+// returns the closest existing query at a lower level than the requested level
+func (c Cx1Client) getQuerySyncBase(collection *SASTQueryCollection, auditSession *AuditSession, level, language, group, name string) *SASTQuery {
+	type levelID struct{ level, id string }
+	var lower []levelID
+	switch level {
+	case c.QueryTypeProject():
+		if auditSession.ApplicationID != "" {
+			lower = append(lower, levelID{c.QueryTypeApplication(), auditSession.ApplicationID})
+		}
+		fallthrough
+	case c.QueryTypeApplication():
+		lower = append(lower, levelID{c.QueryTypeTenant(), c.QueryTypeTenant()})
+		fallthrough
+	case c.QueryTypeTenant():
+		lower = append(lower, levelID{c.QueryTypeProduct(), c.QueryTypeProduct()})
+	}
+
+	for _, l := range lower {
+		if q := collection.GetQueryByLevelAndName(l.level, l.id, language, group, name); q != nil {
+			return q
+		}
+	}
+	return nil
+}
+
+// the query tree does not include the source or full metadata, so each query is fetched individually
+func (c Cx1Client) getQuerySyncQuery(auditSession *AuditSession, query *SASTQuery) (SASTQuery, error) {
+	q := *query
+	if q.EditorKey == "" {
+		q.CalculateEditorKey()
+	}
+	full, err := c.GetAuditSASTQueryByKey(auditSession, q.EditorKey)
+	if err != nil {
+		return full, fmt.Errorf("failed to get query %v: %s", q.StringDetailed(), err)
+	}
+	full.MergeQuery(q)
+	return full, nil
+}
+
+// returns the metadata with any fields set in the repository metadata replaced
+func (m QueryRepositoryMetadata) apply(metadata AuditSASTQueryMetadata) AuditSASTQueryMetadata {
+	if m.Severity != "" {
+		metadata.Severity = GetSeverity(GetSeverityID(m.Severity))
+	}
+	if m.CWE != nil {
+		metadata.Cwe = *m.CWE
+	}
+	if m.Description != nil {
+		metadata.CxDescriptionID = *m.Description
+	}
+	if m.Executable != nil {
+		metadata.IsExecutable = *m.Executable
+	}
+	return metadata
+}
+
+func querySourceEqual(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+	}
+	return normalize(a) == normalize(b)
+}
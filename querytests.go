@@ -0,0 +1,387 @@
+package Cx1ClientGo
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Unit tests for CxQL queries. Each test suite is a directory with a querytest.yaml file and a directory of sample code:
+
+		name: sql-injection
+		samples: samples
+		tests:
+		  - language: Java
+		    group: Java_High_Risk
+		    query: SQL_Injection
+		    source: SQL_Injection.cs   # optional, otherwise the query currently saved in Cx1 is run
+		    exact: true
+		    expected:
+		      - file: src/Main.java
+		        line: 14
+		        count: 1           # optional, exact number of results expected (0 = none), otherwise at least one
+
+	RunQueryTestSuite uploads and scans the samples, opens an audit session on that scan, runs each query and compares the
+	results with the expectations. WriteQueryTestJUnit produces a JUnit XML report from the test results.
+*/
+
+const queryTestFile = "querytest.yaml"
+
+// Finds all querytest.yaml files under the path and loads the suites and query sources
+func LoadQueryTestSuites(path string) ([]QueryTestSuite, error) {
+	var suites []QueryTestSuite
+
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != queryTestFile {
+			return nil
+		}
+
+		suite, err := loadQueryTestSuite(filepath.Dir(file))
+		if err != nil {
+			return err
+		}
+		suites = append(suites, suite)
+		return nil
+	})
+
+	return suites, err
+}
+
+func loadQueryTestSuite(dir string) (QueryTestSuite, error) {
+	suite := QueryTestSuite{}
+
+	data, err := os.ReadFile(filepath.Join(dir, queryTestFile))
+	if err != nil {
+		return suite, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&suite); err != nil {
+		return suite, fmt.Errorf("failed to parse %v: %s", filepath.Join(dir, queryTestFile), err)
+	}
+
+	suite.Path = dir
+	if suite.Name == "" {
+		suite.Name = filepath.Base(dir)
+	}
+	if suite.Samples == "" {
+		suite.Samples = "samples"
+	}
+
+	for id := range suite.Tests {
+		test := &suite.Tests[id]
+		if test.Language == "" || test.Group == "" || test.Query == "" {
+			return suite, fmt.Errorf("test %d in suite %v must specify the language, group and query", id, suite.Name)
+		}
+		if test.Name == "" {
+			test.Name = fmt.Sprintf("%v.%v.%v", test.Language, test.Group, test.Query)
+		}
+		if test.SourceFile != "" {
+			source, err := os.ReadFile(filepath.Join(dir, test.SourceFile))
+			if err != nil {
+				return suite, fmt.Errorf("failed to read query source for test %v: %s", test.Name, err)
+			}
+			test.Source = string(source)
+		}
+	}
+
+	return suite, nil
+}
+
+func (s QueryTestSuite) String() string {
+	return fmt.Sprintf("Query test suite %v with %d tests", s.Name, len(s.Tests))
+}
+
+func (r QueryTestResult) String() string {
+	switch {
+	case r.Error != nil:
+		return fmt.Sprintf("%v/%v: ERROR %s", r.Suite, r.Test, r.Error)
+	case r.Passed:
+		return fmt.Sprintf("%v/%v: PASS (%d results)", r.Suite, r.Test, len(r.Results))
+	default:
+		return fmt.Sprintf("%v/%v: FAIL %v", r.Suite, r.Test, strings.Join(r.Failures, "; "))
+	}
+}
+
+/*
+Runs the tests in the suite: the samples are zipped and scanned under the project (branch = suite name), an audit session
+is created for that scan and each query is run in the session. The audit session is deleted afterwards.
+An error is returned only if the suite could not be run at all, failures of individual tests are in the results.
+*/
+func (c Cx1Client) RunQueryTestSuite(project *Project, suite *QueryTestSuite) ([]QueryTestResult, error) {
+	c.logger.Infof("Running %v", suite.String())
+	results := []QueryTestResult{}
+
+	zipContents, err := zipQueryTestSamples(filepath.Join(suite.Path, suite.Samples))
+	if err != nil {
+		return results, fmt.Errorf("failed to zip samples for suite %v: %s", suite.Name, err)
+	}
+
+	uploadUrl, err := c.UploadBytes(&zipContents)
+	if err != nil {
+		return results, fmt.Errorf("failed to upload samples for suite %v: %s", suite.Name, err)
+	}
+
+	settings := []ScanConfiguration{{ScanType: "sast", Values: map[string]string{"incremental": "false"}}}
+	scan, err := c.ScanProjectZipByID(project.ProjectID, uploadUrl, suite.Name, settings, map[string]string{"querytest": ""})
+	if err != nil {
+		return results, err
+	}
+
+	scan, err = c.ScanPolling(&scan)
+	if err != nil {
+		return results, fmt.Errorf("failed to poll scan %v for suite %v: %s", scan.ScanID, suite.Name, err)
+	}
+	if scan.Status != "Completed" {
+		return results, fmt.Errorf("scan %v for suite %v finished with status %v", scan.ScanID, suite.Name, scan.Status)
+	}
+
+	session, err := c.GetAuditSessionByID("sast", project.ProjectID, scan.ScanID)
+	if err != nil {
+		return results, fmt.Errorf("failed to create audit session for suite %v: %s", suite.Name, err)
+	}
+	defer func() {
+		if err := c.AuditDeleteSession(&session); err != nil {
+			c.logger.Warnf("Failed to delete %v: %s", session.String(), err)
+		}
+	}()
+
+	collection, err := c.GetAuditSASTQueriesByLevelID(&session, c.QueryTypeProject(), project.ProjectID)
+	if err != nil {
+		return results, fmt.Errorf("failed to get queries for suite %v: %s", suite.Name, err)
+	}
+
+	for _, test := range suite.Tests {
+		start := time.Now()
+		result := c.runQueryTest(&session, &collection, &test)
+		result.Suite = suite.Name
+		result.Duration = time.Since(start)
+		c.logger.Infof("%v", result.String())
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (c Cx1Client) runQueryTest(session *AuditSession, collection *SASTQueryCollection, test *QueryTest) QueryTestResult {
+	result := QueryTestResult{Test: test.Name}
+
+	query := collection.GetQueryByLevelAndName(c.QueryTypeProject(), session.ProjectID, test.Language, test.Group, test.Query)
+	if query == nil {
+		query = c.getQuerySyncBase(collection, session, c.QueryTypeProject(), test.Language, test.Group, test.Query)
+	}
+	if query == nil {
+		result.Error = fmt.Errorf("query %v -> %v -> %v does not exist", test.Language, test.Group, test.Query)
+		return result
+	}
+
+	q, err := c.getQuerySyncQuery(session, query)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	source := test.Source
+	if source == "" {
+		source = q.Source
+	}
+
+	failure, err := c.RunSASTQuery(session, &q, source)
+	if err != nil {
+		if len(failure.Errors) > 0 {
+			for _, e := range failure.Errors {
+				result.Failures = append(result.Failures, fmt.Sprintf("compilation error on line %d: %v", e.Line, e.Message))
+			}
+			return result
+		}
+		result.Error = err
+		return result
+	}
+
+	result.Results, err = c.GetAuditSASTQueryResultsByKey(session, q.EditorKey)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to get results: %s", err)
+		return result
+	}
+
+	result.Failures = test.Check(result.Results)
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// Compares the results with the expectations and returns a description of each mismatch
+func (t QueryTest) Check(results []AuditQueryResult) []string {
+	failures := []string{}
+	covered := make([]bool, len(results))
+
+	for _, e := range t.Expected {
+		var count uint64
+		for id, r := range results {
+			if e.Matches(r) {
+				count++
+				covered[id] = true
+			}
+		}
+		if e.Count == nil {
+			if count == 0 {
+				failures = append(failures, fmt.Sprintf("expected results at %v, found none", e.String()))
+			}
+		} else if count != *e.Count {
+			failures = append(failures, fmt.Sprintf("expected %d results at %v, found %d", *e.Count, e.String(), count))
+		}
+	}
+
+	if t.Exact {
+		for id, r := range results {
+			if !covered[id] && len(r.Nodes) > 0 {
+				n := r.Nodes[len(r.Nodes)-1]
+				failures = append(failures, fmt.Sprintf("unexpected result at %v:%d", n.FileName, n.Line))
+			}
+		}
+	}
+
+	return failures
+}
+
+func (e QueryTestExpectation) Matches(r AuditQueryResult) bool {
+	if len(r.Nodes) == 0 {
+		return false
+	}
+	for _, n := range []AuditQueryResultNode{r.Nodes[0], r.Nodes[len(r.Nodes)-1]} {
+		if strings.HasSuffix(filepath.ToSlash(n.FileName), strings.TrimPrefix(filepath.ToSlash(e.File), "/")) && (e.Line == 0 || e.Line == n.Line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e QueryTestExpectation) String() string {
+	if e.Line == 0 {
+		return e.File
+	}
+	return fmt.Sprintf("%v:%d", e.File, e.Line)
+}
+
+func zipQueryTestSamples(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Details string `xml:",chardata"`
+}
+
+// Writes the results as a JUnit XML report, with one testsuite per query test suite
+func WriteQueryTestJUnit(w io.Writer, results []QueryTestResult) error {
+	report := junitTestSuites{}
+	suiteIndex := make(map[string]int)
+
+	for _, r := range results {
+		id, ok := suiteIndex[r.Suite]
+		if !ok {
+			id = len(report.Suites)
+			suiteIndex[r.Suite] = id
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.Suite})
+		}
+		suite := &report.Suites[id]
+
+		tc := junitTestCase{Name: r.Test, ClassName: r.Suite, Time: r.Duration.Seconds()}
+		if r.Error != nil {
+			tc.Error = &junitMessage{Message: r.Error.Error()}
+			suite.Errors++
+		} else if !r.Passed {
+			tc.Failure = &junitMessage{Message: fmt.Sprintf("%d expectations failed", len(r.Failures)), Details: strings.Join(r.Failures, "\n")}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Time += tc.Time
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	for _, s := range report.Suites {
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Errors += s.Errors
+		report.Time += s.Time
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
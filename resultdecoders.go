@@ -0,0 +1,194 @@
+package Cx1ClientGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+/*
+	Scan results are decoded from the /results response as a stream: the response body is read with a json.Decoder
+	token by token, and each entry in the results array is held only as raw bytes until it has been decoded into the
+	concrete type registered for its "type" field. This avoids building a generic map for the whole page and
+	re-marshalling every result, which matters for scans with many thousands of results.
+
+	Decoders for the built-in engines are registered below. Other result types can be added with
+	RegisterScanResultType, and results of types without a decoder (or whose decoder failed) are kept in
+	ScanResultSet.Raw with the full payload instead of being dropped.
+
+	Each result is unmarshalled twice: once into ScanResultBase to find its type (the same value is kept for raw
+	results), then by the decoder for that type. The base fields are a small part of a result compared with the
+	nodes or package data, and this keeps decoders self-contained - a single generic decode would need every
+	result type to be split into base and engine-specific parts.
+*/
+
+var scanResultDecodersLock sync.RWMutex
+var scanResultDecoders = map[string]ScanResultDecoder{
+	"sast": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.SAST)
+	},
+	"sca": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.SCA)
+	},
+	"sca-container": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.SCAContainer)
+	},
+	"kics": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.IAC)
+	},
+	"containers": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.Containers)
+	},
+	"sscs-secret-detection": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.SecretDetection)
+	},
+	"sscs-scorecard": func(data json.RawMessage, results *ScanResultSet) error {
+		return appendScanResult(data, &results.Scorecard)
+	},
+}
+
+// Registers a decoder for results with the given "type" value, replacing any existing decoder for that type.
+// Decoders are shared by all clients and may be registered at any time, eg: from an init function.
+func RegisterScanResultType(resultType string, decoder ScanResultDecoder) {
+	scanResultDecodersLock.Lock()
+	defer scanResultDecodersLock.Unlock()
+	scanResultDecoders[strings.ToLower(resultType)] = decoder
+}
+
+// Returns the result types which currently have a registered decoder
+func GetScanResultTypes() []string {
+	scanResultDecodersLock.RLock()
+	defer scanResultDecodersLock.RUnlock()
+	types := make([]string, 0, len(scanResultDecoders))
+	for t := range scanResultDecoders {
+		types = append(types, t)
+	}
+	return types
+}
+
+func getScanResultDecoder(resultType string) ScanResultDecoder {
+	scanResultDecodersLock.RLock()
+	defer scanResultDecodersLock.RUnlock()
+	return scanResultDecoders[strings.ToLower(resultType)]
+}
+
+func appendScanResult[T any](data json.RawMessage, list *[]T) error {
+	var result T
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*list = append(*list, result)
+	return nil
+}
+
+// Decodes a /results response: {"results": [...], "totalCount": N}
+// Note: totalCount is the number of results matching the filter, not the number in this page
+func (c Cx1Client) decodeScanResults(reader io.Reader) (uint64, ScanResultSet, error) {
+	var results ScanResultSet
+	var totalCount uint64
+
+	dec := json.NewDecoder(reader)
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		c.logger.Tracef("Failed while parsing response: %s", err)
+		return 0, results, err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return totalCount, results, fmt.Errorf("failed to read results response: %s", err)
+		}
+
+		switch token {
+		case "results":
+			if err = c.decodeScanResultList(dec, &results); err != nil {
+				return totalCount, results, err
+			}
+		case "totalCount":
+			if err = dec.Decode(&totalCount); err != nil {
+				return totalCount, results, fmt.Errorf("failed to read results totalCount: %s", err)
+			}
+		default:
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return totalCount, results, fmt.Errorf("failed to read results response field %v: %s", token, err)
+			}
+		}
+	}
+
+	if err := expectJSONDelim(dec, '}'); err != nil {
+		return totalCount, results, err
+	}
+
+	c.logger.Debugf("Retrieved %d results (%d without a registered type) of %d", results.Count(), len(results.Raw), totalCount)
+	return totalCount, results, nil
+}
+
+func (c Cx1Client) decodeScanResultList(dec *json.Decoder, results *ScanResultSet) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read results list: %s", err)
+	}
+	if token == nil { // "results": null
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected results list but found %v", token)
+	}
+
+	for dec.More() {
+		var data json.RawMessage
+		if err = dec.Decode(&data); err != nil {
+			return fmt.Errorf("failed to read result: %s", err)
+		}
+
+		var base ScanResultBase
+		if err = json.Unmarshal(data, &base); err != nil { // a base field has an unexpected type, read only the type
+			var probe struct {
+				Type         string `json:"type"`
+				SimilarityID string `json:"similarityId"`
+			}
+			if perr := json.Unmarshal(data, &probe); perr != nil {
+				c.logger.Warnf("Failed to read result type: %s", perr)
+				continue
+			}
+			c.logger.Tracef("Failed to read base fields of result %v: %s", probe.SimilarityID, err)
+			base = ScanResultBase{Type: probe.Type, SimilarityID: probe.SimilarityID}
+		}
+
+		if decoder := getScanResultDecoder(base.Type); decoder != nil {
+			if err = decoder(data, results); err == nil {
+				continue
+			}
+			c.logger.Warnf("Failed to unmarshal result %v to %v type, keeping raw result: %s", base.SimilarityID, base.Type, err)
+		} else {
+			c.logger.Tracef("No decoder registered for result %v of type %v, keeping raw result", base.SimilarityID, base.Type)
+		}
+
+		results.Raw = append(results.Raw, RawResult{ScanResultBase: base, Data: data})
+	}
+
+	return expectJSONDelim(dec, ']')
+}
+
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %s", err)
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected '%v' in response but found %v", delim, token)
+	}
+	return nil
+}
+
+// Decodes the payload of a raw result into another type, eg: once a matching struct is available
+func (r RawResult) Decode(v interface{}) error {
+	return json.Unmarshal(r.Data, v)
+}
+
+func (r RawResult) String() string {
+	return fmt.Sprintf("%v (%v) - %v", r.SimilarityID, r.Type, r.Severity)
+}
@@ -0,0 +1,127 @@
+package Cx1ClientGo
+
+import (
+	"strings"
+	"testing"
+)
+
+// logs to the test output
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Tracef(format string, args ...interface{}) { l.t.Logf("TRACE "+format, args...) }
+func (l testLogger) Debugf(format string, args ...interface{}) { l.t.Logf("DEBUG "+format, args...) }
+func (l testLogger) Infof(format string, args ...interface{})  { l.t.Logf("INFO "+format, args...) }
+func (l testLogger) Warnf(format string, args ...interface{})  { l.t.Logf("WARN "+format, args...) }
+func (l testLogger) Errorf(format string, args ...interface{}) { l.t.Logf("ERROR "+format, args...) }
+func (l testLogger) Fatalf(format string, args ...interface{}) { l.t.Fatalf(format, args...) }
+
+func newTestClient(t *testing.T) Cx1Client {
+	return Cx1Client{logger: testLogger{t}}
+}
+
+func TestDecodeScanResults(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		totalCount uint64
+		sast, sca  int
+		iac        int
+		raw        []string // similarity IDs of the raw results
+		invalid    bool
+	}{
+		{
+			name:       "empty",
+			body:       `{"results": [], "totalCount": 0}`,
+			totalCount: 0,
+		},
+		{
+			name:       "null results",
+			body:       `{"totalCount": 3, "results": null}`,
+			totalCount: 3,
+		},
+		{
+			name: "known types",
+			body: `{"results": [
+				{"type": "sast", "similarityId": "s1", "severity": "HIGH", "data": {"queryName": "SQL_Injection", "nodes": [{"line": 3}]}},
+				{"type": "SCA", "similarityId": "s2", "data": {"packageIdentifier": "npm-lodash-4.17.20"}},
+				{"type": "kics", "similarityId": "s3", "data": {}}
+			], "totalCount": 10}`,
+			totalCount: 10,
+			sast:       1, sca: 1, iac: 1,
+		},
+		{
+			name: "unknown type is kept raw",
+			body: `{"totalCount": 2, "extra": {"ignored": [1, 2]}, "results": [
+				{"type": "new-engine", "similarityId": "n1", "severity": "LOW", "data": {"anything": true}},
+				{"type": "sast", "similarityId": "s1"}
+			]}`,
+			totalCount: 2,
+			sast:       1,
+			raw:        []string{"n1"},
+		},
+		{
+			name: "failed decode is kept raw",
+			body: `{"results": [
+				{"type": "sast", "similarityId": "bad", "data": {"queryId": "not-a-number"}}
+			], "totalCount": 1}`,
+			totalCount: 1,
+			raw:        []string{"bad"},
+		},
+		{
+			name: "unexpected base field type is kept raw",
+			body: `{"results": [
+				{"type": "sast", "similarityId": "odd", "confidenceLevel": "high"}
+			], "totalCount": 1}`,
+			totalCount: 1,
+			raw:        []string{"odd"},
+		},
+		{
+			name:    "not an object",
+			body:    `[]`,
+			invalid: true,
+		},
+		{
+			name:    "results not a list",
+			body:    `{"results": {"type": "sast"}}`,
+			invalid: true,
+		},
+		{
+			name:    "truncated",
+			body:    `{"results": [{"type": "sast", "similarityId": "s1"}`,
+			invalid: true,
+		},
+	}
+
+	c := newTestClient(t)
+	for _, test := range tests {
+		totalCount, results, err := c.decodeScanResults(strings.NewReader(test.body))
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if totalCount != test.totalCount {
+			t.Errorf("%v: totalCount = %d, expected %d", test.name, totalCount, test.totalCount)
+		}
+		if len(results.SAST) != test.sast || len(results.SCA) != test.sca || len(results.IAC) != test.iac {
+			t.Errorf("%v: got %d sast, %d sca, %d iac results, expected %d, %d, %d", test.name, len(results.SAST), len(results.SCA), len(results.IAC), test.sast, test.sca, test.iac)
+		}
+		if len(results.Raw) != len(test.raw) {
+			t.Errorf("%v: got %d raw results, expected %d", test.name, len(results.Raw), len(test.raw))
+			continue
+		}
+		for id, r := range results.Raw {
+			if r.SimilarityID != test.raw[id] || len(r.Data) == 0 || r.Type == "" {
+				t.Errorf("%v: raw result %d = %v with %d bytes, expected %v with the payload", test.name, id, r.String(), len(r.Data), test.raw[id])
+			}
+		}
+	}
+}
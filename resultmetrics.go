@@ -0,0 +1,589 @@
+package Cx1ClientGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Result metrics which the analytics API does not provide, computed locally from the scan history:
+	  - mean time to remediate per query, grouped by project, application, group (team) or tag
+	  - SLA breaches: open findings whose age since FirstFoundAt exceeds the target for their severity
+	  - recurrence: how often remediated findings come back
+
+	Findings are tracked per project, branch, result type and similarity ID. A finding is remediated when a later
+	completed scan of the same branch (with the finding's engine) no longer reports it, or when its state changes
+	to one of the remediated states (default NOT_EXPLOITABLE), in which case the triage time can be taken from the
+	predicate history. A remediated finding that is reported again (or leaves the remediated states) recurs.
+
+	Update only processes scans which were not processed before, and Save/Load persist the state between runs so
+	that a scheduled job only has to fetch the results of new scans. The scan list can only be filtered by creation
+	time, so scans created up to Overlap before the last completion are listed again and the IDs of the scans
+	processed in that window are kept: a scan that was created earlier but finished later is still picked up.
+	Such a scan is older than results already processed for its branch, so it only adds the findings it reports.
+*/
+
+const (
+	ResultMetricsByProject     = "project"
+	ResultMetricsByApplication = "application"
+	ResultMetricsByGroup       = "group"
+	ResultMetricsByTag         = "tag"
+)
+
+// engine which produces each result type, absent findings only count as remediated if the engine ran
+var resultMetricsEngines = map[string]string{
+	"sast":       "sast",
+	"sca":        "sca",
+	"kics":       "kics",
+	"containers": "containers",
+}
+
+type resultMetricsProject struct {
+	Name         string
+	Applications []string // names
+	Groups       []string // paths
+	Tags         []string // key:value
+}
+
+type resultMetricsState struct {
+	Findings     map[string]*ResultMetricsFinding
+	Scans        map[string]time.Time // completion time of processed scans which finished within the overlap of LastScanAt
+	Branches     map[string]bool      // project/branch combinations with at least one processed scan
+	BranchScanAt map[string]time.Time // creation time of the latest processed scan per project/branch
+	Projects     map[string]resultMetricsProject
+	LastScanAt   time.Time // latest completion time of the processed scans
+}
+
+type resultMetricsEntry struct {
+	base  *ScanResultBase
+	query string
+}
+
+// Creates a ResultMetrics with empty state, use Load to continue from a previous run
+func NewResultMetrics(client *Cx1Client, options ResultMetricsOptions) *ResultMetrics {
+	if options.SLATargets == nil {
+		options.SLATargets = AnalyticsDefaultSLATargets
+	}
+	if options.RemediatedStates == nil {
+		options.RemediatedStates = []string{"NOT_EXPLOITABLE"}
+	}
+	if options.Since.IsZero() {
+		options.Since = time.Now().AddDate(0, 0, -90)
+	}
+	if options.Overlap <= 0 {
+		options.Overlap = 24 * time.Hour
+	}
+
+	m := &ResultMetrics{
+		client:  client,
+		options: options,
+	}
+	m.reset()
+	return m
+}
+
+func (m *ResultMetrics) reset() {
+	m.open = nil
+	m.state = resultMetricsState{
+		Findings:     make(map[string]*ResultMetricsFinding),
+		Scans:        make(map[string]time.Time),
+		Branches:     make(map[string]bool),
+		BranchScanAt: make(map[string]time.Time),
+		Projects:     make(map[string]resultMetricsProject),
+	}
+}
+
+// Processes all completed scans which were not processed yet, oldest first, and returns the number of scans processed.
+// On error the scans processed so far are kept, so calling Update again continues where it stopped.
+func (m *ResultMetrics) Update() (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	since := m.options.Since
+	if !m.state.LastScanAt.IsZero() {
+		since = m.state.LastScanAt.Add(-m.options.Overlap)
+	}
+
+	_, scans, err := m.client.GetAllScansFiltered(ScanFilter{
+		BaseFilter: BaseFilter{Limit: m.client.pagination.Scans},
+		Statuses:   []string{"Completed", "Partial"},
+		Branches:   m.options.Branches,
+		FromDate:   since,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get scans since %v: %s", since.Format(time.RFC3339), err)
+	}
+
+	type pendingScan struct {
+		scan        Scan
+		createdAt   time.Time
+		completedAt time.Time
+	}
+	pending := []pendingScan{}
+	for _, scan := range scans {
+		if _, ok := m.state.Scans[scan.ScanID]; ok {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, scan.CreatedAt)
+		if err != nil {
+			m.client.logger.Warnf("Skipping scan %v with invalid creation time %v: %s", scan.ScanID, scan.CreatedAt, err)
+			continue
+		}
+		completedAt, err := time.Parse(time.RFC3339Nano, scan.UpdatedAt)
+		if err != nil {
+			completedAt = createdAt
+		}
+		pending = append(pending, pendingScan{scan, createdAt, completedAt})
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].createdAt.Before(pending[j].createdAt) })
+
+	m.client.logger.Debugf("Result metrics: %d new scans since %v", len(pending), since.Format(time.RFC3339))
+
+	refreshed := make(map[string]bool)
+	processed := 0
+	for _, p := range pending {
+		if !refreshed[p.scan.ProjectID] {
+			if err = m.refreshProject(p.scan.ProjectID); err != nil {
+				return processed, err
+			}
+			refreshed[p.scan.ProjectID] = true
+		}
+		if err = m.processScan(&p.scan, p.createdAt, p.completedAt); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+
+	// scans which completed before the overlap window were created before it too, so they are not listed again
+	for scanID, completedAt := range m.state.Scans {
+		if completedAt.Before(m.state.LastScanAt.Add(-m.options.Overlap)) {
+			delete(m.state.Scans, scanID)
+		}
+	}
+	return processed, nil
+}
+
+func (m *ResultMetrics) refreshProject(projectID string) error {
+	project, err := m.client.GetProjectByID(projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project %v: %s", projectID, err)
+	}
+
+	info := resultMetricsProject{Name: project.Name}
+	if project.Applications != nil {
+		for _, id := range *project.Applications {
+			application, err := m.client.GetApplicationByID(id)
+			if err != nil {
+				return fmt.Errorf("failed to get application %v of project %v: %s", id, project.String(), err)
+			}
+			info.Applications = append(info.Applications, application.Name)
+		}
+	}
+	for _, id := range project.Groups {
+		group, err := m.client.GetGroupByID(id)
+		if err != nil {
+			return fmt.Errorf("failed to get group %v of project %v: %s", id, project.String(), err)
+		}
+		info.Groups = append(info.Groups, group.Path)
+	}
+	for key, value := range project.Tags {
+		if value == "" {
+			info.Tags = append(info.Tags, key)
+		} else {
+			info.Tags = append(info.Tags, fmt.Sprintf("%v:%v", key, value))
+		}
+	}
+	sort.Strings(info.Tags)
+
+	m.state.Projects[projectID] = info
+	return nil
+}
+
+func (m *ResultMetrics) processScan(scan *Scan, scanTime, completedAt time.Time) error {
+	if m.state.BranchScanAt == nil { // state saved by an earlier version
+		m.state.BranchScanAt = make(map[string]time.Time)
+	}
+	branch := scan.ProjectID + "|" + scan.Branch
+	stale := scanTime.Before(m.state.BranchScanAt[branch]) // a newer scan of the branch was already processed
+	m.indexOpenFindings()
+
+	_, results, err := m.client.GetAllScanResultsFiltered(ScanResultsFilter{
+		BaseFilter: BaseFilter{Limit: m.client.pagination.Results},
+		ScanID:     scan.ScanID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get results for scan %v: %s", scan.ScanID, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range resultMetricsEntries(&results) {
+		key := fmt.Sprintf("%v|%v|%v|%v", scan.ProjectID, scan.Branch, entry.base.Type, entry.base.SimilarityID)
+		seen[key] = true
+
+		finding, ok := m.state.Findings[key]
+		if !ok {
+			firstFound, err := time.Parse(time.RFC3339Nano, entry.base.FirstFoundAt)
+			if err != nil {
+				firstFound = scanTime
+			}
+			finding = &ResultMetricsFinding{
+				ProjectID:    scan.ProjectID,
+				Branch:       scan.Branch,
+				Type:         entry.base.Type,
+				SimilarityID: entry.base.SimilarityID,
+				FirstFoundAt: firstFound,
+				OpenedAt:     firstFound,
+			}
+			m.state.Findings[key] = finding
+		}
+
+		if stale && ok {
+			continue
+		}
+
+		finding.Query = entry.query
+		finding.Severity = entry.base.Severity
+		finding.State = entry.base.State
+		finding.LastScanID = scan.ScanID
+		finding.LastSeenAt = scanTime
+
+		if slices.Contains(m.options.RemediatedStates, entry.base.State) {
+			if finding.IsOpen() {
+				finding.remediate(m.triageTime(entry.base, scan.ProjectID, scan.ScanID, scanTime))
+			}
+		} else if !finding.IsOpen() {
+			finding.recur(scanTime)
+		}
+		m.trackOpenFinding(key, finding)
+	}
+
+	if !stale && m.state.Branches[branch] {
+		for key, finding := range m.open[branch] {
+			if !seen[key] && resultMetricsEngineCompleted(scan, finding.Type) {
+				finding.remediate(scanTime)
+				m.trackOpenFinding(key, finding)
+			}
+		}
+	}
+	m.state.Branches[branch] = true
+	if !stale {
+		m.state.BranchScanAt[branch] = scanTime
+	}
+
+	m.state.Scans[scan.ScanID] = completedAt
+	if completedAt.After(m.state.LastScanAt) {
+		m.state.LastScanAt = completedAt
+	}
+	return nil
+}
+
+// builds the index of open findings per project and branch, if it is not built yet (eg: after Load)
+func (m *ResultMetrics) indexOpenFindings() {
+	if m.open != nil {
+		return
+	}
+	m.open = make(map[string]map[string]*ResultMetricsFinding)
+	for key, finding := range m.state.Findings {
+		m.trackOpenFinding(key, finding)
+	}
+}
+
+// adds the finding to the index of open findings, or removes it if it is no longer open
+func (m *ResultMetrics) trackOpenFinding(key string, finding *ResultMetricsFinding) {
+	branch := finding.ProjectID + "|" + finding.Branch
+	if !finding.IsOpen() {
+		delete(m.open[branch], key)
+		return
+	}
+	if m.open[branch] == nil {
+		m.open[branch] = make(map[string]*ResultMetricsFinding)
+	}
+	m.open[branch][key] = finding
+}
+
+// Returns the time a result was triaged into a remediated state, from the predicate history if enabled
+func (m *ResultMetrics) triageTime(result *ScanResultBase, projectID, scanID string, scanTime time.Time) time.Time {
+	if !m.options.UsePredicates {
+		return scanTime
+	}
+
+	createdAt := ""
+	switch result.Type {
+	case "sast":
+		predicate, err := m.client.GetLastSASTResultsPredicateByID(result.SimilarityID, projectID, scanID)
+		if err != nil {
+			m.client.logger.Warnf("Failed to get predicates for %v: %s", result.SimilarityID, err)
+		}
+		createdAt = predicate.CreatedAt
+	case "kics":
+		predicates, err := m.client.GetIACResultsPredicatesByID(result.SimilarityID, projectID)
+		if err != nil {
+			m.client.logger.Warnf("Failed to get predicates for %v: %s", result.SimilarityID, err)
+		}
+		if len(predicates) > 0 {
+			createdAt = predicates[len(predicates)-1].CreatedAt
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+		return t
+	}
+	return scanTime
+}
+
+func resultMetricsEntries(results *ScanResultSet) []resultMetricsEntry {
+	entries := []resultMetricsEntry{}
+	for id := range results.SAST {
+		entries = append(entries, resultMetricsEntry{&results.SAST[id].ScanResultBase, results.SAST[id].Data.QueryName})
+	}
+	for id := range results.IAC {
+		entries = append(entries, resultMetricsEntry{&results.IAC[id].ScanResultBase, results.IAC[id].Data.QueryName})
+	}
+	for id := range results.SCA {
+		entries = append(entries, resultMetricsEntry{&results.SCA[id].ScanResultBase, results.SCA[id].VulnerabilityDetails.CveName})
+	}
+	for id := range results.Containers {
+		entries = append(entries, resultMetricsEntry{&results.Containers[id].ScanResultBase, results.Containers[id].VulnerabilityDetails.CveName})
+	}
+	return entries
+}
+
+// Returns true if the engine producing the result type ran and completed in the scan
+func resultMetricsEngineCompleted(scan *Scan, resultType string) bool {
+	engine, ok := resultMetricsEngines[resultType]
+	if !ok || !slices.Contains(scan.Engines, engine) {
+		return false
+	}
+	if scan.Status == "Completed" {
+		return true
+	}
+	for _, details := range scan.StatusDetails {
+		if details.Name == engine {
+			return details.Status == "Completed"
+		}
+	}
+	return false
+}
+
+func (f *ResultMetricsFinding) remediate(at time.Time) {
+	if at.Before(f.OpenedAt) {
+		at = f.OpenedAt
+	}
+	f.RemediatedAt = at
+	f.Remediations++
+	f.TimeToRemediate += at.Sub(f.OpenedAt)
+}
+
+func (f *ResultMetricsFinding) recur(at time.Time) {
+	f.RemediatedAt = time.Time{}
+	f.OpenedAt = at
+	f.Recurrences++
+}
+
+// Returns true if the finding has not been remediated (or has recurred since)
+func (f ResultMetricsFinding) IsOpen() bool {
+	return f.RemediatedAt.IsZero()
+}
+
+func (f ResultMetricsFinding) String() string {
+	status := "open"
+	if !f.IsOpen() {
+		status = fmt.Sprintf("remediated %v", f.RemediatedAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%v %v %v (%v) in project %v branch %v: first found %v, %v", f.Severity, f.Type, f.Query, f.SimilarityID, ShortenGUID(f.ProjectID), f.Branch, f.FirstFoundAt.Format(time.RFC3339), status)
+}
+
+// Returns the keys a finding is grouped under for a dimension, eg: all tags of its project
+func (m *ResultMetrics) keys(finding *ResultMetricsFinding, dimension string) []string {
+	project, ok := m.state.Projects[finding.ProjectID]
+	if !ok {
+		project.Name = finding.ProjectID
+	}
+
+	switch dimension {
+	case ResultMetricsByProject:
+		return []string{project.Name}
+	case ResultMetricsByApplication:
+		return project.Applications
+	case ResultMetricsByGroup:
+		return project.Groups
+	case ResultMetricsByTag:
+		return project.Tags
+	}
+	return []string{}
+}
+
+// Returns the mean time to remediate per query for each key of the dimension (ResultMetricsBy*), sorted by key and query
+func (m *ResultMetrics) GetMeanTimeToRemediate(dimension string) []ResultMetricsMTTR {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	index := make(map[string]*ResultMetricsMTTR)
+	for _, finding := range m.state.Findings {
+		if finding.Remediations == 0 {
+			continue
+		}
+		for _, key := range m.keys(finding, dimension) {
+			id := key + "|" + finding.Query
+			entry, ok := index[id]
+			if !ok {
+				entry = &ResultMetricsMTTR{Dimension: dimension, Key: key, Type: finding.Type, Query: finding.Query}
+				index[id] = entry
+			}
+			entry.Remediations += finding.Remediations
+			entry.TotalTime += finding.TimeToRemediate
+		}
+	}
+
+	list := make([]ResultMetricsMTTR, 0, len(index))
+	for _, entry := range index {
+		entry.MeanTime = entry.TotalTime / time.Duration(entry.Remediations)
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Key != list[j].Key {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].Query < list[j].Query
+	})
+	return list
+}
+
+// Returns the open findings whose age since FirstFoundAt exceeds the SLA target for their severity at the given time,
+// for each key of the dimension (ResultMetricsBy*), most overdue first
+func (m *ResultMetrics) GetSLABreaches(dimension string, at time.Time) []ResultMetricsSLABreach {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	breaches := []ResultMetricsSLABreach{}
+	for _, finding := range m.state.Findings {
+		if !finding.IsOpen() {
+			continue
+		}
+		target, ok := m.options.SLATargets[strings.ToLower(finding.Severity)]
+		if !ok {
+			continue
+		}
+		age := at.Sub(finding.FirstFoundAt)
+		overdue := age - time.Duration(target)*24*time.Hour
+		if overdue <= 0 {
+			continue
+		}
+		for _, key := range m.keys(finding, dimension) {
+			breaches = append(breaches, ResultMetricsSLABreach{
+				Dimension:  dimension,
+				Key:        key,
+				Finding:    *finding,
+				Age:        age,
+				TargetDays: target,
+				Overdue:    overdue,
+			})
+		}
+	}
+
+	sort.SliceStable(breaches, func(i, j int) bool { return breaches[i].Overdue > breaches[j].Overdue })
+	return breaches
+}
+
+// Returns the number of findings, remediations and recurrences for each key of the dimension (ResultMetricsBy*)
+func (m *ResultMetrics) GetRecurrence(dimension string) []ResultMetricsRecurrence {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	index := make(map[string]*ResultMetricsRecurrence)
+	for _, finding := range m.state.Findings {
+		for _, key := range m.keys(finding, dimension) {
+			entry, ok := index[key]
+			if !ok {
+				entry = &ResultMetricsRecurrence{Dimension: dimension, Key: key}
+				index[key] = entry
+			}
+			entry.Findings++
+			entry.Remediations += finding.Remediations
+			entry.Recurrences += finding.Recurrences
+			if finding.Recurrences > 0 {
+				entry.RecurredFindings++
+			}
+		}
+	}
+
+	list := make([]ResultMetricsRecurrence, 0, len(index))
+	for _, entry := range index {
+		if entry.Remediations > 0 {
+			entry.Rate = float64(entry.Recurrences) / float64(entry.Remediations)
+		}
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// Returns a copy of all tracked findings
+func (m *ResultMetrics) GetFindings() []ResultMetricsFinding {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	findings := make([]ResultMetricsFinding, 0, len(m.state.Findings))
+	for _, finding := range m.state.Findings {
+		findings = append(findings, *finding)
+	}
+	return findings
+}
+
+// Writes the state as JSON, to be restored with Load on the next run
+func (m *ResultMetrics) Save(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return json.NewEncoder(w).Encode(m.state)
+}
+
+// Replaces the state with one written by Save
+func (m *ResultMetrics) Load(r io.Reader) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.reset()
+	if err := json.NewDecoder(r).Decode(&m.state); err != nil {
+		m.reset()
+		return fmt.Errorf("failed to load result metrics state: %s", err)
+	}
+	return nil
+}
+
+// Returns the number of tracked findings
+func (m *ResultMetrics) Count() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.state.Findings)
+}
+
+func (m *ResultMetrics) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	open := 0
+	for _, finding := range m.state.Findings {
+		if finding.IsOpen() {
+			open++
+		}
+	}
+	return fmt.Sprintf("Result metrics: %d findings (%d open) in %d projects, last scan %v", len(m.state.Findings), open, len(m.state.Projects), m.state.LastScanAt.Format(time.RFC3339))
+}
+
+func (r ResultMetricsMTTR) String() string {
+	return fmt.Sprintf("%v %v - %v %v: %d remediations, mean time %v", r.Dimension, r.Key, r.Type, r.Query, r.Remediations, r.MeanTime.Round(time.Hour))
+}
+
+func (b ResultMetricsSLABreach) String() string {
+	return fmt.Sprintf("%v %v - %v, %d days overdue (target %d days)", b.Dimension, b.Key, b.Finding.String(), int64(b.Overdue.Hours()/24), b.TargetDays)
+}
+
+func (r ResultMetricsRecurrence) String() string {
+	return fmt.Sprintf("%v %v: %d findings, %d remediations, %d recurrences (rate %.2f)", r.Dimension, r.Key, r.Findings, r.Remediations, r.Recurrences, r.Rate)
+}
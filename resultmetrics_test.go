@@ -0,0 +1,357 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var resultMetricsT0 = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// a scan for the test server, results are similarity IDs of sast findings with an optional ":STATE" suffix
+type testMetricsScan struct {
+	id        string
+	branch    string
+	created   int // hours after resultMetricsT0
+	completed int
+	status    string   // default Completed
+	engines   []string // default sast
+	details   []ScanStatusDetails
+	results   []string
+}
+
+func (s testMetricsScan) scan() Scan {
+	scan := Scan{
+		ScanID:        s.id,
+		Status:        s.status,
+		StatusDetails: s.details,
+		Branch:        s.branch,
+		ProjectID:     "p1",
+		Engines:       s.engines,
+		CreatedAt:     resultMetricsT0.Add(time.Duration(s.created) * time.Hour).Format(time.RFC3339),
+		UpdatedAt:     resultMetricsT0.Add(time.Duration(s.completed) * time.Hour).Format(time.RFC3339),
+	}
+	if scan.Status == "" {
+		scan.Status = "Completed"
+	}
+	if scan.Engines == nil {
+		scan.Engines = []string{"sast"}
+	}
+	if scan.Branch == "" {
+		scan.Branch = "main"
+	}
+	return scan
+}
+
+func (s testMetricsScan) resultsJSON() string {
+	results := []string{}
+	for _, r := range s.results {
+		similarityID, state, _ := strings.Cut(r, ":")
+		if state == "" {
+			state = "TO_VERIFY"
+		}
+		results = append(results, fmt.Sprintf(`{"type": "sast", "similarityId": "%v", "state": "%v", "severity": "HIGH", "data": {"queryName": "Query_%v"}}`, similarityID, state, similarityID))
+	}
+	return fmt.Sprintf(`{"results": [%v], "totalCount": %d}`, strings.Join(results, ","), len(results))
+}
+
+// serves the scans list, results, and an empty project p1
+type testMetricsServer struct {
+	mutex sync.Mutex
+	scans []testMetricsScan
+	from  []time.Time // from-date of each scans request
+}
+
+func (s *testMetricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/api/projects/p1":
+		w.Write([]byte(`{"id": "p1", "name": "project"}`))
+	case "/api/configuration/project":
+		w.Write([]byte(`[]`))
+	case "/api/scans":
+		from, _ := time.Parse(time.RFC3339, r.URL.Query().Get("from-date"))
+		s.from = append(s.from, from)
+		scans := []string{}
+		for _, ts := range s.scans {
+			if !resultMetricsT0.Add(time.Duration(ts.created) * time.Hour).Before(from) {
+				scan := ts.scan()
+				scans = append(scans, fmt.Sprintf(`{"id": "%v", "status": "%v", "branch": "%v", "projectId": "p1", "createdAt": "%v", "updatedAt": "%v", "engines": ["sast"]}`,
+					scan.ScanID, scan.Status, scan.Branch, scan.CreatedAt, scan.UpdatedAt))
+			}
+		}
+		fmt.Fprintf(w, `{"filteredTotalCount": %d, "scans": [%v]}`, len(scans), strings.Join(scans, ","))
+	case "/api/results/":
+		for _, ts := range s.scans {
+			if ts.id == r.URL.Query().Get("scan-id") {
+				w.Write([]byte(ts.resultsJSON()))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestResultMetrics(t *testing.T, scans []testMetricsScan) (*ResultMetrics, *testMetricsServer) {
+	handler := &testMetricsServer{scans: scans}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := &Cx1Client{
+		httpClient: server.Client(),
+		baseUrl:    server.URL,
+		logger:     testLogger{t},
+		auth:       Cx1ClientAuth{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+	}
+	return NewResultMetrics(client, ResultMetricsOptions{Since: resultMetricsT0.Add(-time.Hour)}), handler
+}
+
+// returns "open" or "remediated" with the number of remediations and recurrences, or "missing"
+func describeMetricsFinding(m *ResultMetrics, branch, similarityID string) string {
+	finding, ok := m.state.Findings[fmt.Sprintf("p1|%v|sast|%v", branch, similarityID)]
+	if !ok {
+		return "missing"
+	}
+	status := "remediated"
+	if finding.IsOpen() {
+		status = "open"
+	}
+	return fmt.Sprintf("%v %d/%d", status, finding.Remediations, finding.Recurrences)
+}
+
+func TestResultMetricsProcessScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		scans    []testMetricsScan // processed in this order
+		findings map[string]string // branch|similarityID -> describeMetricsFinding
+	}{
+		{
+			name: "absent finding is remediated",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a", "b"}},
+				{id: "s2", created: 3, completed: 4, results: []string{"a"}},
+			},
+			findings: map[string]string{"main|a": "open 0/0", "main|b": "remediated 1/0"},
+		},
+		{
+			name: "first scan of another branch does not remediate",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s2", branch: "dev", created: 3, completed: 4},
+			},
+			findings: map[string]string{"main|a": "open 0/0"},
+		},
+		{
+			name: "engine that did not run does not remediate",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s2", created: 3, completed: 4, engines: []string{"sca"}},
+			},
+			findings: map[string]string{"main|a": "open 0/0"},
+		},
+		{
+			name: "partial scan with a failed engine does not remediate",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s2", created: 3, completed: 4, status: "Partial", details: []ScanStatusDetails{{Name: "sast", Status: "Failed"}}},
+			},
+			findings: map[string]string{"main|a": "open 0/0"},
+		},
+		{
+			name: "partial scan with a completed engine remediates",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s2", created: 3, completed: 4, status: "Partial", details: []ScanStatusDetails{{Name: "sast", Status: "Completed"}}},
+			},
+			findings: map[string]string{"main|a": "remediated 1/0"},
+		},
+		{
+			name: "stale out-of-order scan only adds findings",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s3", created: 5, completed: 6, results: []string{"a"}},
+				{id: "s2", created: 3, completed: 7, results: []string{"b"}}, // created before s3, finished after it
+			},
+			findings: map[string]string{"main|a": "open 0/0", "main|b": "open 0/0"},
+		},
+		{
+			name: "remediated finding recurs",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s2", created: 3, completed: 4},
+				{id: "s3", created: 5, completed: 6, results: []string{"a"}},
+			},
+			findings: map[string]string{"main|a": "open 1/1"},
+		},
+		{
+			name: "remediated state and recurrence when the state changes back",
+			scans: []testMetricsScan{
+				{id: "s1", created: 1, completed: 2, results: []string{"a"}},
+				{id: "s2", created: 3, completed: 4, results: []string{"a:NOT_EXPLOITABLE"}},
+				{id: "s3", created: 5, completed: 6, results: []string{"a:NOT_EXPLOITABLE"}},
+				{id: "s4", created: 7, completed: 8, results: []string{"a:CONFIRMED"}},
+			},
+			findings: map[string]string{"main|a": "open 1/1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, _ := newTestResultMetrics(t, test.scans)
+			for _, ts := range test.scans {
+				scan := ts.scan()
+				created, _ := time.Parse(time.RFC3339, scan.CreatedAt)
+				completed, _ := time.Parse(time.RFC3339, scan.UpdatedAt)
+				if err := m.processScan(&scan, created, completed); err != nil {
+					t.Fatalf("processScan(%v): %s", scan.ScanID, err)
+				}
+			}
+
+			if len(m.state.Findings) != len(test.findings) {
+				t.Errorf("expected %d findings, got %d", len(test.findings), len(m.state.Findings))
+			}
+			for key, expected := range test.findings {
+				branch, similarityID, _ := strings.Cut(key, "|")
+				if got := describeMetricsFinding(m, branch, similarityID); got != expected {
+					t.Errorf("finding %v: expected %v, got %v", key, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestResultMetricsEngineCompleted(t *testing.T) {
+	tests := []struct {
+		name       string
+		scan       Scan
+		resultType string
+		expected   bool
+	}{
+		{"completed scan with the engine", Scan{Status: "Completed", Engines: []string{"sast", "sca"}}, "sast", true},
+		{"completed scan without the engine", Scan{Status: "Completed", Engines: []string{"sca"}}, "sast", false},
+		{"unknown result type", Scan{Status: "Completed", Engines: []string{"sast"}}, "sscs-secret-detection", false},
+		{"iac results from kics", Scan{Status: "Completed", Engines: []string{"kics"}}, "kics", true},
+		{"partial scan with the engine completed", Scan{Status: "Partial", Engines: []string{"sast"}, StatusDetails: []ScanStatusDetails{{Name: "sast", Status: "Completed"}}}, "sast", true},
+		{"partial scan with the engine failed", Scan{Status: "Partial", Engines: []string{"sast"}, StatusDetails: []ScanStatusDetails{{Name: "sast", Status: "Failed"}}}, "sast", false},
+		{"partial scan without details", Scan{Status: "Partial", Engines: []string{"sast"}}, "sast", false},
+	}
+
+	for _, test := range tests {
+		if got := resultMetricsEngineCompleted(&test.scan, test.resultType); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestResultMetricsUpdateOverlap(t *testing.T) {
+	m, server := newTestResultMetrics(t, []testMetricsScan{
+		{id: "s1", created: 0, completed: 1, results: []string{"a"}},
+		{id: "s2", created: 2, completed: 3, results: []string{"a", "b"}},
+	})
+
+	if n, err := m.Update(); err != nil || n != 2 {
+		t.Fatalf("first Update: processed %d scans, error %v", n, err)
+	}
+	if !m.state.LastScanAt.Equal(resultMetricsT0.Add(3 * time.Hour)) {
+		t.Errorf("expected LastScanAt at the latest completion, got %v", m.state.LastScanAt)
+	}
+
+	// a long-running scan created before the last completion is listed through the overlap, processed scans are skipped
+	server.mutex.Lock()
+	server.scans = append(server.scans, testMetricsScan{id: "s3", created: 1, completed: 5, results: []string{"c"}})
+	server.mutex.Unlock()
+	if n, err := m.Update(); err != nil || n != 1 {
+		t.Fatalf("second Update: processed %d scans, error %v", n, err)
+	}
+	if from := server.from[len(server.from)-1]; !from.Equal(resultMetricsT0.Add(3*time.Hour - m.options.Overlap)) {
+		t.Errorf("expected scans to be listed from LastScanAt minus the overlap, got %v", from)
+	}
+	if got := describeMetricsFinding(m, "main", "b"); got != "open 0/0" {
+		t.Errorf("the stale scan s3 should not remediate b, got %v", got)
+	}
+	if got := describeMetricsFinding(m, "main", "c"); got != "open 0/0" {
+		t.Errorf("the stale scan s3 should add c, got %v", got)
+	}
+
+	// scans which completed before the overlap window are pruned
+	server.mutex.Lock()
+	server.scans = append(server.scans, testMetricsScan{id: "s4", created: 50, completed: 51, results: []string{"a"}})
+	server.mutex.Unlock()
+	if n, err := m.Update(); err != nil || n != 1 {
+		t.Fatalf("third Update: processed %d scans, error %v", n, err)
+	}
+	scans := []string{}
+	for id := range m.state.Scans {
+		scans = append(scans, id)
+	}
+	if len(scans) != 1 || scans[0] != "s4" {
+		t.Errorf("expected only s4 to be kept in the overlap window, got %v", scans)
+	}
+	if got := describeMetricsFinding(m, "main", "b"); got != "remediated 1/0" {
+		t.Errorf("expected b to be remediated by s4, got %v", got)
+	}
+}
+
+func TestResultMetricsSaveLoad(t *testing.T) {
+	scans := []testMetricsScan{
+		{id: "s1", created: 1, completed: 2, results: []string{"a", "b"}},
+		{id: "s2", created: 3, completed: 4, results: []string{"a"}},
+		{id: "s3", created: 5, completed: 6},
+	}
+	m, _ := newTestResultMetrics(t, scans)
+	for _, ts := range scans[:2] {
+		scan := ts.scan()
+		if err := m.processScan(&scan, resultMetricsT0.Add(time.Duration(ts.created)*time.Hour), resultMetricsT0.Add(time.Duration(ts.completed)*time.Hour)); err != nil {
+			t.Fatalf("processScan(%v): %s", ts.id, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := m.Save(&buf); err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+	saved := buf.String()
+
+	loaded, _ := newTestResultMetrics(t, scans)
+	if err := loaded.Load(strings.NewReader(saved)); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	findings := func(m *ResultMetrics) []string {
+		list := []string{}
+		for _, f := range m.GetFindings() {
+			list = append(list, fmt.Sprintf("%v %v %v %v %v", f.SimilarityID, f.Query, f.LastScanID, f.RemediatedAt.Format(time.RFC3339), f.TimeToRemediate))
+		}
+		sort.Strings(list)
+		return list
+	}
+	if a, b := findings(m), findings(loaded); strings.Join(a, ";") != strings.Join(b, ";") {
+		t.Errorf("loaded findings differ:\n%v\n%v", a, b)
+	}
+	if !loaded.state.LastScanAt.Equal(m.state.LastScanAt) || len(loaded.state.Scans) != 2 || !loaded.state.Branches["p1|main"] {
+		t.Errorf("loaded state differs: %+v", loaded.state)
+	}
+
+	// the open findings index is rebuilt after Load, so a later scan still remediates a by absence
+	scan := scans[2].scan()
+	if err := loaded.processScan(&scan, resultMetricsT0.Add(5*time.Hour), resultMetricsT0.Add(6*time.Hour)); err != nil {
+		t.Fatalf("processScan(s3): %s", err)
+	}
+	if got := describeMetricsFinding(loaded, "main", "a"); got != "remediated 1/0" {
+		t.Errorf("expected a to be remediated after Load, got %v", got)
+	}
+
+	if err := loaded.Load(strings.NewReader("{invalid")); err == nil || loaded.Count() != 0 {
+		t.Errorf("expected an invalid state to fail and reset, got error %v with %d findings", err, loaded.Count())
+	}
+}
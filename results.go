@@ -44,21 +44,25 @@ func (c Cx1Client) GetScanResultsCountByID(scanID string) (uint64, error) {
 
 // returns one 'page' of scan results matching the filter
 // returns items (filter.Offset*filter.Limit) to (filter.Offset + 1)*filter.Limit
-// returns the count of items retrieved, however some items may not be parsed into the result
-// set depending on support in cx1clientgo
+// returns the total count of items matching the filter, results of types without a registered decoder
+// are returned in the Raw list of the result set
 func (c Cx1Client) GetScanResultsFiltered(filter ScanResultsFilter) (uint64, ScanResultSet, error) {
 	params, _ := query.Values(filter)
 
 	results := ScanResultSet{}
 
-	data, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/results/?%v", params.Encode()), nil, nil)
+	response, err := c.sendRequestRawCx1(http.MethodGet, fmt.Sprintf("/results/?%v", params.Encode()), nil, nil)
 	if err != nil {
+		if response != nil && response.Body != nil {
+			response.Body.Close()
+		}
 		err = fmt.Errorf("failed to fetch scans matching filter %v: %s", params.Encode(), err)
 		c.logger.Tracef("Error: %s", err)
 		return 0, results, err
 	}
+	defer response.Body.Close()
 
-	count, results, err := c.parseScanResults(data)
+	count, results, err := c.decodeScanResults(response.Body)
 	return count, results, err
 }
 
@@ -67,8 +71,8 @@ func (s *ScanResultsFilter) Bump() { // this one does offset in pages rather tha
 }
 
 // gets all of the results available matching a filter
-// the counter returned represents the total number of results which were parsed
-// this may not include some of the returned results depending on Cx1ClientGo support
+// the counter returned represents the total number of results which were parsed, including those in Raw:
+// results of unknown types or which failed to decode are kept in ScanResultSet.Raw rather than dropped
 func (c Cx1Client) GetAllScanResultsFiltered(filter ScanResultsFilter) (uint64, ScanResultSet, error) {
 	var results ScanResultSet
 
@@ -320,11 +324,11 @@ func (c Cx1Client) GetScanSASTResultSummary(results *ScanResultSet) ScanResultSu
 }
 
 func (s ScanResultSet) String() string {
-	return fmt.Sprintf("Result set with %d SAST, %d SCA, %d SCAContainer, %d IAC, and %d Containers results", len(s.SAST), len(s.SCA), len(s.SCAContainer), len(s.IAC), len(s.Containers))
+	return fmt.Sprintf("Result set with %d SAST, %d SCA, %d SCAContainer, %d IAC, %d Containers, %d SecretDetection, %d Scorecard, and %d other results", len(s.SAST), len(s.SCA), len(s.SCAContainer), len(s.IAC), len(s.Containers), len(s.SecretDetection), len(s.Scorecard), len(s.Raw))
 }
 
 func (s ScanResultSet) Count() uint64 {
-	return uint64(len(s.SAST) + len(s.SCA) + len(s.SCAContainer) + len(s.IAC) + len(s.Containers))
+	return uint64(len(s.SAST) + len(s.SCA) + len(s.SCAContainer) + len(s.IAC) + len(s.Containers) + len(s.SecretDetection) + len(s.Scorecard) + len(s.Raw))
 }
 
 func (s *ScanResultSet) Append(results *ScanResultSet) {
@@ -343,6 +347,15 @@ func (s *ScanResultSet) Append(results *ScanResultSet) {
 	if len(results.Containers) > 0 {
 		s.Containers = append(s.Containers, results.Containers...)
 	}
+	if len(results.SecretDetection) > 0 {
+		s.SecretDetection = append(s.SecretDetection, results.SecretDetection...)
+	}
+	if len(results.Scorecard) > 0 {
+		s.Scorecard = append(s.Scorecard, results.Scorecard...)
+	}
+	if len(results.Raw) > 0 {
+		s.Raw = append(s.Raw, results.Raw...)
+	}
 }
 
 func (s ScanResultStatusSummary) Total() uint64 {
@@ -362,86 +375,6 @@ func (s ScanResultSummary) String() string {
 			s.High.NotExploitable+s.Medium.NotExploitable+s.Low.NotExploitable+s.Information.NotExploitable))
 }
 
-// Note: response.TotalCount may be greater than the resultset, due to limited cx1clientgo engine support
-func (c Cx1Client) parseScanResults(response []byte) (uint64, ScanResultSet, error) {
-	var resultResponse struct {
-		Results    []map[string]interface{}
-		TotalCount uint64
-	}
-
-	var ResultSet ScanResultSet
-
-	dec := json.NewDecoder(bytes.NewReader(response))
-	dec.UseNumber()
-	err := dec.Decode(&resultResponse)
-	if err != nil {
-		c.logger.Tracef("Failed while parsing response: %s", err)
-		//c.logger.Tracef("Response contents: %s", string(response))
-		return resultResponse.TotalCount, ResultSet, err
-	}
-	//c.logger.Debugf("Retrieved %d results", resultResponse.TotalCount)
-
-	/*
-		if uint64(len(resultResponse.Results)) != resultResponse.TotalCount {
-			c.logger.Warnf("Expected results total count %d but parsed only %d", resultResponse.TotalCount, len(resultResponse.Results))
-			c.logger.Tracef("Response was: %v", string(response))
-		}
-	*/
-
-	for _, r := range resultResponse.Results {
-		//c.logger.Infof("Result %v: %v", r["similarityId"].(string), r["type"].(string))
-		jsonResult, _ := json.Marshal(r)
-		switch r["type"].(string) {
-		case "sast":
-			var SASTResult ScanSASTResult
-			err := json.Unmarshal(jsonResult, &SASTResult)
-			if err != nil {
-				c.logger.Warnf("Failed to unmarshal result %v to SAST type: %s", r["similarityId"].(string), err)
-			} else {
-				ResultSet.SAST = append(ResultSet.SAST, SASTResult)
-			}
-		case "sca":
-			var SCAResult ScanSCAResult
-			err := json.Unmarshal(jsonResult, &SCAResult)
-			if err != nil {
-				c.logger.Warnf("Failed to unmarshal result %v to SCA type: %s", r["similarityId"].(string), err)
-			} else {
-				ResultSet.SCA = append(ResultSet.SCA, SCAResult)
-			}
-		case "kics":
-			var IACResult ScanIACResult
-			err := json.Unmarshal(jsonResult, &IACResult)
-			if err != nil {
-				c.logger.Warnf("Failed to unmarshal result %v to IAC type: %s", r["similarityId"].(string), err)
-			} else {
-				ResultSet.IAC = append(ResultSet.IAC, IACResult)
-			}
-		case "sca-container":
-			var SCACResult ScanSCAContainerResult
-			err := json.Unmarshal(jsonResult, &SCACResult)
-			if err != nil {
-				c.logger.Warnf("Failed to unmarshal result %v to SCAContainer type: %s", r["similarityId"].(string), err)
-			} else {
-				ResultSet.SCAContainer = append(ResultSet.SCAContainer, SCACResult)
-			}
-		case "containers":
-			var ContainerResult ScanContainersResult
-			err := json.Unmarshal(jsonResult, &ContainerResult)
-			if err != nil {
-				c.logger.Warnf("Failed to unmarshal result %v to Containers type: %s", r["similarityId"].(string), err)
-			} else {
-				ResultSet.Containers = append(ResultSet.Containers, ContainerResult)
-			}
-		default:
-			c.logger.Warnf("Unable to unmarshal result %v of unknown type %v", r["similarityId"].(string), r["type"].(string))
-		}
-	}
-
-	c.logger.Debugf("Retrieved %d of %d results", ResultSet.Count(), resultResponse.TotalCount)
-
-	return resultResponse.TotalCount, ResultSet, nil
-}
-
 func (b ResultsPredicatesBase) String() string {
 	return fmt.Sprintf("[%v] %v set severity %v, state %v, comment %v", b.CreatedAt, b.CreatedBy, b.Severity, b.State, b.Comment)
 }
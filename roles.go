@@ -50,7 +50,7 @@ func (c Cx1Client) GetIAMRoles() ([]Role, error) {
 func (c Cx1Client) GetIAMRoleByName(name string) (Role, error) {
 	c.logger.Debugf("Getting KeyCloak Role named %v", name)
 	var role Role
-	response, err := c.sendRequestIAM(http.MethodGet, "/auth/admin", fmt.Sprintf("/roles/%v", url.QueryEscape(name)), nil, nil)
+	response, err := c.sendCachedRequestIAM("/auth/admin", fmt.Sprintf("/roles/%v", url.QueryEscape(name)))
 	if err != nil {
 		return role, err
 	}
@@ -102,7 +102,7 @@ func (c Cx1Client) GetRolesByClientIDAndName(clientId string, name string) ([]Ro
 	c.logger.Debugf("Getting KeyCloak Roles for client %v with name matching %v", clientId, name)
 	var roles []Role
 
-	response, err := c.sendRequestIAM(http.MethodGet, "/auth/admin", fmt.Sprintf("/clients/%v/roles?search=%v&briefRepresentation=false", clientId, url.PathEscape(name)), nil, nil)
+	response, err := c.sendCachedRequestIAM("/auth/admin", fmt.Sprintf("/clients/%v/roles?search=%v&briefRepresentation=false", clientId, url.PathEscape(name)))
 	if err != nil {
 		return roles, err
 	}
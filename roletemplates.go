@@ -0,0 +1,549 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Custom ast-app roles declared as templates, for example in roles.yaml:
+
+		- name: appsec-reviewer
+		  description: Can view and triage results
+		  creator: platform-team
+		  extends: [ ast-viewer ]
+		  composites: [ update-result-states-propose ]
+
+	All roles referenced by a template (extends and composites) are ast-app client roles.
+	Roles listed in extends are expanded to their current permissions when the template is applied, so re-applying the
+	template after Checkmarx adds permissions to a built-in role upgrades the custom role. Roles listed in composites
+	are added as-is.
+
+	To find custom roles that were not created from templates but lack newly-introduced permissions, take a RoleSnapshot
+	of the built-in roles (and save it as JSON), then later use GetRolePermissionGaps with that snapshot.
+*/
+
+const (
+	roleTemplateDefaultType     = "Role"
+	roleTemplateDefaultCategory = "Composite role"
+)
+
+// Loads a list of role templates from a YAML or JSON file
+func LoadRoleTemplates(path string) ([]RoleTemplate, error) {
+	var templates []RoleTemplate
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return templates, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&templates); err != nil {
+		return templates, fmt.Errorf("failed to parse role templates in %v: %s", path, err)
+	}
+
+	for id, t := range templates {
+		if t.Name == "" {
+			return templates, fmt.Errorf("role template %d in %v has no name", id, path)
+		}
+	}
+	return templates, nil
+}
+
+func WriteRoleTemplates(w io.Writer, templates []RoleTemplate) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(templates); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Returns true for roles created by Checkmarx rather than by a tenant user
+func (r *Role) IsBuiltin() bool {
+	for _, creator := range r.Attributes.Creator {
+		if strings.EqualFold(creator, "Checkmarx") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a template describing an existing ast-app role
+func (c Cx1Client) GetRoleTemplate(name string) (RoleTemplate, error) {
+	t := RoleTemplate{Name: name}
+
+	role, err := c.GetAppRoleByName(name)
+	if err != nil {
+		return t, err
+	}
+	composites, err := c.GetRoleComposites(&role)
+	if err != nil {
+		return t, err
+	}
+
+	t.Description = role.Description
+	t.Creator = firstAttribute(role.Attributes.Creator)
+	t.Type = firstAttribute(role.Attributes.Type)
+	t.Category = firstAttribute(role.Attributes.Category)
+	for _, r := range composites {
+		t.Composites = append(t.Composites, r.Name)
+	}
+	sort.Strings(t.Composites)
+	return t, nil
+}
+
+func firstAttribute(values []string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// returns the names of the composite roles the template resolves to, with extended roles expanded to their permissions
+func (c Cx1Client) getRoleTemplateComposites(t RoleTemplate) ([]string, error) {
+	composites := []string{}
+	for _, name := range t.Composites {
+		if !slices.Contains(composites, name) {
+			composites = append(composites, name)
+		}
+	}
+
+	for _, name := range t.Extends {
+		role, err := c.GetAppRoleByName(name)
+		if err != nil {
+			return composites, fmt.Errorf("extended role %v: %s", name, err)
+		}
+		permissions, err := c.getRolePermissions(&role)
+		if err != nil {
+			return composites, err
+		}
+		for _, p := range permissions {
+			if !slices.Contains(composites, p) {
+				composites = append(composites, p)
+			}
+		}
+	}
+
+	sort.Strings(composites)
+	return composites, nil
+}
+
+// returns the names of the non-composite roles contained in the role
+func (c Cx1Client) getRolePermissions(role *Role) ([]string, error) {
+	permissions := []string{}
+	if !role.Composite {
+		return []string{role.Name}, nil
+	}
+
+	all, err := c.GetAllRoleComposites(role)
+	if err != nil {
+		return permissions, fmt.Errorf("failed to get composites of role %v: %s", role.String(), err)
+	}
+	for _, r := range all {
+		if !r.Composite && !slices.Contains(permissions, r.Name) {
+			permissions = append(permissions, r.Name)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// Compares the template with the existing role, nothing is changed
+func (c Cx1Client) DiffRoleTemplate(t RoleTemplate) (RoleTemplateDiff, error) {
+	diff := RoleTemplateDiff{Template: t.Name}
+
+	desired, err := c.getRoleTemplateComposites(t)
+	if err != nil {
+		return diff, err
+	}
+
+	role, err := c.GetAppRoleByName(t.Name)
+	if err != nil {
+		c.logger.Tracef("Role %v does not exist: %s", t.Name, err)
+		diff.MissingComposites = desired
+		return diff, nil
+	}
+	diff.Exists = true
+	diff.RoleID = role.RoleID
+
+	compare := func(attribute, current, wanted string) {
+		if current != wanted {
+			diff.AttributeChanges = append(diff.AttributeChanges, fmt.Sprintf("%v: '%v' -> '%v'", attribute, current, wanted))
+		}
+	}
+	compare("description", role.Description, t.Description)
+	if t.Creator != "" {
+		compare("creator", firstAttribute(role.Attributes.Creator), t.Creator)
+	}
+	compare("type", firstAttribute(role.Attributes.Type), t.getType())
+	compare("category", firstAttribute(role.Attributes.Category), t.getCategory())
+
+	composites, err := c.GetRoleComposites(&role)
+	if err != nil {
+		return diff, err
+	}
+	current := []string{}
+	for _, r := range composites {
+		current = append(current, r.Name)
+	}
+	for _, name := range desired {
+		if !slices.Contains(current, name) {
+			diff.MissingComposites = append(diff.MissingComposites, name)
+		}
+	}
+	for _, name := range current {
+		if !slices.Contains(desired, name) {
+			diff.ExtraComposites = append(diff.ExtraComposites, name)
+		}
+	}
+	sort.Strings(diff.ExtraComposites)
+
+	return diff, nil
+}
+
+func (t RoleTemplate) getType() string {
+	if t.Type == "" {
+		return roleTemplateDefaultType
+	}
+	return t.Type
+}
+
+func (t RoleTemplate) getCategory() string {
+	if t.Category == "" {
+		return roleTemplateDefaultCategory
+	}
+	return t.Category
+}
+
+/*
+Creates or updates the role to match the template. Composites that are not in the template are only removed if
+removeExtra is true. The returned diff describes the state before the template was applied.
+*/
+func (c Cx1Client) ApplyRoleTemplate(t RoleTemplate, removeExtra bool) (RoleTemplateDiff, error) {
+	diff, err := c.DiffRoleTemplate(t)
+	if err != nil {
+		diff.Error = err.Error()
+		return diff, err
+	}
+
+	fail := func(err error) (RoleTemplateDiff, error) {
+		diff.Error = err.Error()
+		return diff, fmt.Errorf("failed to apply role template %v: %s", t.Name, err)
+	}
+
+	var role Role
+	if !diff.Exists {
+		creator := t.Creator
+		if creator == "" {
+			creator = c.currentPrincipal()
+		}
+		c.logger.Infof("Creating role %v from template", t.Name)
+		role, err = c.CreateAppRole(t.Name, creator)
+		if err != nil {
+			return fail(err)
+		}
+		diff.RoleID = role.RoleID
+	} else {
+		role, err = c.GetRoleByID(diff.RoleID)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if !diff.Exists || len(diff.AttributeChanges) > 0 {
+		if err = c.updateRoleFromTemplate(role, t); err != nil {
+			return fail(err)
+		}
+	}
+
+	if len(diff.MissingComposites) > 0 {
+		add := []Role{}
+		for _, name := range diff.MissingComposites {
+			r, err := c.GetAppRoleByName(name)
+			if err != nil {
+				return fail(err)
+			}
+			add = append(add, r)
+		}
+		if err = c.AddRoleComposites(&role, &add); err != nil {
+			return fail(err)
+		}
+	}
+
+	if removeExtra && len(diff.ExtraComposites) > 0 {
+		remove := []Role{}
+		for _, name := range diff.ExtraComposites {
+			r, err := c.GetAppRoleByName(name)
+			if err != nil {
+				return fail(err)
+			}
+			remove = append(remove, r)
+		}
+		if err = c.RemoveRoleComposites(&role, &remove); err != nil {
+			return fail(err)
+		}
+	}
+
+	diff.Applied = true
+	c.logger.Infof("Applied %v", diff.String())
+	return diff, nil
+}
+
+// Applies all templates, continuing with the next template if one fails
+func (c Cx1Client) ApplyRoleTemplates(templates []RoleTemplate, removeExtra bool) ([]RoleTemplateDiff, error) {
+	diffs := []RoleTemplateDiff{}
+	failed := 0
+	for _, t := range templates {
+		diff, err := c.ApplyRoleTemplate(t, removeExtra)
+		if err != nil {
+			c.logger.Errorf("%s", err)
+			failed++
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if failed > 0 {
+		return diffs, fmt.Errorf("%d of %d role templates failed to apply", failed, len(templates))
+	}
+	return diffs, nil
+}
+
+// Applies the templates to each tenant, the results are keyed by tenant name
+func ApplyRoleTemplatesToTenants(clients []*Cx1Client, templates []RoleTemplate, removeExtra bool) (map[string][]RoleTemplateDiff, error) {
+	results := make(map[string][]RoleTemplateDiff)
+	failed := []string{}
+	for _, c := range clients {
+		diffs, err := c.ApplyRoleTemplates(templates, removeExtra)
+		results[c.tenant] = diffs
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%v: %s", c.tenant, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("role templates failed on %d tenants: %v", len(failed), strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+func (c Cx1Client) updateRoleFromTemplate(role Role, t RoleTemplate) error {
+	creator := t.Creator
+	if creator == "" {
+		creator = firstAttribute(role.Attributes.Creator)
+	}
+
+	data := map[string]interface{}{
+		"name":        role.Name,
+		"description": t.Description,
+		"composite":   true,
+		"clientRole":  true,
+		"attributes": map[string]interface{}{
+			"category":   []string{t.getCategory()},
+			"type":       []string{t.getType()},
+			"creator":    []string{creator},
+			"lastUpdate": []int64{time.Now().UnixMilli()},
+		},
+	}
+	jsonBody, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sendRequestIAM(http.MethodPut, "/auth/admin", fmt.Sprintf("/roles-by-id/%v", role.RoleID), bytes.NewReader(jsonBody), nil)
+	return err
+}
+
+func (d RoleTemplateDiff) IsEmpty() bool {
+	return d.Exists && len(d.AttributeChanges) == 0 && len(d.MissingComposites) == 0 && len(d.ExtraComposites) == 0
+}
+
+func (d RoleTemplateDiff) String() string {
+	if !d.Exists {
+		return fmt.Sprintf("role %v: create with %d composites", d.Template, len(d.MissingComposites))
+	}
+	if d.IsEmpty() {
+		return fmt.Sprintf("role %v: up to date", d.Template)
+	}
+	parts := []string{}
+	if len(d.AttributeChanges) > 0 {
+		parts = append(parts, strings.Join(d.AttributeChanges, ", "))
+	}
+	if len(d.MissingComposites) > 0 {
+		parts = append(parts, fmt.Sprintf("add %v", strings.Join(d.MissingComposites, ", ")))
+	}
+	if len(d.ExtraComposites) > 0 {
+		parts = append(parts, fmt.Sprintf("extra %v", strings.Join(d.ExtraComposites, ", ")))
+	}
+	return fmt.Sprintf("role %v: %v", d.Template, strings.Join(parts, "; "))
+}
+
+// Returns the current permissions of each built-in composite ast-app role
+func (c Cx1Client) GetRoleSnapshot() (RoleSnapshot, error) {
+	snapshot := RoleSnapshot{
+		Tenant: c.tenant,
+		Taken:  time.Now().UTC(),
+		Roles:  make(map[string][]string),
+	}
+
+	roles, err := c.getFullAppRoles()
+	if err != nil {
+		return snapshot, err
+	}
+
+	for _, role := range roles {
+		if !role.Composite || !role.IsBuiltin() {
+			continue
+		}
+		permissions, err := c.getRolePermissions(&role)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.Roles[role.Name] = permissions
+	}
+	return snapshot, nil
+}
+
+// brief representation does not include the attributes
+func (c Cx1Client) getFullAppRoles() ([]Role, error) {
+	roles, err := c.GetAppRoles()
+	if err != nil {
+		return roles, err
+	}
+	for id := range roles {
+		if roles[id], err = c.GetRoleByID(roles[id].RoleID); err != nil {
+			return roles, err
+		}
+	}
+	return roles, nil
+}
+
+// Returns the permissions added to each built-in role since the previous snapshot
+func (c Cx1Client) GetNewBuiltinPermissions(previous RoleSnapshot) (map[string][]string, error) {
+	added := make(map[string][]string)
+
+	current, err := c.GetRoleSnapshot()
+	if err != nil {
+		return added, err
+	}
+
+	for name, permissions := range current.Roles {
+		before, ok := previous.Roles[name]
+		if !ok {
+			continue
+		}
+		for _, p := range permissions {
+			if !slices.Contains(before, p) {
+				added[name] = append(added[name], p)
+			}
+		}
+	}
+	return added, nil
+}
+
+/*
+Returns the custom roles that lack permissions added to built-in roles since the previous snapshot.
+A custom role is considered to be based on a built-in role if it contains all the permissions that the built-in role
+had in the previous snapshot.
+*/
+func (c Cx1Client) GetRolePermissionGaps(previous RoleSnapshot) ([]RolePermissionGap, error) {
+	gaps := []RolePermissionGap{}
+
+	added, err := c.GetNewBuiltinPermissions(previous)
+	if err != nil {
+		return gaps, err
+	}
+	if len(added) == 0 {
+		return gaps, nil
+	}
+
+	roles, err := c.getFullAppRoles()
+	if err != nil {
+		return gaps, err
+	}
+
+	builtins := make([]string, 0, len(added))
+	for name := range added {
+		builtins = append(builtins, name)
+	}
+	sort.Strings(builtins)
+
+	for _, role := range roles {
+		if !role.Composite || role.IsBuiltin() {
+			continue
+		}
+		permissions, err := c.getRolePermissions(&role)
+		if err != nil {
+			return gaps, err
+		}
+
+		for _, builtin := range builtins {
+			if !containsAll(permissions, previous.Roles[builtin]) {
+				continue
+			}
+			gap := RolePermissionGap{Role: role.Name, RoleID: role.RoleID, BasedOn: builtin}
+			for _, p := range added[builtin] {
+				if !slices.Contains(permissions, p) {
+					gap.Missing = append(gap.Missing, p)
+				}
+			}
+			if len(gap.Missing) > 0 {
+				gaps = append(gaps, gap)
+			}
+		}
+	}
+
+	return gaps, nil
+}
+
+func containsAll(list, subset []string) bool {
+	for _, s := range subset {
+		if !slices.Contains(list, s) {
+			return false
+		}
+	}
+	return len(subset) > 0
+}
+
+// Adds the missing permissions to the custom roles
+func (c Cx1Client) UpgradeCustomRoles(gaps []RolePermissionGap) error {
+	for _, gap := range gaps {
+		role, err := c.GetRoleByID(gap.RoleID)
+		if err != nil {
+			return err
+		}
+
+		add := []Role{}
+		for _, name := range gap.Missing {
+			r, err := c.GetAppRoleByName(name)
+			if err != nil {
+				return fmt.Errorf("permission %v: %s", name, err)
+			}
+			add = append(add, r)
+		}
+		if len(add) == 0 {
+			continue
+		}
+
+		c.logger.Infof("Adding %v to role %v (based on %v)", strings.Join(gap.Missing, ", "), role.String(), gap.BasedOn)
+		if err = c.AddRoleComposites(&role, &add); err != nil {
+			return fmt.Errorf("failed to upgrade role %v: %s", role.String(), err)
+		}
+	}
+	return nil
+}
+
+func (g RolePermissionGap) String() string {
+	return fmt.Sprintf("role %v (based on %v) is missing: %v", g.Role, g.BasedOn, strings.Join(g.Missing, ", "))
+}
@@ -0,0 +1,401 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	SCA deep-dive: the /results endpoint only returns one flat entry per vulnerable package, so the package inventory,
+	dependency paths and license data come from the SCA export service (ScanReportJson format) instead.
+
+	GetSCAScanReportByID requests the export, polls until it is ready and parses it into an SCAScanReport, which can
+	then be queried for:
+	- the full package inventory, split into direct and transitive dependencies
+	- a dependency tree rooted at the direct dependencies, and the paths from direct dependencies to any package
+	- vulnerabilities per package or CVE, including exploitable methods where exploitable path analysis ran
+	- licenses with their risk levels
+	- upgrade recommendations, answering "which direct dependency do I bump to fix CVE-X"
+*/
+
+const (
+	SCAExportFormatJSON = "ScanReportJson"
+	SCAExportFormatXML  = "ScanReportXml"
+	SCAExportFormatCSV  = "ScanReportCsv"
+	SCAExportFormatPDF  = "ScanReportPdf"
+	SCAExportFormatSBOM = "CycloneDxJson"
+	SCAExportFormatSPDX = "SpdxJson"
+
+	scaExportStatusDone   = "Completed"
+	scaExportStatusFailed = "Failed"
+)
+
+var scaLicenseRiskLevels = map[string]int{
+	"none":   1,
+	"low":    2,
+	"medium": 3,
+	"high":   4,
+}
+
+// Requests an export of the SCA results of a scan in the given format (one of the SCAExportFormat* constants)
+// Returns the export ID, use SCAExportPollingByID to wait for it
+func (c Cx1Client) RequestSCAExportByScanID(scanID, fileFormat string, hideDevAndTestDependencies bool) (string, error) {
+	c.logger.Debugf("Requesting %v SCA export for scan %v", fileFormat, scanID)
+	jsonData := map[string]interface{}{
+		"ScanId":     scanID,
+		"FileFormat": fileFormat,
+		"ExportParameters": map[string]interface{}{
+			"hideDevAndTestDependencies": hideDevAndTestDependencies,
+		},
+	}
+
+	jsonValue, _ := json.Marshal(jsonData)
+	data, err := c.sendRequest(http.MethodPost, "/sca/export/requests", bytes.NewReader(jsonValue), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to request SCA export for scan %v: %s", scanID, err)
+	}
+
+	var exportResponse struct {
+		ExportID string `json:"exportId"`
+	}
+	err = json.Unmarshal(data, &exportResponse)
+	return exportResponse.ExportID, err
+}
+
+func (c Cx1Client) GetSCAExportStatusByID(exportID string) (SCAExportStatus, error) {
+	var response SCAExportStatus
+
+	params := url.Values{"exportId": {exportID}}
+	data, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/sca/export/requests?%v", params.Encode()), nil, nil)
+	if err != nil {
+		return response, fmt.Errorf("failed to fetch SCA export status for export %v: %s", exportID, err)
+	}
+
+	err = json.Unmarshal(data, &response)
+	return response, err
+}
+
+// convenience function, polls until the export is complete
+func (c Cx1Client) SCAExportPollingByID(exportID string) error {
+	return c.SCAExportPollingByIDWithTimeout(exportID, c.consts.ReportPollingDelaySeconds, c.consts.ReportPollingMaxSeconds)
+}
+
+func (c Cx1Client) SCAExportPollingByIDWithTimeout(exportID string, delaySeconds, maxSeconds int) error {
+	pollingCounter := 0
+	for {
+		status, err := c.GetSCAExportStatusByID(exportID)
+		if err != nil {
+			return err
+		}
+
+		if status.ExportStatus == scaExportStatusDone {
+			return nil
+		} else if status.ExportStatus == scaExportStatusFailed {
+			return fmt.Errorf("SCA export %v failed: %v", ShortenGUID(exportID), status.ErrorMessage)
+		}
+
+		if maxSeconds != 0 && pollingCounter > maxSeconds {
+			return fmt.Errorf("SCA export %v polling reached %d seconds, aborting - use cx1client.get/setclientvars to change", ShortenGUID(exportID), pollingCounter)
+		}
+
+		time.Sleep(time.Duration(delaySeconds) * time.Second)
+		pollingCounter += delaySeconds
+	}
+}
+
+func (c Cx1Client) DownloadSCAExportByID(exportID string) ([]byte, error) {
+	data, err := c.sendRequest(http.MethodGet, fmt.Sprintf("/sca/export/requests/%v/download", exportID), nil, nil)
+	if err != nil {
+		return []byte{}, fmt.Errorf("failed to download SCA export %v: %s", exportID, err)
+	}
+	return data, nil
+}
+
+// Exports the SCA results of a scan as ScanReportJson and parses them, this includes dev & test dependencies
+func (c Cx1Client) GetSCAScanReportByID(scanID string) (SCAScanReport, error) {
+	report := SCAScanReport{ScanID: scanID}
+
+	exportID, err := c.RequestSCAExportByScanID(scanID, SCAExportFormatJSON, false)
+	if err != nil {
+		return report, err
+	}
+	if err = c.SCAExportPollingByID(exportID); err != nil {
+		return report, err
+	}
+	data, err := c.DownloadSCAExportByID(exportID)
+	if err != nil {
+		return report, err
+	}
+
+	report, err = ParseSCAScanReport(data)
+	report.ScanID = scanID
+	if err != nil {
+		return report, fmt.Errorf("failed to parse SCA export for scan %v: %s", scanID, err)
+	}
+
+	c.logger.Debugf("Retrieved SCA report for scan %v: %v", scanID, report.String())
+	return report, nil
+}
+
+// Parses an SCA ScanReportJson export, eg: one downloaded with DownloadSCAExportByID
+func ParseSCAScanReport(data []byte) (SCAScanReport, error) {
+	var report SCAScanReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, err
+	}
+
+	report.packageIndex = make(map[string]int, len(report.Packages))
+	for id := range report.Packages {
+		p := &report.Packages[id]
+		report.packageIndex[p.PackageID] = id
+		for pid := range p.DependencyPaths {
+			p.DependencyPaths[pid] = normalizeSCADependencyPath(p.DependencyPaths[pid], p.SCAPackageRef, p.IsDirectDependency)
+		}
+	}
+	return report, nil
+}
+
+// makes sure the path runs from the direct dependency to the package itself
+// an empty path is only the package itself for a direct dependency, for a transitive one the path is unknown and stays empty
+func normalizeSCADependencyPath(path []SCAPackageRef, pkg SCAPackageRef, direct bool) []SCAPackageRef {
+	if len(path) == 0 {
+		if direct {
+			return []SCAPackageRef{pkg}
+		}
+		return path
+	}
+	if path[len(path)-1].PackageID == pkg.PackageID {
+		return path
+	}
+	if path[0].PackageID == pkg.PackageID {
+		reversed := make([]SCAPackageRef, len(path))
+		for id := range path {
+			reversed[len(path)-1-id] = path[id]
+		}
+		return reversed
+	}
+	return append(path, pkg)
+}
+
+func (r SCAScanReport) GetPackage(packageID string) *SCAPackage {
+	if id, ok := r.packageIndex[packageID]; ok {
+		return &r.Packages[id]
+	}
+	for id := range r.Packages { // report not created with ParseSCAScanReport
+		if r.Packages[id].PackageID == packageID {
+			return &r.Packages[id]
+		}
+	}
+	return nil
+}
+
+func (r SCAScanReport) GetDirectDependencies() []SCAPackage {
+	packages := []SCAPackage{}
+	for _, p := range r.Packages {
+		if p.IsDirectDependency {
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+func (r SCAScanReport) GetTransitiveDependencies() []SCAPackage {
+	packages := []SCAPackage{}
+	for _, p := range r.Packages {
+		if !p.IsDirectDependency {
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+// Returns packages with at least one vulnerability that is not ignored
+func (r SCAScanReport) GetVulnerablePackages() []SCAPackage {
+	vulnerable := r.vulnerablePackageIDs()
+	packages := []SCAPackage{}
+	for _, p := range r.Packages {
+		if vulnerable[p.PackageID] {
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+func (r SCAScanReport) vulnerablePackageIDs() map[string]bool {
+	vulnerable := make(map[string]bool)
+	for _, v := range r.Vulnerabilities {
+		if !v.IsIgnored {
+			vulnerable[v.PackageID] = true
+		}
+	}
+	return vulnerable
+}
+
+func (r SCAScanReport) GetVulnerabilitiesByPackageID(packageID string) []SCAVulnerability {
+	vulns := []SCAVulnerability{}
+	for _, v := range r.Vulnerabilities {
+		if v.PackageID == packageID {
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns
+}
+
+func (r SCAScanReport) GetVulnerabilitiesByCVE(cveName string) []SCAVulnerability {
+	vulns := []SCAVulnerability{}
+	for _, v := range r.Vulnerabilities {
+		if strings.EqualFold(v.CveName, cveName) {
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns
+}
+
+// Returns vulnerabilities where exploitable path analysis found the vulnerable methods are reachable
+func (r SCAScanReport) GetExploitableVulnerabilities() []SCAVulnerability {
+	vulns := []SCAVulnerability{}
+	for _, v := range r.Vulnerabilities {
+		if v.IsExploitable || len(v.ExploitableMethods) > 0 {
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns
+}
+
+// Returns each path from a direct dependency to the package, the last entry in each path is the package itself.
+// A direct dependency without paths has the path to itself, a transitive dependency without known paths has none.
+func (r SCAScanReport) GetPathsToPackage(packageID string) [][]SCAPackageRef {
+	paths := [][]SCAPackageRef{}
+	p := r.GetPackage(packageID)
+	if p == nil {
+		return paths
+	}
+	for _, path := range p.DependencyPaths {
+		if len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 && p.IsDirectDependency {
+		paths = append(paths, []SCAPackageRef{p.SCAPackageRef})
+	}
+	return paths
+}
+
+// Returns the dependency tree built from all dependency paths, with one root per direct dependency
+func (r SCAScanReport) GetDependencyTree() []*SCADependencyNode {
+	vulnerable := r.vulnerablePackageIDs()
+	roots := []*SCADependencyNode{}
+	rootIndex := make(map[string]*SCADependencyNode)
+
+	getChild := func(children *[]*SCADependencyNode, ref SCAPackageRef) *SCADependencyNode {
+		for _, n := range *children {
+			if n.PackageID == ref.PackageID {
+				return n
+			}
+		}
+		node := &SCADependencyNode{SCAPackageRef: ref, Vulnerable: vulnerable[ref.PackageID], Dependencies: []*SCADependencyNode{}}
+		*children = append(*children, node)
+		return node
+	}
+
+	for _, p := range r.Packages {
+		paths := p.DependencyPaths
+		if len(paths) == 0 && p.IsDirectDependency {
+			paths = [][]SCAPackageRef{{p.SCAPackageRef}}
+		}
+		for _, path := range paths {
+			if len(path) == 0 {
+				continue
+			}
+			root, ok := rootIndex[path[0].PackageID]
+			if !ok {
+				root = getChild(&roots, path[0])
+				rootIndex[root.PackageID] = root
+			}
+			node := root
+			for _, ref := range path[1:] {
+				node = getChild(&node.Dependencies, ref)
+			}
+		}
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Name < roots[j].Name })
+	return roots
+}
+
+// Returns the upgrade recommendations for a CVE, or for all vulnerabilities that are not ignored if cveName is empty.
+// There is one recommendation per path, so a vulnerable package pulled in by two direct dependencies has two.
+func (r SCAScanReport) GetUpgradeRecommendations(cveName string) []SCAUpgradeRecommendation {
+	recommendations := []SCAUpgradeRecommendation{}
+	for _, v := range r.Vulnerabilities {
+		if cveName == "" && v.IsIgnored {
+			continue
+		}
+		if cveName != "" && !strings.EqualFold(v.CveName, cveName) {
+			continue
+		}
+		p := r.GetPackage(v.PackageID)
+		if p == nil {
+			continue
+		}
+
+		for _, path := range r.GetPathsToPackage(v.PackageID) { // none if the path to a transitive package is unknown
+			recommendations = append(recommendations, SCAUpgradeRecommendation{
+				CveName:            v.CveName,
+				Severity:           v.Severity,
+				VulnerablePackage:  p.SCAPackageRef,
+				RecommendedVersion: v.FixResolutionText,
+				DirectPackage:      path[0],
+				Path:               path,
+			})
+		}
+	}
+	return recommendations
+}
+
+// Returns the licenses at or above the risk level (None, Low, Medium or High)
+func (r SCAScanReport) GetLicensesByRisk(minRiskLevel string) []SCALicense {
+	minRisk := scaLicenseRiskLevels[strings.ToLower(minRiskLevel)]
+	licenses := []SCALicense{}
+	for _, l := range r.Licenses {
+		if scaLicenseRiskLevels[strings.ToLower(l.RiskLevel)] >= minRisk {
+			licenses = append(licenses, l)
+		}
+	}
+	sort.SliceStable(licenses, func(i, j int) bool {
+		return scaLicenseRiskLevels[strings.ToLower(licenses[i].RiskLevel)] > scaLicenseRiskLevels[strings.ToLower(licenses[j].RiskLevel)]
+	})
+	return licenses
+}
+
+func (r SCAScanReport) GetPackageLicenses(packageID string) []SCALicense {
+	licenses := []SCALicense{}
+	for _, l := range r.Licenses {
+		if l.PackageID == packageID {
+			licenses = append(licenses, l)
+		}
+	}
+	return licenses
+}
+
+func (r SCAScanReport) String() string {
+	direct := len(r.GetDirectDependencies())
+	return fmt.Sprintf("%d packages (%d direct, %d transitive), %d vulnerabilities, %d licenses", len(r.Packages), direct, len(r.Packages)-direct, len(r.Vulnerabilities), len(r.Licenses))
+}
+
+func (p SCAPackageRef) String() string {
+	return fmt.Sprintf("%v %v", p.Name, p.Version)
+}
+
+func (u SCAUpgradeRecommendation) String() string {
+	if u.DirectPackage.PackageID == u.VulnerablePackage.PackageID {
+		return fmt.Sprintf("%v: upgrade direct dependency %v to %v", u.CveName, u.VulnerablePackage.String(), u.RecommendedVersion)
+	}
+	return fmt.Sprintf("%v: upgrade direct dependency %v so that %v resolves to %v (via %d levels)", u.CveName, u.DirectPackage.String(), u.VulnerablePackage.String(), u.RecommendedVersion, len(u.Path)-1)
+}
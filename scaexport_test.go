@@ -0,0 +1,120 @@
+package Cx1ClientGo
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func scaRefs(ids ...string) []SCAPackageRef {
+	refs := []SCAPackageRef{}
+	for _, id := range ids {
+		refs = append(refs, SCAPackageRef{PackageID: id, Name: id})
+	}
+	return refs
+}
+
+func scaRefIDs(refs []SCAPackageRef) []string {
+	ids := []string{}
+	for _, r := range refs {
+		ids = append(ids, r.PackageID)
+	}
+	return ids
+}
+
+func TestNormalizeSCADependencyPath(t *testing.T) {
+	pkg := SCAPackageRef{PackageID: "pkg", Name: "pkg"}
+
+	tests := []struct {
+		name     string
+		path     []SCAPackageRef
+		direct   bool
+		expected []string
+	}{
+		{name: "empty path for direct dependency", path: nil, direct: true, expected: []string{"pkg"}},
+		{name: "empty path for transitive dependency", path: nil, expected: []string{}},
+		{name: "only the package", path: scaRefs("pkg"), direct: true, expected: []string{"pkg"}},
+		{name: "direct to package", path: scaRefs("direct", "middle", "pkg"), expected: []string{"direct", "middle", "pkg"}},
+		{name: "package to direct", path: scaRefs("pkg", "middle", "direct"), expected: []string{"direct", "middle", "pkg"}},
+		{name: "package missing", path: scaRefs("direct", "middle"), expected: []string{"direct", "middle", "pkg"}},
+	}
+
+	for _, test := range tests {
+		got := scaRefIDs(normalizeSCADependencyPath(test.path, pkg, test.direct))
+		if !slices.Equal(got, test.expected) {
+			t.Errorf("%v: got path %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestParseSCAScanReport(t *testing.T) {
+	data := []byte(`{
+		"Packages": [
+			{"Id": "direct", "Name": "direct", "Version": "1.0", "IsDirectDependency": true},
+			{"Id": "reversed", "Name": "reversed", "Version": "2.0", "DependencyPaths": [[{"Id": "reversed"}, {"Id": "direct"}]]},
+			{"Id": "nopath", "Name": "nopath", "Version": "3.0", "DependencyPaths": [[]]},
+			{"Id": "orphan", "Name": "orphan", "Version": "4.0"}
+		],
+		"Vulnerabilities": [
+			{"Id": "v1", "CveName": "CVE-2024-0001", "PackageId": "reversed", "Severity": "High", "FixResolutionText": "2.1"},
+			{"Id": "v3", "CveName": "CVE-2024-0003", "PackageId": "nopath", "Severity": "High", "FixResolutionText": "3.1"},
+			{"Id": "v2", "CveName": "CVE-2024-0002", "PackageId": "unknown", "Severity": "Low"}
+		],
+		"Licenses": [
+			{"PackageId": "direct", "Name": "MIT", "RiskLevel": "Low"}
+		]
+	}`)
+
+	report, err := ParseSCAScanReport(data)
+	if err != nil {
+		t.Fatalf("ParseSCAScanReport: unexpected error %s", err)
+	}
+
+	tests := []struct {
+		packageID string
+		found     bool
+		paths     [][]string
+	}{
+		{packageID: "direct", found: true, paths: [][]string{{"direct"}}},
+		{packageID: "reversed", found: true, paths: [][]string{{"direct", "reversed"}}},
+		{packageID: "nopath", found: true, paths: [][]string{}},
+		{packageID: "orphan", found: true, paths: [][]string{}},
+		{packageID: "unknown", found: false, paths: [][]string{}},
+	}
+
+	for _, test := range tests {
+		if p := report.GetPackage(test.packageID); (p != nil) != test.found {
+			t.Errorf("GetPackage(%v): found = %v, expected %v", test.packageID, p != nil, test.found)
+		}
+		paths := report.GetPathsToPackage(test.packageID)
+		if len(paths) != len(test.paths) {
+			t.Errorf("GetPathsToPackage(%v): got %d paths, expected %d", test.packageID, len(paths), len(test.paths))
+			continue
+		}
+		for id := range paths {
+			if got := scaRefIDs(paths[id]); !slices.Equal(got, test.paths[id]) {
+				t.Errorf("GetPathsToPackage(%v)[%d] = %v, expected %v", test.packageID, id, got, test.paths[id])
+			}
+		}
+	}
+
+	recommendations := report.GetUpgradeRecommendations("")
+	if len(recommendations) != 1 || recommendations[0].DirectPackage.PackageID != "direct" || recommendations[0].RecommendedVersion != "2.1" {
+		t.Errorf("GetUpgradeRecommendations: got %v, expected one recommendation to bump direct", recommendations)
+	}
+
+	if _, err := ParseSCAScanReport([]byte(`{"Packages": {}}`)); err == nil {
+		t.Errorf("ParseSCAScanReport: expected an error for an invalid report")
+	}
+}
+
+func TestSCADependencyTreeSkipsEmptyPaths(t *testing.T) {
+	report := SCAScanReport{Packages: []SCAPackage{
+		{SCAPackageRef: SCAPackageRef{PackageID: "a", Name: "a"}, DependencyPaths: [][]SCAPackageRef{{}, scaRefs("root", "a")}},
+	}}
+
+	roots := report.GetDependencyTree()
+	if len(roots) != 1 || roots[0].PackageID != "root" || len(roots[0].Dependencies) != 1 || roots[0].Dependencies[0].PackageID != "a" {
+		t.Errorf("GetDependencyTree: unexpected tree %v", roots)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/go-querystring/query"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/slices"
 )
 
@@ -580,13 +581,18 @@ func (c Cx1Client) ScanPollingDetailed(s *Scan) (Scan, error) {
 	return c.ScanPollingWithTimeout(s, true, c.consts.ScanPollingDelaySeconds, c.consts.ScanPollingMaxSeconds)
 }
 
-func (c Cx1Client) ScanPollingWithTimeout(s *Scan, detailed bool, delaySeconds, maxSeconds int) (Scan, error) {
+func (c Cx1Client) ScanPollingWithTimeout(s *Scan, detailed bool, delaySeconds, maxSeconds int) (scan Scan, err error) {
+	c, span := c.startOperation("ScanPollingWithTimeout", attribute.String("cx1.scan.id", s.ScanID))
+	defer func() {
+		span.SetAttributes(attribute.String("cx1.scan.status", scan.Status))
+		endOperation(span, err)
+	}()
+
 	c.logger.Infof("Polling status of scan %v", s.ScanID)
 	shortId := ShortenGUID(s.ScanID)
 
 	pollingCounter := 0
-	var err error
-	scan := *s
+	scan = *s
 	for !(scan.Status == "Failed" || scan.Status == "Partial" || scan.Status == "Completed" || scan.Status == "Canceled") { // scan is queueing or running
 		scan, err = c.GetScanByID(scan.ScanID)
 		if err != nil {
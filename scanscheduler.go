@@ -0,0 +1,360 @@
+package Cx1ClientGo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	The ScanScheduler is a client-side queue for scan submissions, to avoid piling up hundreds of Queued scans on the
+	tenant when CI triggers many scans at once. Capacity is the license MaxConcurrentScans (or the override in
+	ScanSchedulerOptions) multiplied by CapacityShare. Scans are only submitted while the number of running and queued
+	scans on the tenant (GetScansSummary, including scans not submitted through this scheduler) is below that capacity.
+
+	Queued requests are ordered by:
+	1. the highest Criticality of the applications containing the project (or the project's own criticality)
+	2. the team with the fewest scans in flight from this scheduler, so one team can't take all capacity
+	3. the project's main branch or one of the PriorityBranches first
+	4. the oldest request first
+	A team can additionally be limited to MaxPerTeam scans in flight.
+
+	Submit returns immediately, use Wait on the returned ScheduledScan to get the Scan once it has been submitted to
+	Cx1, and then poll it as usual (eg: ScanPolling).
+*/
+
+type scanSchedulerProject struct {
+	criticality uint
+	mainBranch  string
+	team        string
+}
+
+// Creates a new scheduler and starts checking tenant load in the background.
+// The scheduler stops when ctx is cancelled or Close is called; requests still queued at that point fail.
+func NewScanScheduler(ctx context.Context, client *Cx1Client, options ScanSchedulerOptions) (*ScanScheduler, error) {
+	maxScans := options.MaxConcurrentScans
+	if maxScans <= 0 {
+		maxScans = client.GetLicense().LicenseData.MaxConcurrentScans
+	}
+	if maxScans <= 0 {
+		return nil, fmt.Errorf("license does not define MaxConcurrentScans, set ScanSchedulerOptions.MaxConcurrentScans")
+	}
+	if options.CapacityShare <= 0 || options.CapacityShare > 1 {
+		options.CapacityShare = 1
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Duration(client.consts.ScanPollingDelaySeconds) * time.Second
+		if options.PollInterval <= 0 {
+			options.PollInterval = 30 * time.Second
+		}
+	}
+
+	capacity := int(float64(maxScans) * options.CapacityShare)
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	s := &ScanScheduler{
+		client:   client,
+		options:  options,
+		capacity: capacity,
+		projects: make(map[string]scanSchedulerProject),
+		wake:     make(chan struct{}, 1),
+		ctx:      sctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	client.logger.Infof("Scan scheduler using %d of %d concurrent scans", capacity, maxScans)
+
+	go s.run()
+	return s, nil
+}
+
+// Adds a scan request to the queue. The project is looked up to determine criticality, main branch and team.
+func (s *ScanScheduler) Submit(request ScanRequest) (*ScheduledScan, error) {
+	if request.ScanType != "upload" && request.ScanType != "git" {
+		return nil, fmt.Errorf("invalid scanType provided, must be 'upload' or 'git'")
+	}
+
+	project, err := s.getProject(request.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Team == "" {
+		request.Team = project.team
+	}
+
+	scan := &ScheduledScan{
+		ScanRequest:    request,
+		Criticality:    project.criticality,
+		PriorityBranch: request.Branch == project.mainBranch || slices.Contains(s.options.PriorityBranches, request.Branch),
+		QueuedAt:       time.Now(),
+		done:           make(chan struct{}),
+	}
+
+	s.mutex.Lock()
+	if s.ctx.Err() != nil {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("scan scheduler is closed")
+	}
+	s.queue = append(s.queue, scan)
+	s.mutex.Unlock()
+
+	s.client.logger.Debugf("Queued %v", scan.String())
+	s.trigger()
+	return scan, nil
+}
+
+// Removes a scan request from the queue if it has not been submitted yet
+func (s *ScanScheduler) Cancel(scan *ScheduledScan) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, q := range s.queue {
+		if q == scan {
+			s.queue = append(s.queue[:id], s.queue[id+1:]...)
+			scan.Err = fmt.Errorf("scan request was cancelled")
+			close(scan.done)
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of requests waiting locally, scans submitted by this scheduler that are still queued or
+// running on the tenant, and the total running and queued scans on the tenant at the last check
+func (s *ScanScheduler) Count() (waiting, inFlight int, tenantActive uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.queue), len(s.inFlight), s.lastActive
+}
+
+// Stops the scheduler, any requests still waiting fail with an error
+func (s *ScanScheduler) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *ScanScheduler) String() string {
+	waiting, inFlight, active := s.Count()
+	return fmt.Sprintf("Scan scheduler: %d waiting, %d in flight, %d/%d active on tenant", waiting, inFlight, active, s.capacity)
+}
+
+// Blocks until the scan has been submitted to Cx1 (or failed to submit) and returns it
+func (q *ScheduledScan) Wait(ctx context.Context) (Scan, error) {
+	select {
+	case <-q.done:
+		return q.Scan, q.Err
+	case <-ctx.Done():
+		return Scan{}, ctx.Err()
+	}
+}
+
+func (q *ScheduledScan) String() string {
+	return fmt.Sprintf("%v scan of project %v branch %v (team %v, criticality %d)", q.ScanType, ShortenGUID(q.ProjectID), q.Branch, q.Team, q.Criticality)
+}
+
+func (s *ScanScheduler) getProject(projectID string) (scanSchedulerProject, error) {
+	s.mutex.Lock()
+	info, ok := s.projects[projectID]
+	s.mutex.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	project, err := s.client.GetProjectByID(projectID)
+	if err != nil {
+		return info, fmt.Errorf("failed to get project %v: %s", projectID, err)
+	}
+
+	info = scanSchedulerProject{
+		criticality: project.Criticality,
+		mainBranch:  project.MainBranch,
+	}
+	if len(project.Groups) > 0 {
+		info.team = project.Groups[0]
+	}
+	if project.Applications != nil {
+		for _, appId := range *project.Applications {
+			app, err := s.client.GetApplicationByID(appId)
+			if err != nil {
+				s.client.logger.Warnf("Failed to get application %v for project %v: %s", appId, project.String(), err)
+				continue
+			}
+			if app.Criticality > info.criticality {
+				info.criticality = app.Criticality
+			}
+		}
+	}
+
+	s.mutex.Lock()
+	s.projects[projectID] = info
+	s.mutex.Unlock()
+	return info, nil
+}
+
+func (s *ScanScheduler) trigger() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ScanScheduler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.shutdown()
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.dispatch()
+	}
+}
+
+// checks tenant load and submits as many queued scans as capacity allows
+func (s *ScanScheduler) dispatch() {
+	s.mutex.Lock()
+	waiting := len(s.queue)
+	inFlight := len(s.inFlight)
+	s.mutex.Unlock()
+	if waiting == 0 && inFlight == 0 {
+		return
+	}
+
+	summary, err := s.client.GetScansSummary()
+	if err != nil {
+		s.client.logger.Warnf("Scan scheduler failed to get scan summary: %s", err)
+		return
+	}
+	active := summary.Running + summary.Queued
+
+	if inFlight > 0 {
+		s.updateInFlight()
+	}
+
+	s.mutex.Lock()
+	s.lastActive = active
+	s.mutex.Unlock()
+
+	for int(active) < s.capacity && s.ctx.Err() == nil {
+		s.mutex.Lock()
+		next := s.next()
+		s.mutex.Unlock()
+		if next == nil {
+			break
+		}
+
+		s.submit(next)
+		if next.Err == nil {
+			active++
+		}
+	}
+
+	s.client.logger.Tracef("%v", s.String())
+}
+
+// removes scans that are no longer queued or running on the tenant from the in-flight list
+func (s *ScanScheduler) updateInFlight() {
+	scans, err := s.client.GetScansByStatus([]string{"Queued", "Running"})
+	if err != nil {
+		s.client.logger.Warnf("Scan scheduler failed to get active scans: %s", err)
+		return
+	}
+
+	active := make(map[string]bool, len(scans))
+	for _, scan := range scans {
+		active[scan.ScanID] = true
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	remaining := []*ScheduledScan{}
+	for _, q := range s.inFlight {
+		if active[q.Scan.ScanID] {
+			remaining = append(remaining, q)
+		} else {
+			s.client.logger.Debugf("Scan %v for team %v is no longer active", ShortenGUID(q.Scan.ScanID), q.Team)
+		}
+	}
+	s.inFlight = remaining
+}
+
+// removes and returns the highest-priority request whose team is below MaxPerTeam, must hold the mutex
+func (s *ScanScheduler) next() *ScheduledScan {
+	teamCount := make(map[string]int)
+	for _, q := range s.inFlight {
+		teamCount[q.Team]++
+	}
+
+	best := -1
+	for id, q := range s.queue {
+		if s.options.MaxPerTeam > 0 && teamCount[q.Team] >= s.options.MaxPerTeam {
+			continue
+		}
+		if best == -1 || scheduledScanBefore(q, s.queue[best], teamCount) {
+			best = id
+		}
+	}
+	if best == -1 {
+		return nil
+	}
+
+	q := s.queue[best]
+	s.queue = append(s.queue[:best], s.queue[best+1:]...)
+	return q
+}
+
+func scheduledScanBefore(a, b *ScheduledScan, teamCount map[string]int) bool {
+	if a.Criticality != b.Criticality {
+		return a.Criticality > b.Criticality
+	}
+	if teamCount[a.Team] != teamCount[b.Team] {
+		return teamCount[a.Team] < teamCount[b.Team]
+	}
+	if a.PriorityBranch != b.PriorityBranch {
+		return a.PriorityBranch
+	}
+	return a.QueuedAt.Before(b.QueuedAt)
+}
+
+func (s *ScanScheduler) submit(q *ScheduledScan) {
+	s.client.logger.Infof("Submitting %v after %v in queue", q.String(), time.Since(q.QueuedAt).Round(time.Second))
+
+	if q.ScanType == "upload" {
+		q.Scan, q.Err = s.client.ScanProjectZipByID(q.ProjectID, q.SourceURL, q.Branch, q.Settings, q.Tags)
+	} else {
+		q.Scan, q.Err = s.client.ScanProjectGitByID(q.ProjectID, q.SourceURL, q.Branch, q.Settings, q.Tags)
+	}
+	q.SubmittedAt = time.Now()
+
+	if q.Err != nil {
+		s.client.logger.Errorf("Failed to submit %v: %s", q.String(), q.Err)
+	} else {
+		s.mutex.Lock()
+		s.inFlight = append(s.inFlight, q)
+		s.mutex.Unlock()
+	}
+	close(q.done)
+}
+
+func (s *ScanScheduler) shutdown() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, q := range s.queue {
+		q.Err = fmt.Errorf("scan scheduler is closed")
+		close(q.done)
+	}
+	if len(s.queue) > 0 {
+		s.client.logger.Warnf("Scan scheduler closed with %d scans still waiting", len(s.queue))
+	}
+	s.queue = nil
+}
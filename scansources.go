@@ -0,0 +1,353 @@
+package Cx1ClientGo
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	ScanSources gives file-level access to the source archive the repostore kept for a scan (GetScanSourcesByID),
+	without an audit session. The archive is only downloaded on first use, and if the ScanSources was created from
+	a ScanSourceCache the archive is shared with the cache (and its cache directory).
+
+	ScanSources implements io/fs.FS so it can be used with fs.WalkDir, fs.ReadFile, http.FS, template.ParseFS etc.
+	The fs names are the archive paths normalized as in ListFiles (forward slashes, no leading ./ or /), use
+	ReadResultFile to read a file by the name reported in a result.
+	Comparing two ScanSources lists the added, removed and modified files, eg: to see why an incremental scan
+	picked up more or fewer files than expected.
+*/
+
+var sourceLanguageExtensions = map[string]string{
+	".java":      "Java",
+	".jsp":       "Java",
+	".cs":        "CSharp",
+	".cshtml":    "CSharp",
+	".razor":     "CSharp",
+	".js":        "JavaScript",
+	".jsx":       "JavaScript",
+	".mjs":       "JavaScript",
+	".cjs":       "JavaScript",
+	".ts":        "JavaScript",
+	".tsx":       "JavaScript",
+	".vue":       "JavaScript",
+	".py":        "Python",
+	".go":        "Go",
+	".php":       "PHP",
+	".rb":        "Ruby",
+	".erb":       "Ruby",
+	".c":         "CPP",
+	".cc":        "CPP",
+	".cpp":       "CPP",
+	".cxx":       "CPP",
+	".h":         "CPP",
+	".hpp":       "CPP",
+	".cls":       "Apex",
+	".trigger":   "Apex",
+	".page":      "Apex",
+	".component": "Apex",
+	".m":         "Objc",
+	".mm":        "Objc",
+	".swift":     "Swift",
+	".kt":        "Kotlin",
+	".kts":       "Kotlin",
+	".scala":     "Scala",
+	".groovy":    "Groovy",
+	".gradle":    "Groovy",
+	".dart":      "Dart",
+	".vb":        "VbNet",
+	".vbs":       "VbScript",
+	".asp":       "ASP",
+	".pl":        "Perl",
+	".pm":        "Perl",
+	".sql":       "PLSQL",
+	".pks":       "PLSQL",
+	".pkb":       "PLSQL",
+	".rs":        "Rust",
+}
+
+// Returns the SAST language for a file based on its extension, or an empty string
+func DetectSourceLanguage(fileName string) string {
+	return sourceLanguageExtensions[strings.ToLower(path.Ext(fileName))]
+}
+
+// Creates a ScanSources for the scan, the archive is downloaded on first use
+func NewScanSources(client *Cx1Client, scanID string) *ScanSources {
+	return &ScanSources{ScanID: scanID, client: client}
+}
+
+// Creates a ScanSources which loads its archive through the cache
+func (sc *ScanSourceCache) GetSources(scanID string) *ScanSources {
+	return &ScanSources{ScanID: scanID, client: sc.client, cache: sc}
+}
+
+func (s *ScanSources) load() (*ScanSourceArchive, error) {
+	s.once.Do(func() {
+		if s.cache != nil {
+			s.archive, s.err = s.cache.GetArchive(s.ScanID)
+			return
+		}
+
+		data, err := s.client.GetScanSourcesByID(s.ScanID)
+		if err == nil && len(data) == 0 {
+			err = fmt.Errorf("failed to download sources for scan %v", s.ScanID)
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.archive, s.err = NewScanSourceArchive(s.ScanID, data)
+	})
+	return s.archive, s.err
+}
+
+// Returns the underlying archive, eg: for GetSnippet
+func (s *ScanSources) GetArchive() (*ScanSourceArchive, error) {
+	return s.load()
+}
+
+// Implements fs.FS, names are the normalized paths returned by ListFiles (eg: src/Main.java)
+func (s *ScanSources) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	archive, err := s.load()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if entries, ok := archive.dirs[name]; ok {
+		dir := &scanSourceDir{info: scanSourceFileInfo{name: path.Base(name), dir: true}}
+		for _, entry := range entries {
+			info, _ := archive.stat(path.Join(name, entry))
+			dir.entries = append(dir.entries, fs.FileInfoToDirEntry(info))
+		}
+		return dir, nil
+	}
+
+	f, ok := archive.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	info, _ := archive.stat(name)
+	return &scanSourceFile{info: info, rc: rc}, nil
+}
+
+// Returns the contents of a file, the name must be a path as returned by ListFiles
+func (s *ScanSources) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	archive, err := s.load()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	if _, ok := archive.dirs[name]; ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	f, ok := archive.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return readSourceFile(f)
+}
+
+// Returns the contents of a file named as in a result: leading slashes and backslashes are ignored and, if
+// there is no exact match, the file is found by a unique path suffix
+func (s *ScanSources) ReadResultFile(fileName string) ([]byte, error) {
+	archive, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	f := archive.getFile(fileName)
+	if f == nil {
+		return nil, &fs.PathError{Op: "read", Path: fileName, Err: fs.ErrNotExist}
+	}
+	return readSourceFile(f)
+}
+
+func readSourceFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// returns the info for a normalized path in the archive
+func (a *ScanSourceArchive) stat(name string) (scanSourceFileInfo, bool) {
+	if _, ok := a.dirs[name]; ok {
+		return scanSourceFileInfo{name: path.Base(name), dir: true}, true
+	}
+	if f, ok := a.files[name]; ok {
+		return scanSourceFileInfo{name: path.Base(name), size: int64(f.UncompressedSize64), modified: f.Modified}, true
+	}
+	return scanSourceFileInfo{}, false
+}
+
+type scanSourceFileInfo struct {
+	name     string
+	size     int64
+	modified time.Time
+	dir      bool
+}
+
+func (i scanSourceFileInfo) Name() string       { return i.name }
+func (i scanSourceFileInfo) Size() int64        { return i.size }
+func (i scanSourceFileInfo) ModTime() time.Time { return i.modified }
+func (i scanSourceFileInfo) IsDir() bool        { return i.dir }
+func (i scanSourceFileInfo) Sys() interface{}   { return nil }
+func (i scanSourceFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+type scanSourceFile struct {
+	info scanSourceFileInfo
+	rc   io.ReadCloser
+}
+
+func (f *scanSourceFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *scanSourceFile) Read(b []byte) (int, error) { return f.rc.Read(b) }
+func (f *scanSourceFile) Close() error               { return f.rc.Close() }
+
+type scanSourceDir struct {
+	info    scanSourceFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *scanSourceDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *scanSourceDir) Close() error               { return nil }
+func (d *scanSourceDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fmt.Errorf("is a directory")}
+}
+
+// Implements fs.ReadDirFile
+func (d *scanSourceDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+// Returns all files in the archive, sorted by path
+func (s *ScanSources) ListFiles() ([]ScanSourceFile, error) {
+	archive, err := s.load()
+	if err != nil {
+		return []ScanSourceFile{}, err
+	}
+
+	files := make([]ScanSourceFile, 0, len(archive.files))
+	for name, f := range archive.files {
+		files = append(files, ScanSourceFile{
+			Path:     name,
+			Size:     f.UncompressedSize64,
+			CRC32:    f.CRC32,
+			Language: DetectSourceLanguage(name),
+			Modified: f.Modified,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Returns the number of files per detected language, files without a detected language are counted under ""
+func (s *ScanSources) GetLanguages() (map[string]int, error) {
+	files, err := s.ListFiles()
+	languages := make(map[string]int)
+	for _, f := range files {
+		languages[f.Language]++
+	}
+	return languages, err
+}
+
+// Compares the sources of this scan with those of a previous scan. Files are matched by path and are
+// considered modified if their size or checksum changed.
+func (s *ScanSources) Compare(previous *ScanSources) (ScanSourcesDiff, error) {
+	diff := ScanSourcesDiff{
+		OldScanID: previous.ScanID,
+		NewScanID: s.ScanID,
+		Added:     []ScanSourceFile{},
+		Removed:   []ScanSourceFile{},
+		Modified:  []ScanSourceFile{},
+	}
+
+	oldFiles, err := previous.ListFiles()
+	if err != nil {
+		return diff, fmt.Errorf("failed to list sources for scan %v: %s", previous.ScanID, err)
+	}
+	newFiles, err := s.ListFiles()
+	if err != nil {
+		return diff, fmt.Errorf("failed to list sources for scan %v: %s", s.ScanID, err)
+	}
+
+	oldIndex := make(map[string]ScanSourceFile, len(oldFiles))
+	for _, f := range oldFiles {
+		oldIndex[f.Path] = f
+	}
+
+	for _, f := range newFiles {
+		old, ok := oldIndex[f.Path]
+		if !ok {
+			diff.Added = append(diff.Added, f)
+			continue
+		}
+		delete(oldIndex, f.Path)
+		if old.CRC32 != f.CRC32 || old.Size != f.Size {
+			diff.Modified = append(diff.Modified, f)
+		} else {
+			diff.Unchanged++
+		}
+	}
+
+	for _, f := range oldFiles {
+		if _, ok := oldIndex[f.Path]; ok {
+			diff.Removed = append(diff.Removed, f)
+		}
+	}
+	return diff, nil
+}
+
+// Returns the number of added and modified files per detected language
+func (d ScanSourcesDiff) GetChangedLanguages() map[string]int {
+	languages := make(map[string]int)
+	for _, f := range d.Added {
+		languages[f.Language]++
+	}
+	for _, f := range d.Modified {
+		languages[f.Language]++
+	}
+	return languages
+}
+
+func (d ScanSourcesDiff) String() string {
+	return fmt.Sprintf("Sources of scan %v compared to %v: %d added, %d removed, %d modified, %d unchanged",
+		ShortenGUID(d.NewScanID), ShortenGUID(d.OldScanID), len(d.Added), len(d.Removed), len(d.Modified), d.Unchanged)
+}
+
+func (s *ScanSources) String() string {
+	return fmt.Sprintf("Sources of scan %v", ShortenGUID(s.ScanID))
+}
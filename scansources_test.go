@@ -0,0 +1,117 @@
+package Cx1ClientGo
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// returns a zip archive with the files
+func newTestSourceZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %v to zip: %s", name, err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %v to zip: %s", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestScanSources(t *testing.T, files map[string]string) *ScanSources {
+	archive, err := NewScanSourceArchive("test", newTestSourceZip(t, files))
+	if err != nil {
+		t.Fatalf("NewScanSourceArchive: %s", err)
+	}
+	sources := &ScanSources{ScanID: "test", archive: archive}
+	sources.once.Do(func() {}) // already loaded
+	return sources
+}
+
+func TestScanSourcesFS(t *testing.T) {
+	sources := newTestScanSources(t, map[string]string{
+		"./src/Main.java":          "class Main {}",
+		"src\\util\\Helper.java":   "class Helper {}",
+		"/README.md":               "# readme",
+		"src/../../etc/passwd":     "skipped",
+		"web/static/js/app.min.js": "app()",
+	})
+
+	if err := fstest.TestFS(sources, "src/Main.java", "src/util/Helper.java", "README.md", "web/static/js/app.min.js"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := sources.ListFiles()
+	if err != nil {
+		t.Fatalf("ListFiles: %s", err)
+	}
+	for _, f := range files {
+		if _, err := fs.Stat(sources, f.Path); err != nil {
+			t.Errorf("ListFiles returned %v which cannot be opened: %s", f.Path, err)
+		}
+	}
+	if len(files) != 4 {
+		t.Errorf("ListFiles returned %d files, expected 4", len(files))
+	}
+}
+
+func TestScanSourcesReadFile(t *testing.T) {
+	sources := newTestScanSources(t, map[string]string{
+		"project/src/Main.java":    "class Main {}",
+		"project/src/a/Util.java":  "class A {}",
+		"project/src/b/Util.java":  "class B {}",
+		"project\\win\\Code.cs":    "class Code {}",
+		"project/docs/README.md":   "# readme",
+		"project/docs/Guide.md":    "# guide",
+		"project/docs/more/FAQ.md": "# faq",
+	})
+
+	tests := []struct {
+		name       string
+		strict     string // expected content from ReadFile, empty for an error
+		strictErr  error
+		lenient    string // expected content from ReadResultFile, empty for an error
+		lenientErr error
+	}{
+		{name: "project/src/Main.java", strict: "class Main {}", lenient: "class Main {}"},
+		{name: "/project/src/Main.java", strictErr: fs.ErrInvalid, lenient: "class Main {}"},
+		{name: "src/Main.java", strictErr: fs.ErrNotExist, lenient: "class Main {}"},
+		{name: "Main.java", strictErr: fs.ErrNotExist, lenient: "class Main {}"},
+		{name: "project/win/Code.cs", strict: "class Code {}", lenient: "class Code {}"},
+		{name: "project\\win\\Code.cs", strictErr: fs.ErrNotExist, lenient: "class Code {}"},
+		{name: "Util.java", strictErr: fs.ErrNotExist, lenientErr: fs.ErrNotExist}, // ambiguous suffix
+		{name: "project/src/../src/Main.java", strictErr: fs.ErrInvalid, lenientErr: fs.ErrNotExist},
+		{name: "project/docs", strictErr: nil, lenientErr: fs.ErrNotExist},
+		{name: "missing.txt", strictErr: fs.ErrNotExist, lenientErr: fs.ErrNotExist},
+	}
+
+	for _, test := range tests {
+		data, err := sources.ReadFile(test.name)
+		if test.strict != "" {
+			if err != nil || string(data) != test.strict {
+				t.Errorf("ReadFile(%q) = %q, %v, expected %q", test.name, data, err, test.strict)
+			}
+		} else if err == nil || (test.strictErr != nil && !errors.Is(err, test.strictErr)) {
+			t.Errorf("ReadFile(%q): expected error %v, got %v", test.name, test.strictErr, err)
+		}
+
+		data, err = sources.ReadResultFile(test.name)
+		if test.lenient != "" {
+			if err != nil || string(data) != test.lenient {
+				t.Errorf("ReadResultFile(%q) = %q, %v, expected %q", test.name, data, err, test.lenient)
+			}
+		} else if err == nil || (test.lenientErr != nil && !errors.Is(err, test.lenientErr)) {
+			t.Errorf("ReadResultFile(%q): expected error %v, got %v", test.name, test.lenientErr, err)
+		}
+	}
+}
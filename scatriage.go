@@ -0,0 +1,417 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+	SCA and container triage. Unlike SAST and IAC these are not keyed by similarity ID:
+	- SCA vulnerability triage identifies a vulnerability by package manager, name, version and CVE, and the
+	  SCA "management of risk" API stores it as a list of actions (state change, ignore) per project
+	- SCA package triage ignores all vulnerabilities of a package version
+	- container triage identifies a vulnerability by image, package and CVE
+
+	Ignores can carry an expiry date after which the vulnerability counts again. A justification comment is
+	required when marking a vulnerability NotExploitable or ignoring it.
+*/
+
+const (
+	SCAStateToVerify               = "ToVerify"
+	SCAStateNotExploitable         = "NotExploitable"
+	SCAStateProposedNotExploitable = "ProposedNotExploitable"
+	SCAStateConfirmed              = "Confirmed"
+	SCAStateUrgent                 = "Urgent"
+
+	scaActionChangeState = "ChangeState"
+	scaActionIgnore      = "Ignore"
+
+	scaVulnerabilityRiskPath = "/sca/management-of-risk/package-vulnerabilities"
+	scaPackageRiskPath       = "/sca/management-of-risk/packages"
+	containersTriagePath     = "/containers/triage/vulnerabilities"
+)
+
+type scaRiskAction struct {
+	ActionType     string      `json:"actionType"`
+	Value          interface{} `json:"value"`
+	Comment        string      `json:"comment,omitempty"`
+	ExpirationDate string      `json:"expirationDate,omitempty"`
+}
+
+// history entries use either a plain comment or a comment object
+type scaRiskHistoryAction struct {
+	ActionType     string          `json:"actionType"`
+	Value          json.RawMessage `json:"value"`
+	Comment        json.RawMessage `json:"comment"`
+	ExpirationDate string          `json:"expirationDate"`
+	CreatedAt      string          `json:"createdAt"`
+	UserName       string          `json:"userName"`
+}
+
+func (c Cx1Client) AddSCAResultsPredicates(predicates []SCAResultsPredicates) error {
+	c.logger.Debugf("Adding %d SCA results predicates", len(predicates))
+
+	for _, p := range predicates {
+		if err := p.validate(); err != nil {
+			return err
+		}
+
+		actions := []scaRiskAction{}
+		if p.State != "" {
+			actions = append(actions, scaRiskAction{ActionType: scaActionChangeState, Value: p.State, Comment: p.Comment})
+		}
+		if p.Ignore {
+			actions = append(actions, newSCAIgnoreAction(p.Comment, p.ExpiresAt))
+		}
+
+		body := map[string]interface{}{
+			"packageName":     p.PackageName,
+			"packageVersion":  p.PackageVersion,
+			"packageManager":  p.PackageManager,
+			"vulnerabilityId": p.VulnerabilityID,
+			"projectIds":      []string{p.ProjectID},
+			"actions":         actions,
+		}
+		jsonBody, _ := json.Marshal(body)
+		if _, err := c.sendRequest(http.MethodPost, scaVulnerabilityRiskPath, bytes.NewReader(jsonBody), nil); err != nil {
+			return fmt.Errorf("failed to add SCA predicate for %v: %s", p.String(), err)
+		}
+	}
+	return nil
+}
+
+// Returns the triage history of a vulnerability in a package version for a project, oldest first
+func (c Cx1Client) GetSCAResultsPredicatesByID(packageManager, packageName, packageVersion, vulnerabilityID, projectID string) ([]SCAResultsPredicates, error) {
+	c.logger.Debugf("Fetching SCA results predicates for project %v %v %v %v %v", projectID, packageManager, packageName, packageVersion, vulnerabilityID)
+	params := url.Values{
+		"packageManager":  {packageManager},
+		"packageName":     {packageName},
+		"packageVersion":  {packageVersion},
+		"vulnerabilityId": {vulnerabilityID},
+		"projectId":       {projectID},
+	}
+
+	actions, err := c.getSCARiskHistory(scaVulnerabilityRiskPath, params)
+	if err != nil {
+		return []SCAResultsPredicates{}, err
+	}
+
+	predicates := []SCAResultsPredicates{}
+	for _, a := range actions {
+		p := SCAResultsPredicates{
+			PackageName:     packageName,
+			PackageVersion:  packageVersion,
+			PackageManager:  packageManager,
+			VulnerabilityID: vulnerabilityID,
+			ProjectID:       projectID,
+			Comment:         a.getComment(),
+			CreatedBy:       a.UserName,
+			CreatedAt:       a.CreatedAt,
+		}
+		switch a.ActionType {
+		case scaActionChangeState:
+			_ = json.Unmarshal(a.Value, &p.State)
+		case scaActionIgnore:
+			_ = json.Unmarshal(a.Value, &p.Ignore)
+			p.ExpiresAt, _ = time.Parse(time.RFC3339, a.ExpirationDate)
+		default:
+			c.logger.Tracef("Skipping unknown SCA risk action type %v", a.ActionType)
+			continue
+		}
+		predicates = append(predicates, p)
+	}
+	return predicates, nil
+}
+
+// Returns the latest state and ignore status of a vulnerability, with expired ignores already lifted
+func (c Cx1Client) GetLastSCAResultsPredicateByID(packageManager, packageName, packageVersion, vulnerabilityID, projectID string) (SCAResultsPredicates, error) {
+	history, err := c.GetSCAResultsPredicatesByID(packageManager, packageName, packageVersion, vulnerabilityID, projectID)
+	last := SCAResultsPredicates{
+		PackageName:     packageName,
+		PackageVersion:  packageVersion,
+		PackageManager:  packageManager,
+		VulnerabilityID: vulnerabilityID,
+		ProjectID:       projectID,
+	}
+	if err != nil {
+		return last, err
+	}
+
+	for _, p := range history {
+		if p.State != "" {
+			last.State = p.State
+		} else {
+			last.Ignore = p.Ignore
+			last.ExpiresAt = p.ExpiresAt
+		}
+		last.Comment = p.Comment
+		last.CreatedBy = p.CreatedBy
+		last.CreatedAt = p.CreatedAt
+	}
+	if last.Ignore && last.IsExpired() {
+		last.Ignore = false
+	}
+	return last, nil
+}
+
+func (c Cx1Client) AddSCAPackagePredicates(predicates []SCAPackagePredicates) error {
+	c.logger.Debugf("Adding %d SCA package predicates", len(predicates))
+
+	for _, p := range predicates {
+		if p.Ignore && p.Comment == "" {
+			return fmt.Errorf("a justification comment is required to ignore package %v", p.String())
+		}
+
+		body := map[string]interface{}{
+			"packageName":    p.PackageName,
+			"packageVersion": p.PackageVersion,
+			"packageManager": p.PackageManager,
+			"projectIds":     []string{p.ProjectID},
+			"actions":        []scaRiskAction{newSCAIgnoreAction(p.Comment, p.ExpiresAt)},
+		}
+		if !p.Ignore {
+			body["actions"] = []scaRiskAction{{ActionType: scaActionIgnore, Value: false, Comment: p.Comment}}
+		}
+
+		jsonBody, _ := json.Marshal(body)
+		if _, err := c.sendRequest(http.MethodPost, scaPackageRiskPath, bytes.NewReader(jsonBody), nil); err != nil {
+			return fmt.Errorf("failed to add SCA package predicate for %v: %s", p.String(), err)
+		}
+	}
+	return nil
+}
+
+// Returns the ignore history of a package version for a project, oldest first
+func (c Cx1Client) GetSCAPackagePredicatesByID(packageManager, packageName, packageVersion, projectID string) ([]SCAPackagePredicates, error) {
+	c.logger.Debugf("Fetching SCA package predicates for project %v %v %v %v", projectID, packageManager, packageName, packageVersion)
+	params := url.Values{
+		"packageManager": {packageManager},
+		"packageName":    {packageName},
+		"packageVersion": {packageVersion},
+		"projectId":      {projectID},
+	}
+
+	actions, err := c.getSCARiskHistory(scaPackageRiskPath, params)
+	if err != nil {
+		return []SCAPackagePredicates{}, err
+	}
+
+	predicates := []SCAPackagePredicates{}
+	for _, a := range actions {
+		if a.ActionType != scaActionIgnore {
+			continue
+		}
+		p := SCAPackagePredicates{
+			PackageName:    packageName,
+			PackageVersion: packageVersion,
+			PackageManager: packageManager,
+			ProjectID:      projectID,
+			Comment:        a.getComment(),
+			CreatedBy:      a.UserName,
+			CreatedAt:      a.CreatedAt,
+		}
+		_ = json.Unmarshal(a.Value, &p.Ignore)
+		p.ExpiresAt, _ = time.Parse(time.RFC3339, a.ExpirationDate)
+		predicates = append(predicates, p)
+	}
+	return predicates, nil
+}
+
+func (c Cx1Client) getSCARiskHistory(path string, params url.Values) ([]scaRiskHistoryAction, error) {
+	var history struct {
+		Actions []scaRiskHistoryAction `json:"actions"`
+	}
+
+	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("%v?%v", path, params.Encode()), nil, nil)
+	if err != nil {
+		return history.Actions, err
+	}
+
+	err = json.Unmarshal(response, &history)
+	return history.Actions, err
+}
+
+func (c Cx1Client) AddContainersResultsPredicates(predicates []ContainersResultsPredicates) error {
+	c.logger.Debugf("Adding %d Containers results predicates", len(predicates))
+
+	for _, p := range predicates {
+		if p.State == SCAStateNotExploitable && p.Comment == "" {
+			return fmt.Errorf("a justification comment is required to mark %v as not exploitable", p.String())
+		}
+	}
+
+	jsonBody, err := json.Marshal(predicates)
+	if err != nil {
+		c.logger.Tracef("Failed to add Containers results predicates: %s", err)
+		return err
+	}
+
+	_, err = c.sendRequest(http.MethodPost, containersTriagePath, bytes.NewReader(jsonBody), nil)
+	return err
+}
+
+// Returns the triage history of a container image vulnerability in a project
+func (c Cx1Client) GetContainersResultsPredicatesByID(imageName, imageTag, packageName, packageVersion, vulnerabilityID, projectID string) ([]ContainersResultsPredicates, error) {
+	c.logger.Debugf("Fetching Containers results predicates for project %v image %v:%v package %v %v %v", projectID, imageName, imageTag, packageName, packageVersion, vulnerabilityID)
+
+	var Predicates struct {
+		Predicates []ContainersResultsPredicates `json:"predicates"`
+		TotalCount uint
+	}
+	params := url.Values{
+		"imageName":       {imageName},
+		"imageTag":        {imageTag},
+		"packageName":     {packageName},
+		"packageVersion":  {packageVersion},
+		"vulnerabilityId": {vulnerabilityID},
+		"projectId":       {projectID},
+	}
+
+	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("%v?%v", containersTriagePath, params.Encode()), nil, nil)
+	if err != nil {
+		return []ContainersResultsPredicates{}, err
+	}
+
+	err = json.Unmarshal(response, &Predicates)
+	if err != nil {
+		return []ContainersResultsPredicates{}, err
+	}
+	return Predicates.Predicates, nil
+}
+
+func newSCAIgnoreAction(comment string, expiresAt time.Time) scaRiskAction {
+	action := scaRiskAction{ActionType: scaActionIgnore, Value: true, Comment: comment}
+	if !expiresAt.IsZero() {
+		action.ExpirationDate = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return action
+}
+
+func (a scaRiskHistoryAction) getComment() string {
+	if len(a.Comment) == 0 {
+		return ""
+	}
+	var comment string
+	if json.Unmarshal(a.Comment, &comment) == nil {
+		return comment
+	}
+	var commentObject struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(a.Comment, &commentObject)
+	return commentObject.Message
+}
+
+func (p SCAResultsPredicates) validate() error {
+	if p.PackageManager == "" || p.PackageName == "" || p.PackageVersion == "" || p.VulnerabilityID == "" || p.ProjectID == "" {
+		return fmt.Errorf("SCA predicate %v is missing package, vulnerability or project details", p.String())
+	}
+	if p.State == "" && !p.Ignore {
+		return fmt.Errorf("SCA predicate %v does not change the state or ignore the vulnerability", p.String())
+	}
+	if (p.Ignore || p.State == SCAStateNotExploitable) && p.Comment == "" {
+		return fmt.Errorf("a justification comment is required for SCA predicate %v", p.String())
+	}
+	if !p.ExpiresAt.IsZero() && !p.Ignore {
+		return fmt.Errorf("SCA predicate %v has an expiry but does not ignore the vulnerability", p.String())
+	}
+	return nil
+}
+
+// Returns true if the predicate is an ignore whose expiry date has passed
+func (p SCAResultsPredicates) IsExpired() bool {
+	return !p.ExpiresAt.IsZero() && p.ExpiresAt.Before(time.Now())
+}
+
+func (p SCAPackagePredicates) IsExpired() bool {
+	return !p.ExpiresAt.IsZero() && p.ExpiresAt.Before(time.Now())
+}
+
+func (p SCAResultsPredicates) String() string {
+	return fmt.Sprintf("%v in %v %v %v", p.VulnerabilityID, p.PackageManager, p.PackageName, p.PackageVersion)
+}
+
+func (p SCAPackagePredicates) String() string {
+	return fmt.Sprintf("%v %v %v", p.PackageManager, p.PackageName, p.PackageVersion)
+}
+
+func (p ContainersResultsPredicates) String() string {
+	return fmt.Sprintf("%v in %v %v (image %v:%v)", p.VulnerabilityID, p.PackageName, p.PackageVersion, p.ImageName, p.ImageTag)
+}
+
+// Returns the package manager, name and version from the PackageIdentifier, eg: Npm-lodash-4.17.15
+// The package name and version fields of the result are used when present. Otherwise the version starts at the
+// first '-' followed by a number and then a '.' or the end, so versions like 1.0.0-2 and 2.0.0-rc-1 are kept whole.
+// PackageData only holds reference links, so it does not help here.
+func (r ScanSCAResult) GetPackage() (manager, name, version string) {
+	identifier := r.Data.PackageIdentifier
+	if id := strings.Index(identifier, "-"); id > 0 {
+		manager = identifier[:id]
+		identifier = identifier[id+1:]
+	}
+
+	if r.Data.PackageVersion != "" {
+		name = r.Data.PackageName
+		if name == "" {
+			name = strings.TrimSuffix(identifier, "-"+r.Data.PackageVersion)
+		}
+		return manager, name, r.Data.PackageVersion
+	}
+
+	for id := 1; id < len(identifier)-1; id++ {
+		if identifier[id] == '-' && isSCAVersionStart(identifier[id+1:]) {
+			return manager, identifier[:id], identifier[id+1:]
+		}
+	}
+	return manager, identifier, ""
+}
+
+// true if s starts with a number followed by a '.' or nothing, eg: 4.17.15 or 2 but not 3parclient or 2-utils
+func isSCAVersionStart(s string) bool {
+	digits := 0
+	for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && (digits == len(s) || s[digits] == '.')
+}
+
+func (r ScanSCAResult) CreateResultsPredicate(projectId string) SCAResultsPredicates {
+	manager, name, version := r.GetPackage()
+	return SCAResultsPredicates{
+		PackageName:     name,
+		PackageVersion:  version,
+		PackageManager:  manager,
+		VulnerabilityID: r.VulnerabilityDetails.CveName,
+		ProjectID:       projectId,
+	}
+}
+
+func (r ScanSCAResult) CreatePackagePredicate(projectId string) SCAPackagePredicates {
+	manager, name, version := r.GetPackage()
+	return SCAPackagePredicates{
+		PackageName:    name,
+		PackageVersion: version,
+		PackageManager: manager,
+		ProjectID:      projectId,
+	}
+}
+
+func (r ScanContainersResult) CreateResultsPredicate(projectId, scanId string) ContainersResultsPredicates {
+	return ContainersResultsPredicates{
+		ResultsPredicatesBase: ResultsPredicatesBase{
+			SimilarityID: r.SimilarityID,
+			ProjectID:    projectId,
+			ScanID:       scanId,
+		},
+		ImageName:       r.Data.ImageName,
+		ImageTag:        r.Data.ImageTag,
+		PackageName:     r.Data.PackageName,
+		PackageVersion:  r.Data.PackageVersion,
+		VulnerabilityID: r.VulnerabilityDetails.CveName,
+	}
+}
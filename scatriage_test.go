@@ -0,0 +1,41 @@
+package Cx1ClientGo
+
+import "testing"
+
+func TestScanSCAResultGetPackage(t *testing.T) {
+	tests := []struct {
+		identifier string
+		pkgName    string // result fields, when returned
+		pkgVersion string
+		manager    string
+		name       string
+		version    string
+	}{
+		{identifier: "Npm-lodash-4.17.15", manager: "Npm", name: "lodash", version: "4.17.15"},
+		{identifier: "Npm-@babel/core-7.0.0-beta.1", manager: "Npm", name: "@babel/core", version: "7.0.0-beta.1"},
+		{identifier: "Npm-es5-ext-0.10.53", manager: "Npm", name: "es5-ext", version: "0.10.53"},
+		{identifier: "Maven-org.apache.commons:commons-lang3-3.12.0", manager: "Maven", name: "org.apache.commons:commons-lang3", version: "3.12.0"},
+		{identifier: "Python-django-rest-framework-3.14.0", manager: "Python", name: "django-rest-framework", version: "3.14.0"},
+		{identifier: "Nuget-Newtonsoft.Json-13.0.1", manager: "Nuget", name: "Newtonsoft.Json", version: "13.0.1"},
+		{identifier: "Npm-pkg-1.0.0-2", manager: "Npm", name: "pkg", version: "1.0.0-2"},
+		{identifier: "Npm-x-2.0.0-rc-1", manager: "Npm", name: "x", version: "2.0.0-rc-1"},
+		{identifier: "Python-python-3parclient-4.2.0", manager: "Python", name: "python-3parclient", version: "4.2.0"},
+		{identifier: "Npm-left-pad-2", manager: "Npm", name: "left-pad", version: "2"},
+		{identifier: "Npm-pkg-1.0.0-2", pkgVersion: "1.0.0-2", manager: "Npm", name: "pkg", version: "1.0.0-2"},
+		{identifier: "Npm-weird-1-name-3.0", pkgVersion: "3.0", manager: "Npm", name: "weird-1-name", version: "3.0"},
+		{identifier: "Npm-weird-1-name-3.0", pkgName: "weird-1-name", pkgVersion: "3.0", manager: "Npm", name: "weird-1-name", version: "3.0"},
+		{identifier: "Npm-lodash", manager: "Npm", name: "lodash", version: ""},
+		{identifier: "lodash", manager: "", name: "lodash", version: ""},
+		{identifier: "", manager: "", name: "", version: ""},
+	}
+
+	for _, test := range tests {
+		var r ScanSCAResult
+		r.Data.PackageIdentifier = test.identifier
+		r.Data.PackageName, r.Data.PackageVersion = test.pkgName, test.pkgVersion
+		manager, name, version := r.GetPackage()
+		if manager != test.manager || name != test.name || version != test.version {
+			t.Errorf("GetPackage(%q) = %q, %q, %q, expected %q, %q, %q", test.identifier, manager, name, version, test.manager, test.name, test.version)
+		}
+	}
+}
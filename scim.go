@@ -0,0 +1,1170 @@
+package Cx1ClientGo
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	SCIM 2.0 (RFC 7643/7644) bridge in front of a Cx1 tenant. The SCIMServer is an http.Handler serving:
+		/Users, /Users/{id}         GET (filter, pagination), POST, PUT, PATCH, DELETE
+		/Groups, /Groups/{id}       GET (filter, pagination), POST, PUT, PATCH, DELETE
+		/ServiceProviderConfig      GET
+	The endpoints can be mounted under any prefix, eg: http.Handle("/scim/v2/", NewSCIMServer(&cx1client, "https://bridge/scim/v2", token)).
+	Every request must carry the bearer token, a server without a token rejects all requests.
+
+	Groups are identified by their path without the leading slash, so displayName "parent/child" is a subgroup.
+	Filters support the eq, ne, co, sw, ew, gt, lt, ge, le and pr operators combined with and/or (no parentheses), and
+	are evaluated by the bridge after reading the users/groups from Cx1.
+*/
+
+const (
+	scimSchemaUser  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaList  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaPatch = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimSchemaError = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimSchemaSPC   = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	scimDefaultCount = 100
+	scimMaxCount     = 1000
+)
+
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e scimError) Error() string {
+	return e.detail
+}
+
+func scimBadRequest(scimType, format string, args ...interface{}) error {
+	return scimError{status: http.StatusBadRequest, scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+func scimNotFound(format string, args ...interface{}) error {
+	return scimError{status: http.StatusNotFound, detail: fmt.Sprintf(format, args...)}
+}
+
+// a failed lookup is a 404 only if Cx1 returned 404, other failures are server errors
+func scimLookupError(err error, resource, id string) error {
+	if strings.HasPrefix(err.Error(), "HTTP 404") {
+		return scimNotFound("%v %v not found", resource, id)
+	}
+	return fmt.Errorf("failed to get %v %v: %s", resource, id, err)
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// The token is the bearer token that SCIM clients must send, it is required
+func NewSCIMServer(client *Cx1Client, baseURL, token string) *SCIMServer {
+	return &SCIMServer{
+		client:  client,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+	}
+}
+
+func (s *SCIMServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token == "" {
+		s.writeError(w, scimError{status: http.StatusInternalServerError, detail: "SCIM server has no bearer token configured"})
+		return
+	}
+	token, ok := scimBearerToken(r.Header.Get("Authorization"))
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		s.writeError(w, scimError{status: http.StatusUnauthorized, detail: "invalid or missing bearer token"})
+		return
+	}
+
+	resource, id := scimRoute(r.URL.Path)
+	s.client.logger.Debugf("SCIM %v %v (resource %v, id %v)", r.Method, r.URL.Path, resource, id)
+
+	var result interface{}
+	var err error
+	status := http.StatusOK
+
+	switch {
+	case resource == "ServiceProviderConfig" && r.Method == http.MethodGet:
+		result = scimServiceProviderConfig()
+	case resource == "Users" && id == "" && r.Method == http.MethodGet:
+		result, err = s.listUsers(r)
+	case resource == "Users" && id == "" && r.Method == http.MethodPost:
+		result, err = s.createUser(r.Body)
+		status = http.StatusCreated
+	case resource == "Users" && id != "" && r.Method == http.MethodGet:
+		result, err = s.getUser(id)
+	case resource == "Users" && id != "" && r.Method == http.MethodPut:
+		result, err = s.replaceUser(id, r.Body)
+	case resource == "Users" && id != "" && r.Method == http.MethodPatch:
+		result, err = s.patchUser(id, r.Body)
+	case resource == "Users" && id != "" && r.Method == http.MethodDelete:
+		err = s.deleteUser(id)
+		status = http.StatusNoContent
+	case resource == "Groups" && id == "" && r.Method == http.MethodGet:
+		result, err = s.listGroups(r)
+	case resource == "Groups" && id == "" && r.Method == http.MethodPost:
+		result, err = s.createGroup(r.Body)
+		status = http.StatusCreated
+	case resource == "Groups" && id != "" && r.Method == http.MethodGet:
+		result, err = s.getGroup(id, true)
+	case resource == "Groups" && id != "" && r.Method == http.MethodPut:
+		result, err = s.replaceGroup(id, r.Body)
+	case resource == "Groups" && id != "" && r.Method == http.MethodPatch:
+		result, err = s.patchGroup(id, r.Body)
+	case resource == "Groups" && id != "" && r.Method == http.MethodDelete:
+		err = s.deleteGroup(id)
+		status = http.StatusNoContent
+	case resource == "":
+		err = scimNotFound("unknown endpoint %v", r.URL.Path)
+	default:
+		err = scimError{status: http.StatusMethodNotAllowed, detail: fmt.Sprintf("method %v is not supported on %v", r.Method, r.URL.Path)}
+	}
+
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", scimContentType)
+	if loc := scimLocation(result); loc != "" && status == http.StatusCreated {
+		w.Header().Set("Location", loc)
+	}
+	w.WriteHeader(status)
+	if status != http.StatusNoContent {
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			s.client.logger.Errorf("Failed to write SCIM response: %s", err)
+		}
+	}
+}
+
+// returns the token from an Authorization header of the form "Bearer <token>", the scheme is case-insensitive
+func scimBearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// returns the resource type and ID from the request path, ignoring any prefix the handler is mounted under
+func scimRoute(path string) (string, string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	isResource := func(p string) bool {
+		return p == "Users" || p == "Groups" || p == "ServiceProviderConfig"
+	}
+	n := len(parts)
+	if n >= 1 && isResource(parts[n-1]) {
+		return parts[n-1], ""
+	}
+	if n >= 2 && isResource(parts[n-2]) {
+		return parts[n-2], parts[n-1]
+	}
+	return "", ""
+}
+
+func scimLocation(result interface{}) string {
+	switch r := result.(type) {
+	case SCIMUser:
+		if r.Meta != nil {
+			return r.Meta.Location
+		}
+	case SCIMGroup:
+		if r.Meta != nil {
+			return r.Meta.Location
+		}
+	}
+	return ""
+}
+
+func (s *SCIMServer) writeError(w http.ResponseWriter, err error) {
+	serr, ok := err.(scimError)
+	if !ok {
+		serr = scimError{status: http.StatusInternalServerError, detail: err.Error()}
+	}
+	if serr.status >= 500 {
+		s.client.logger.Errorf("SCIM request failed: %s", serr.detail)
+	} else {
+		s.client.logger.Debugf("SCIM request rejected (%d): %s", serr.status, serr.detail)
+	}
+
+	body := map[string]interface{}{
+		"schemas": []string{scimSchemaError},
+		"status":  strconv.Itoa(serr.status),
+		"detail":  serr.detail,
+	}
+	if serr.scimType != "" {
+		body["scimType"] = serr.scimType
+	}
+
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(serr.status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func scimServiceProviderConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":        []string{scimSchemaSPC},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]string{
+			{"type": "oauthbearertoken", "name": "Bearer token", "description": "Static bearer token configured on the bridge"},
+		},
+	}
+}
+
+// Users
+
+func (s *SCIMServer) toSCIMUser(user User, withGroups bool) (SCIMUser, error) {
+	u := SCIMUser{
+		Schemas:     []string{scimSchemaUser},
+		ID:          user.UserID,
+		UserName:    user.UserName,
+		DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Active:      &user.Enabled,
+		Meta:        &SCIMMeta{ResourceType: "User", Location: fmt.Sprintf("%v/Users/%v", s.BaseURL, user.UserID)},
+	}
+	u.Name.GivenName = user.FirstName
+	u.Name.FamilyName = user.LastName
+	u.Name.Formatted = u.DisplayName
+	if user.Email != "" {
+		u.Emails = []SCIMMultiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+
+	if withGroups {
+		groups, err := s.client.GetUserGroups(&user)
+		if err != nil {
+			return u, err
+		}
+		for _, g := range groups {
+			u.Groups = append(u.Groups, s.groupReference(g))
+		}
+	}
+	return u, nil
+}
+
+func (s *SCIMServer) groupReference(g Group) SCIMMultiValue {
+	return SCIMMultiValue{
+		Value:   g.GroupID,
+		Display: strings.TrimPrefix(g.Path, "/"),
+		Ref:     fmt.Sprintf("%v/Groups/%v", s.BaseURL, g.GroupID),
+	}
+}
+
+func (s *SCIMServer) listUsers(r *http.Request) (interface{}, error) {
+	filter, startIndex, count, err := scimListParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.client.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserName < users[j].UserName })
+
+	withGroups := filter.references("groups")
+	matches := []SCIMUser{}
+	for _, user := range users {
+		u, err := s.toSCIMUser(user, withGroups)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(u) {
+			matches = append(matches, u)
+		}
+	}
+
+	page := scimPage(len(matches), startIndex, count)
+	return scimListResponse{
+		Schemas:      []string{scimSchemaList},
+		TotalResults: len(matches),
+		StartIndex:   startIndex,
+		ItemsPerPage: page.count(),
+		Resources:    matches[page.start:page.end],
+	}, nil
+}
+
+func (s *SCIMServer) getUser(id string) (SCIMUser, error) {
+	user, err := s.client.GetUserByID(id)
+	if err != nil {
+		return SCIMUser{}, scimLookupError(err, "user", id)
+	}
+	return s.toSCIMUser(user, true)
+}
+
+func (s *SCIMServer) createUser(body io.Reader) (SCIMUser, error) {
+	var u SCIMUser
+	if err := json.NewDecoder(body).Decode(&u); err != nil {
+		return u, scimBadRequest("invalidSyntax", "failed to parse user: %s", err)
+	}
+	if u.UserName == "" {
+		return u, scimBadRequest("invalidValue", "userName is required")
+	}
+
+	existing, err := s.client.GetUsersByUserName(u.UserName)
+	if err != nil {
+		return u, err
+	}
+	for _, e := range existing {
+		if strings.EqualFold(e.UserName, u.UserName) {
+			return u, scimError{status: http.StatusConflict, scimType: "uniqueness", detail: fmt.Sprintf("user %v already exists", u.UserName)}
+		}
+	}
+
+	user := User{Enabled: u.IsActive()} // active defaults to true when omitted
+	applySCIMUser(&user, u)
+	user, err = s.client.CreateUser(user)
+	if err != nil {
+		return u, err
+	}
+	return s.toSCIMUser(user, false)
+}
+
+func (s *SCIMServer) replaceUser(id string, body io.Reader) (SCIMUser, error) {
+	var u SCIMUser
+	if err := json.NewDecoder(body).Decode(&u); err != nil {
+		return u, scimBadRequest("invalidSyntax", "failed to parse user: %s", err)
+	}
+
+	user, err := s.client.GetUserByID(id)
+	if err != nil {
+		return u, scimLookupError(err, "user", id)
+	}
+	user.Enabled = u.IsActive() // as for createUser, active defaults to true when omitted
+	applySCIMUser(&user, u)
+	if err = s.client.UpdateUser(&user); err != nil {
+		return u, err
+	}
+	return s.getUser(id)
+}
+
+func (s *SCIMServer) patchUser(id string, body io.Reader) (SCIMUser, error) {
+	patch, err := decodeSCIMPatch(body)
+	if err != nil {
+		return SCIMUser{}, err
+	}
+
+	user, err := s.client.GetUserByID(id)
+	if err != nil {
+		return SCIMUser{}, scimLookupError(err, "user", id)
+	}
+	u, err := s.toSCIMUser(user, false)
+	if err != nil {
+		return u, err
+	}
+
+	for _, op := range patch.Operations {
+		if err = patchSCIMUser(&u, op); err != nil {
+			return u, err
+		}
+	}
+
+	user.Enabled = u.IsActive()
+	applySCIMUser(&user, u)
+	if err = s.client.UpdateUser(&user); err != nil {
+		return u, err
+	}
+	return s.getUser(id)
+}
+
+func (s *SCIMServer) deleteUser(id string) error {
+	user, err := s.client.GetUserByID(id)
+	if err != nil {
+		return scimLookupError(err, "user", id)
+	}
+	if s.DisableOnDelete {
+		user.Enabled = false
+		return s.client.UpdateUser(&user)
+	}
+	return s.client.DeleteUser(&user)
+}
+
+// copies the writable SCIM attributes to the Cx1 user, except for the enabled state
+func applySCIMUser(user *User, u SCIMUser) {
+	if u.UserName != "" {
+		user.UserName = u.UserName
+	}
+	user.FirstName = u.Name.GivenName
+	user.LastName = u.Name.FamilyName
+	user.Email = u.GetEmail()
+}
+
+func patchSCIMUser(u *SCIMUser, op SCIMPatchOperation) error {
+	action := strings.ToLower(op.Op)
+	if action != "add" && action != "replace" && action != "remove" {
+		return scimBadRequest("invalidSyntax", "unsupported patch operation %v", op.Op)
+	}
+
+	if op.Path == "" {
+		if action == "remove" {
+			return scimBadRequest("noTarget", "remove operations require a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return scimBadRequest("invalidValue", "patch value without a path must be an object: %s", err)
+		}
+		for key, value := range values {
+			if key == "name" { // nested object
+				var name map[string]json.RawMessage
+				if err := json.Unmarshal(value, &name); err != nil {
+					return scimBadRequest("invalidValue", "invalid name: %s", err)
+				}
+				for sub, v := range name {
+					if err := patchSCIMUserAttribute(u, action, "name."+sub, v); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := patchSCIMUserAttribute(u, action, key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return patchSCIMUserAttribute(u, action, op.Path, op.Value)
+}
+
+func patchSCIMUserAttribute(u *SCIMUser, action, path string, value json.RawMessage) error {
+	path = strings.TrimPrefix(path, scimSchemaUser+":")
+	lower := strings.ToLower(path)
+
+	str := func() (string, error) {
+		if action == "remove" {
+			return "", nil
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return "", scimBadRequest("invalidValue", "%v must be a string", path)
+		}
+		return s, nil
+	}
+
+	var err error
+	switch {
+	case lower == "active":
+		active := false
+		if action != "remove" {
+			active, err = scimBool(value)
+		}
+		u.Active = &active
+	case lower == "username":
+		if action == "remove" {
+			return scimBadRequest("mutability", "userName cannot be removed")
+		}
+		u.UserName, err = str()
+	case lower == "displayname":
+		u.DisplayName, err = str()
+	case lower == "name.givenname":
+		u.Name.GivenName, err = str()
+	case lower == "name.familyname":
+		u.Name.FamilyName, err = str()
+	case lower == "name.formatted", lower == "externalid", lower == "title", lower == "preferredlanguage", lower == "locale", lower == "timezone":
+		// not stored in Cx1
+	case lower == "emails":
+		if action == "remove" {
+			u.Emails = nil
+		} else if err = json.Unmarshal(value, &u.Emails); err != nil {
+			err = scimBadRequest("invalidValue", "invalid emails: %s", err)
+		}
+	case strings.HasPrefix(lower, "emails[") && strings.HasSuffix(lower, "].value"):
+		var email string
+		if email, err = str(); err == nil {
+			if email == "" {
+				u.Emails = nil
+			} else {
+				u.Emails = []SCIMMultiValue{{Value: email, Type: "work", Primary: true}}
+			}
+		}
+	default:
+		return scimBadRequest("invalidPath", "unsupported attribute %v", path)
+	}
+	return err
+}
+
+// SCIM clients send booleans as JSON booleans or as strings ("True")
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, scimBadRequest("invalidValue", "expected a boolean, got %v", string(value))
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, scimBadRequest("invalidValue", "expected a boolean, got %v", s)
+	}
+	return b, nil
+}
+
+// Groups
+
+func (s *SCIMServer) toSCIMGroup(group Group, withMembers bool) (SCIMGroup, error) {
+	g := SCIMGroup{
+		Schemas:     []string{scimSchemaGroup},
+		ID:          group.GroupID,
+		DisplayName: strings.TrimPrefix(group.Path, "/"),
+		Meta:        &SCIMMeta{ResourceType: "Group", Location: fmt.Sprintf("%v/Groups/%v", s.BaseURL, group.GroupID)},
+	}
+	if g.DisplayName == "" {
+		g.DisplayName = group.Name
+	}
+
+	if withMembers {
+		members, err := s.client.GetGroupMembers(&group)
+		if err != nil {
+			return g, err
+		}
+		g.Members = []SCIMMultiValue{}
+		for _, m := range members {
+			g.Members = append(g.Members, SCIMMultiValue{
+				Value:   m.UserID,
+				Display: m.UserName,
+				Ref:     fmt.Sprintf("%v/Users/%v", s.BaseURL, m.UserID),
+			})
+		}
+	}
+	return g, nil
+}
+
+func flattenGroups(groups []Group) []Group {
+	flat := []Group{}
+	for _, g := range groups {
+		flat = append(flat, g)
+		flat = append(flat, flattenGroups(g.SubGroups)...)
+	}
+	return flat
+}
+
+func (s *SCIMServer) listGroups(r *http.Request) (interface{}, error) {
+	filter, startIndex, count, err := scimListParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.client.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	groups = flattenGroups(groups)
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Path < groups[j].Path })
+
+	excluded := strings.ToLower(r.URL.Query().Get("excludedAttributes"))
+	withMembers := !strings.Contains(excluded, "members")
+	filterMembers := filter.references("members")
+
+	matches := []SCIMGroup{}
+	for _, group := range groups {
+		g, err := s.toSCIMGroup(group, filterMembers)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(g) {
+			matches = append(matches, g)
+		}
+	}
+
+	page := scimPage(len(matches), startIndex, count)
+	resources := matches[page.start:page.end]
+	for id := range resources {
+		if withMembers && resources[id].Members == nil {
+			group, err := s.client.GetGroupByID(resources[id].ID)
+			if err != nil {
+				return nil, err
+			}
+			if resources[id], err = s.toSCIMGroup(group, true); err != nil {
+				return nil, err
+			}
+		} else if !withMembers {
+			resources[id].Members = nil
+		}
+	}
+
+	return scimListResponse{
+		Schemas:      []string{scimSchemaList},
+		TotalResults: len(matches),
+		StartIndex:   startIndex,
+		ItemsPerPage: page.count(),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *SCIMServer) getGroup(id string, withMembers bool) (SCIMGroup, error) {
+	group, err := s.client.GetGroupByID(id)
+	if err != nil {
+		return SCIMGroup{}, scimLookupError(err, "group", id)
+	}
+	return s.toSCIMGroup(group, withMembers)
+}
+
+func (s *SCIMServer) createGroup(body io.Reader) (SCIMGroup, error) {
+	var g SCIMGroup
+	if err := json.NewDecoder(body).Decode(&g); err != nil {
+		return g, scimBadRequest("invalidSyntax", "failed to parse group: %s", err)
+	}
+	path := "/" + strings.Trim(g.DisplayName, "/")
+	if path == "/" {
+		return g, scimBadRequest("invalidValue", "displayName is required")
+	}
+
+	if _, err := s.client.GetGroupByPath(path); err == nil {
+		return g, scimError{status: http.StatusConflict, scimType: "uniqueness", detail: fmt.Sprintf("group %v already exists", path)}
+	}
+
+	var group Group
+	var err error
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		parent, err := s.client.GetGroupByPath(path[:i])
+		if err != nil {
+			return g, scimBadRequest("invalidValue", "parent group %v does not exist", path[:i])
+		}
+		group, err = s.client.CreateChildGroup(&parent, path[i+1:])
+		if err != nil {
+			return g, err
+		}
+	} else {
+		group, err = s.client.CreateGroup(path[1:])
+		if err != nil {
+			return g, err
+		}
+	}
+
+	if err = s.setGroupMembers(group.GroupID, g.Members, "replace"); err != nil {
+		return g, err
+	}
+	return s.getGroup(group.GroupID, true)
+}
+
+func (s *SCIMServer) replaceGroup(id string, body io.Reader) (SCIMGroup, error) {
+	var g SCIMGroup
+	if err := json.NewDecoder(body).Decode(&g); err != nil {
+		return g, scimBadRequest("invalidSyntax", "failed to parse group: %s", err)
+	}
+
+	if err := s.renameGroup(id, g.DisplayName); err != nil {
+		return g, err
+	}
+	if err := s.setGroupMembers(id, g.Members, "replace"); err != nil {
+		return g, err
+	}
+	return s.getGroup(id, true)
+}
+
+func (s *SCIMServer) patchGroup(id string, body io.Reader) (SCIMGroup, error) {
+	patch, err := decodeSCIMPatch(body)
+	if err != nil {
+		return SCIMGroup{}, err
+	}
+	if _, err = s.client.GetGroupByID(id); err != nil {
+		return SCIMGroup{}, scimLookupError(err, "group", id)
+	}
+
+	for _, op := range patch.Operations {
+		action := strings.ToLower(op.Op)
+		path := strings.TrimPrefix(op.Path, scimSchemaGroup+":")
+		lower := strings.ToLower(path)
+
+		switch {
+		case lower == "" && action != "remove":
+			var values struct {
+				DisplayName *string          `json:"displayName"`
+				Members     []SCIMMultiValue `json:"members"`
+			}
+			if err = json.Unmarshal(op.Value, &values); err != nil {
+				return SCIMGroup{}, scimBadRequest("invalidValue", "patch value without a path must be an object: %s", err)
+			}
+			if values.DisplayName != nil {
+				err = s.renameGroup(id, *values.DisplayName)
+			}
+			if err == nil && values.Members != nil {
+				err = s.setGroupMembers(id, values.Members, action)
+			}
+		case lower == "displayname" && action != "remove":
+			var name string
+			if err = json.Unmarshal(op.Value, &name); err != nil {
+				return SCIMGroup{}, scimBadRequest("invalidValue", "displayName must be a string")
+			}
+			err = s.renameGroup(id, name)
+		case lower == "members":
+			var members []SCIMMultiValue
+			if len(op.Value) > 0 {
+				if err = json.Unmarshal(op.Value, &members); err != nil {
+					return SCIMGroup{}, scimBadRequest("invalidValue", "members must be an array: %s", err)
+				}
+			}
+			if action == "remove" && len(members) == 0 {
+				err = s.setGroupMembers(id, []SCIMMultiValue{}, "replace")
+			} else {
+				err = s.setGroupMembers(id, members, action)
+			}
+		case strings.HasPrefix(lower, "members[") && action == "remove":
+			filter, ferr := parseSCIMMemberPath(path)
+			if ferr != nil {
+				return SCIMGroup{}, ferr
+			}
+			current, gerr := s.getGroup(id, true)
+			if gerr != nil {
+				return SCIMGroup{}, gerr
+			}
+			remove := []SCIMMultiValue{}
+			for _, m := range current.Members {
+				if filter.Matches(m) {
+					remove = append(remove, m)
+				}
+			}
+			err = s.setGroupMembers(id, remove, "remove")
+		default:
+			return SCIMGroup{}, scimBadRequest("invalidPath", "unsupported patch operation %v on %v", op.Op, op.Path)
+		}
+
+		if err != nil {
+			return SCIMGroup{}, err
+		}
+	}
+
+	return s.getGroup(id, true)
+}
+
+// parses the filter of a members[filter] patch path
+func parseSCIMMemberPath(path string) (SCIMFilter, error) {
+	if !strings.HasPrefix(strings.ToLower(path), "members[") || !strings.HasSuffix(path, "]") || len(path) <= len("members[]") {
+		return SCIMFilter{}, scimBadRequest("invalidPath", "invalid members path %v", path)
+	}
+	filter, err := ParseSCIMFilter(path[len("members[") : len(path)-1])
+	if err == nil && len(filter.alternatives) == 0 { // an empty filter would match every member
+		return filter, scimBadRequest("invalidPath", "empty filter in members path %v", path)
+	}
+	return filter, err
+}
+
+func (s *SCIMServer) deleteGroup(id string) error {
+	group, err := s.client.GetGroupByID(id)
+	if err != nil {
+		return scimLookupError(err, "group", id)
+	}
+	return s.client.DeleteGroup(&group)
+}
+
+// renames the group, moving a group to another parent is not supported
+func (s *SCIMServer) renameGroup(id, displayName string) error {
+	group, err := s.client.GetGroupByID(id)
+	if err != nil {
+		return scimLookupError(err, "group", id)
+	}
+
+	path := "/" + strings.Trim(displayName, "/")
+	if path == "/" || path == group.Path {
+		return nil
+	}
+	i := strings.LastIndex(path, "/")
+	j := strings.LastIndex(group.Path, "/")
+	if path[:i] != group.Path[:j] {
+		return scimBadRequest("mutability", "group %v cannot be moved to %v", group.Path, path)
+	}
+
+	group.Name = path[i+1:]
+	return s.client.UpdateGroup(&group)
+}
+
+// action is add, remove or replace
+func (s *SCIMServer) setGroupMembers(groupId string, members []SCIMMultiValue, action string) error {
+	ids := []string{}
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+
+	current := []string{}
+	if action == "replace" {
+		users, err := s.client.GetGroupMembersByID(groupId)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			current = append(current, u.UserID)
+			if !slices.Contains(ids, u.UserID) {
+				if err = s.setMembership(u.UserID, groupId, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, id := range ids {
+		switch action {
+		case "add", "replace":
+			if slices.Contains(current, id) {
+				continue
+			}
+			if err := s.setMembership(id, groupId, true); err != nil {
+				return err
+			}
+		case "remove":
+			if err := s.setMembership(id, groupId, false); err != nil {
+				return err
+			}
+		default:
+			return scimBadRequest("invalidSyntax", "unsupported patch operation %v", action)
+		}
+	}
+	return nil
+}
+
+func (s *SCIMServer) setMembership(userId, groupId string, member bool) error {
+	user, err := s.client.GetUserByID(userId)
+	if err != nil {
+		return scimBadRequest("invalidValue", "user %v not found: %s", userId, err)
+	}
+	if _, err = s.client.GetUserGroups(&user); err != nil {
+		return err
+	}
+	if member {
+		return s.client.AssignUserToGroupByID(&user, groupId)
+	}
+	return s.client.RemoveUserFromGroupByID(&user, groupId)
+}
+
+func decodeSCIMPatch(body io.Reader) (SCIMPatchRequest, error) {
+	var patch SCIMPatchRequest
+	if err := json.NewDecoder(body).Decode(&patch); err != nil {
+		return patch, scimBadRequest("invalidSyntax", "failed to parse patch request: %s", err)
+	}
+	if len(patch.Schemas) > 0 && !slices.Contains(patch.Schemas, scimSchemaPatch) {
+		return patch, scimBadRequest("invalidSyntax", "patch request must use schema %v", scimSchemaPatch)
+	}
+	return patch, nil
+}
+
+// Pagination
+
+type scimPageRange struct {
+	start, end int
+}
+
+func (p scimPageRange) count() int {
+	return p.end - p.start
+}
+
+func scimPage(total, startIndex, count int) scimPageRange {
+	start := startIndex - 1
+	if start > total {
+		start = total
+	}
+	end := start + count
+	if end > total {
+		end = total
+	}
+	return scimPageRange{start: start, end: end}
+}
+
+func scimListParams(r *http.Request) (SCIMFilter, int, int, error) {
+	query := r.URL.Query()
+	startIndex, count := 1, scimDefaultCount
+
+	if v := query.Get("startIndex"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return SCIMFilter{}, 0, 0, scimBadRequest("invalidValue", "invalid startIndex %v", v)
+		}
+		if i > 1 {
+			startIndex = i
+		}
+	}
+	if v := query.Get("count"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return SCIMFilter{}, 0, 0, scimBadRequest("invalidValue", "invalid count %v", v)
+		}
+		count = i
+		if count < 0 {
+			count = 0
+		}
+		if count > scimMaxCount {
+			count = scimMaxCount
+		}
+	}
+
+	filter, err := ParseSCIMFilter(query.Get("filter"))
+	return filter, startIndex, count, err
+}
+
+// Filters
+
+type scimComparison struct {
+	attribute string
+	operator  string
+	value     interface{}
+}
+
+// Parses a SCIM filter expression such as: userName eq "bob" and active eq true
+func ParseSCIMFilter(filter string) (SCIMFilter, error) {
+	f := SCIMFilter{}
+	tokens, err := scimFilterTokens(filter)
+	if err != nil {
+		return f, err
+	}
+	if len(tokens) == 0 {
+		return f, nil
+	}
+
+	current := []scimComparison{}
+	for i := 0; i < len(tokens); {
+		if i+1 >= len(tokens) {
+			return f, scimBadRequest("invalidFilter", "incomplete filter expression: %v", filter)
+		}
+		cmp := scimComparison{attribute: tokens[i], operator: strings.ToLower(tokens[i+1])}
+		i += 2
+
+		switch cmp.operator {
+		case "pr":
+		case "eq", "ne", "co", "sw", "ew", "gt", "lt", "ge", "le":
+			if i >= len(tokens) {
+				return f, scimBadRequest("invalidFilter", "missing value for %v %v", cmp.attribute, cmp.operator)
+			}
+			if err := json.Unmarshal([]byte(tokens[i]), &cmp.value); err != nil {
+				return f, scimBadRequest("invalidFilter", "invalid value %v in filter", tokens[i])
+			}
+			i++
+		default:
+			return f, scimBadRequest("invalidFilter", "unsupported operator %v", cmp.operator)
+		}
+		current = append(current, cmp)
+
+		if i < len(tokens) {
+			switch strings.ToLower(tokens[i]) {
+			case "and":
+			case "or":
+				f.alternatives = append(f.alternatives, current)
+				current = []scimComparison{}
+			default:
+				return f, scimBadRequest("invalidFilter", "expected and/or, got %v", tokens[i])
+			}
+			i++
+			if i == len(tokens) {
+				return f, scimBadRequest("invalidFilter", "filter ends with a logical operator")
+			}
+		}
+	}
+	f.alternatives = append(f.alternatives, current)
+	return f, nil
+}
+
+// splits on whitespace, keeping quoted strings (with escapes) as single tokens
+func scimFilterTokens(filter string) ([]string, error) {
+	tokens := []string{}
+	var token strings.Builder
+	inQuote, escaped := false, false
+
+	for _, r := range filter {
+		switch {
+		case inQuote:
+			token.WriteRune(r)
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inQuote = false
+			}
+		case r == '"':
+			inQuote = true
+			token.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		case r == '(' || r == ')':
+			return tokens, scimBadRequest("invalidFilter", "grouping with parentheses is not supported")
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return tokens, scimBadRequest("invalidFilter", "unterminated string in filter")
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+func (f SCIMFilter) references(attribute string) bool {
+	for _, alt := range f.alternatives {
+		for _, cmp := range alt {
+			if strings.HasPrefix(strings.ToLower(cmp.attribute), attribute) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if the resource (any JSON-serializable value) matches the filter, an empty filter matches everything
+func (f SCIMFilter) Matches(resource interface{}) bool {
+	if len(f.alternatives) == 0 {
+		return true
+	}
+
+	var doc interface{}
+	data, err := json.Marshal(resource)
+	if err != nil || json.Unmarshal(data, &doc) != nil {
+		return false
+	}
+
+	for _, alt := range f.alternatives {
+		matched := true
+		for _, cmp := range alt {
+			if !cmp.matches(doc) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp scimComparison) matches(doc interface{}) bool {
+	attribute := strings.TrimPrefix(strings.TrimPrefix(cmp.attribute, scimSchemaUser+":"), scimSchemaGroup+":")
+	values := scimAttributeValues(doc, strings.Split(attribute, "."))
+
+	if cmp.operator == "pr" {
+		for _, v := range values {
+			if v != nil && v != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, v := range values {
+		if m, ok := v.(map[string]interface{}); ok { // multi-valued attribute without sub-attribute compares the value
+			v = scimMapValue(m, "value")
+		}
+		if scimCompare(v, cmp.operator, cmp.value) {
+			return true
+		}
+	}
+	return cmp.operator == "ne" && len(values) == 0
+}
+
+func scimMapValue(m map[string]interface{}, key string) interface{} {
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func scimAttributeValues(doc interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if list, ok := doc.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{doc}
+	}
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		return scimAttributeValues(scimMapValue(d, path[0]), path[1:])
+	case []interface{}:
+		values := []interface{}{}
+		for _, item := range d {
+			values = append(values, scimAttributeValues(item, path)...)
+		}
+		return values
+	}
+	return []interface{}{}
+}
+
+func scimCompare(actual interface{}, operator string, expected interface{}) bool {
+	switch e := expected.(type) {
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return operator == "ne"
+		}
+		a, e = strings.ToLower(a), strings.ToLower(e)
+		switch operator {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "lt":
+			return a < e
+		case "ge":
+			return a >= e
+		case "le":
+			return a <= e
+		}
+	case bool:
+		a, ok := actual.(bool)
+		switch operator {
+		case "eq":
+			return ok && a == e
+		case "ne":
+			return !ok || a != e
+		}
+	case float64:
+		a, ok := actual.(float64)
+		if !ok {
+			return operator == "ne"
+		}
+		switch operator {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		case "gt":
+			return a > e
+		case "lt":
+			return a < e
+		case "ge":
+			return a >= e
+		case "le":
+			return a <= e
+		}
+	case nil:
+		switch operator {
+		case "eq":
+			return actual == nil
+		case "ne":
+			return actual != nil
+		}
+	}
+	return false
+}
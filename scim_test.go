@@ -0,0 +1,154 @@
+package Cx1ClientGo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	active := true
+	user := SCIMUser{
+		UserName: "Bob.Smith",
+		Active:   &active,
+		Emails:   []SCIMMultiValue{{Value: "bob@example.com", Primary: true}},
+	}
+	user.Name.GivenName = "Bob"
+
+	tests := []struct {
+		filter  string
+		matches bool
+		invalid bool
+	}{
+		{filter: "", matches: true},
+		{filter: `userName eq "bob.smith"`, matches: true},
+		{filter: `userName eq "alice"`, matches: false},
+		{filter: `userName ne "alice"`, matches: true},
+		{filter: `userName sw "bob"`, matches: true},
+		{filter: `userName ew "smith"`, matches: true},
+		{filter: `userName co ".sm"`, matches: true},
+		{filter: `name.givenName eq "Bob"`, matches: true},
+		{filter: `emails.value eq "bob@example.com"`, matches: true},
+		{filter: `emails eq "bob@example.com"`, matches: true},
+		{filter: `active eq true`, matches: true},
+		{filter: `active eq false`, matches: false},
+		{filter: `displayName pr`, matches: false},
+		{filter: `userName pr`, matches: true},
+		{filter: `userName eq "alice" or active eq true`, matches: true},
+		{filter: `userName eq "bob.smith" and active eq false`, matches: false},
+		{filter: `userName eq "with space" or userName eq "bob.smith"`, matches: true},
+		{filter: `userName eq "say \"hi\""`, matches: false},
+		{filter: `userName`, invalid: true},
+		{filter: `userName eq`, invalid: true},
+		{filter: `userName xx "bob"`, invalid: true},
+		{filter: `userName eq bob`, invalid: true},
+		{filter: `userName eq "bob`, invalid: true},
+		{filter: `userName eq "bob" and`, invalid: true},
+		{filter: `userName eq "bob" nor active eq true`, invalid: true},
+		{filter: `(userName eq "bob")`, invalid: true},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseSCIMFilter(test.filter)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParseSCIMFilter(%q): expected an error", test.filter)
+			} else if !isSCIMBadRequest(err, "invalidFilter") {
+				t.Errorf("ParseSCIMFilter(%q): expected an invalidFilter error, got %v", test.filter, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSCIMFilter(%q): unexpected error %s", test.filter, err)
+			continue
+		}
+		if matches := filter.Matches(user); matches != test.matches {
+			t.Errorf("ParseSCIMFilter(%q).Matches = %v, expected %v", test.filter, matches, test.matches)
+		}
+	}
+}
+
+func TestParseSCIMMemberPath(t *testing.T) {
+	member := SCIMMultiValue{Value: "1234", Display: "bob"}
+
+	tests := []struct {
+		path    string
+		matches bool
+		errType string
+	}{
+		{path: `members[value eq "1234"]`, matches: true},
+		{path: `Members[value eq "5678"]`, matches: false},
+		{path: `members[display eq "bob"]`, matches: true},
+		{path: `members[`, errType: "invalidPath"},
+		{path: `members[]`, errType: "invalidPath"},
+		{path: `members[ ]`, errType: "invalidPath"},
+		{path: `members[value eq "1234"`, errType: "invalidPath"},
+		{path: `members`, errType: "invalidPath"},
+		{path: `emails[value eq "1234"]`, errType: "invalidPath"},
+		{path: `members[value eq]`, errType: "invalidFilter"},
+	}
+
+	for _, test := range tests {
+		filter, err := parseSCIMMemberPath(test.path)
+		if test.errType != "" {
+			if !isSCIMBadRequest(err, test.errType) {
+				t.Errorf("parseSCIMMemberPath(%q): expected an %v error, got %v", test.path, test.errType, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSCIMMemberPath(%q): unexpected error %s", test.path, err)
+			continue
+		}
+		if matches := filter.Matches(member); matches != test.matches {
+			t.Errorf("parseSCIMMemberPath(%q).Matches = %v, expected %v", test.path, matches, test.matches)
+		}
+	}
+}
+
+func isSCIMBadRequest(err error, scimType string) bool {
+	var serr scimError
+	return errors.As(err, &serr) && serr.status == 400 && serr.scimType == scimType
+}
+
+func TestSCIMBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{header: "Bearer abc", token: "abc", ok: true},
+		{header: "bearer abc", token: "abc", ok: true},
+		{header: "BEARER abc", token: "abc", ok: true},
+		{header: "abc", ok: false},
+		{header: "Basic abc", ok: false},
+		{header: "Bearer ", ok: false},
+		{header: "Bearerabc", ok: false},
+		{header: "", ok: false},
+	}
+
+	for _, test := range tests {
+		token, ok := scimBearerToken(test.header)
+		if ok != test.ok || token != test.token {
+			t.Errorf("scimBearerToken(%q) = %q, %v, expected %q, %v", test.header, token, ok, test.token, test.ok)
+		}
+	}
+}
+
+func TestSCIMServerRequiresBearerScheme(t *testing.T) {
+	client := newTestClient(t)
+	server := NewSCIMServer(&client, "https://scim.example.com", "secret")
+
+	for _, header := range []string{"secret", "Token secret", "Bearer wrong", ""} {
+		request := httptest.NewRequest(http.MethodGet, "/Users", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: expected HTTP 401, got %d", header, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/*
+	Rotation of OIDC client secrets before they expire.
+	RotateClientSecret regenerates the secret, pushes the new value to a SecretSink, and then verifies that the new
+	secret works with a client_credentials token exchange. Each rotation produces an OIDCClientSecretRotation record
+	which can be written to an audit log. The secret values are never logged.
+
+	Note that regenerating a secret immediately invalidates the previous secret, so the sink should be the place
+	where the consumers of the client read their credentials from.
+	Storing the secret is retried a few times. If it still fails, the error is a *SecretStoreError holding the new
+	secret, use errors.As to recover it and store it by other means, otherwise the client has to be rotated again.
+*/
+
+const secretStoreAttempts = 3
+
+// Returns clients with a secret that expires within the given number of days, including already-expired secrets.
+// Clients without a secret expiry are not included.
+func (c Cx1Client) GetClientsWithExpiringSecrets(days int) ([]OIDCClient, error) {
+	c.logger.Debugf("Getting OIDC clients with secrets expiring within %d days", days)
+	var expiring []OIDCClient
+
+	clients, err := c.GetClients()
+	if err != nil {
+		return expiring, err
+	}
+
+	deadline := time.Now().AddDate(0, 0, days)
+	for _, client := range clients {
+		if client.OIDCClientRaw["attributes"] == nil { // brief representation does not include the expiry
+			fullClient, err := c.GetClientByID(client.ID)
+			if err != nil {
+				return expiring, fmt.Errorf("failed to get client %v: %s", client.String(), err)
+			}
+			client = fullClient
+		}
+
+		if client.ClientSecretExpiry == 0 {
+			continue
+		}
+		if expiry := client.GetSecretExpiry(); expiry.Before(deadline) {
+			c.logger.Tracef("Client %v secret expires %v", client.String(), expiry.Format(time.RFC3339))
+			expiring = append(expiring, client)
+		}
+	}
+
+	c.logger.Debugf("Found %d clients with secrets expiring before %v", len(expiring), deadline.Format(time.RFC3339))
+	return expiring, nil
+}
+
+// Returns the time the client secret expires, or the zero time if the secret does not expire
+func (client OIDCClient) GetSecretExpiry() time.Time {
+	if client.ClientSecretExpiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(client.ClientSecretExpiry), 0)
+}
+
+/*
+Regenerates the secret for the client, stores it in the sink, and verifies the new secret with a token request.
+The returned record describes the rotation and should be kept as an audit trail, an error is returned if any step failed.
+If the secret cannot be stored the error is a *SecretStoreError which carries the new secret.
+If the client is the one used by this Cx1Client, the Cx1Client will use the new secret from now on.
+*/
+func (c *Cx1Client) RotateClientSecret(client OIDCClient, sink SecretSink) (OIDCClientSecretRotation, error) {
+	record := OIDCClientSecretRotation{
+		ID:             client.ID,
+		ClientID:       client.ClientID,
+		RotatedAt:      time.Now().UTC(),
+		RotatedBy:      c.currentPrincipal(),
+		PreviousExpiry: client.GetSecretExpiry(),
+	}
+
+	fail := func(err error) (OIDCClientSecretRotation, error) {
+		record.Error = err.Error()
+		c.logger.Errorf("Secret rotation for client %v failed: %s", client.String(), err)
+		return record, err
+	}
+
+	// checked before regenerating, as the new secret would otherwise be lost
+	if sink == nil {
+		return fail(fmt.Errorf("no secret sink provided"))
+	}
+	record.Sink = sink.String()
+	c.logger.Infof("Rotating secret for client %v", client.String())
+
+	secret, err := c.RegenerateClientSecret(client)
+	if err != nil {
+		return fail(fmt.Errorf("failed to regenerate secret: %s", err))
+	}
+	if secret == "" {
+		return fail(fmt.Errorf("regenerated secret is empty"))
+	}
+
+	if c.auth.ClientID != "" && c.auth.ClientID == client.ClientID {
+		c.logger.Debugf("Rotated the secret of the current client %v, using the new secret from now on", client.String())
+		c.auth.ClientSecret = secret
+	}
+
+	if updated, err := c.GetClientByID(client.ID); err != nil {
+		c.logger.Warnf("Failed to get new secret expiry for client %v: %s", client.String(), err)
+	} else {
+		client = updated
+		record.NewExpiry = client.GetSecretExpiry()
+	}
+
+	if err = c.storeClientSecret(client, secret, sink); err != nil {
+		record.Error = fmt.Sprintf("secret was regenerated but could not be stored in %v: %s", sink.String(), err)
+		c.logger.Errorf("Secret rotation for client %v failed: %v", client.String(), record.Error)
+		return record, &SecretStoreError{ClientID: client.ClientID, Secret: secret, Err: err}
+	}
+	record.Stored = true
+
+	if err = c.VerifyClientSecret(client.ClientID, secret); err != nil {
+		return fail(fmt.Errorf("new secret failed verification: %s", err))
+	}
+	record.Verified = true
+
+	c.logger.Infof("Rotated secret for client %v, new secret expires %v", client.String(), record.NewExpiry.Format(time.RFC3339))
+	return record, nil
+}
+
+func (c Cx1Client) storeClientSecret(client OIDCClient, secret string, sink SecretSink) error {
+	var err error
+	for attempt := 1; attempt <= secretStoreAttempts; attempt++ {
+		if err = sink.StoreSecret(client, secret); err == nil {
+			return nil
+		}
+		c.logger.Warnf("Attempt %d of %d to store the secret for client %v in %v failed: %s", attempt, secretStoreAttempts, client.String(), sink.String(), err)
+		if attempt < secretStoreAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	return err
+}
+
+/*
+Rotates the secrets of all clients expiring within the given number of days. Each rotation record is written as a line
+of JSON to auditLog (if not nil). Rotation continues with the next client if one fails.
+The returned error joins the errors of the failed rotations, so any *SecretStoreError can be recovered with errors.As.
+*/
+func (c *Cx1Client) RotateExpiringClientSecrets(days int, sink SecretSink, auditLog io.Writer) ([]OIDCClientSecretRotation, error) {
+	var records []OIDCClientSecretRotation
+
+	clients, err := c.GetClientsWithExpiringSecrets(days)
+	if err != nil {
+		return records, err
+	}
+
+	var errs []error
+	for _, client := range clients {
+		record, err := c.RotateClientSecret(client, sink)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		records = append(records, record)
+
+		if auditLog != nil {
+			if err := json.NewEncoder(auditLog).Encode(record); err != nil {
+				c.logger.Errorf("Failed to write audit record for client %v rotation: %s", client.String(), err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return records, errors.Join(append([]error{fmt.Errorf("%d of %d client secret rotations failed", len(errs), len(clients))}, errs...)...)
+	}
+	return records, nil
+}
+
+// Checks that the client ID and secret can be used to get an access token, without changing the credentials used by this Cx1Client
+func (c Cx1Client) VerifyClientSecret(clientId, secret string) error {
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", clientId)
+	data.Set("client_secret", secret)
+
+	tokenUrl := fmt.Sprintf("%v/auth/realms/%v/protocol/openid-connect/token", c.iamUrl, c.tenant)
+	request, err := http.NewRequest(http.MethodPost, tokenUrl, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %v", err)
+	}
+	request.Header = http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"User-Agent":   {c.cx1UserAgent},
+	}
+
+	response, err := c.handleHTTPResponse(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var responseBody struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&responseBody); err != nil {
+		return fmt.Errorf("failed to parse response body: %v", err)
+	}
+	if responseBody.AccessToken == "" {
+		return fmt.Errorf("no access token returned for client %v", clientId)
+	}
+	return nil
+}
+
+func (e *SecretStoreError) Error() string {
+	return fmt.Sprintf("new secret for client %v could not be stored: %s", e.ClientID, e.Err)
+}
+
+func (e *SecretStoreError) Unwrap() error {
+	return e.Err
+}
+
+func (r OIDCClientSecretRotation) String() string {
+	if r.Error != "" {
+		return fmt.Sprintf("Client %v secret rotation at %v failed: %v", r.ClientID, r.RotatedAt.Format(time.RFC3339), r.Error)
+	}
+	return fmt.Sprintf("Client %v secret rotated at %v by %v, expires %v", r.ClientID, r.RotatedAt.Format(time.RFC3339), r.RotatedBy, r.NewExpiry.Format(time.RFC3339))
+}
+
+func (s FileSecretSink) StoreSecret(client OIDCClient, secret string) error {
+	return writeSecretFile(filepath.Join(s.Directory, client.ClientID+".secret"), []byte(secret))
+}
+
+func (s FileSecretSink) String() string {
+	return fmt.Sprintf("file sink %v", s.Directory)
+}
+
+func (s TemplateSecretSink) StoreSecret(client OIDCClient, secret string) error {
+	data := map[string]interface{}{
+		"ID":       client.ID,
+		"ClientID": client.ClientID,
+		"Secret":   secret,
+		"Expiry":   client.GetSecretExpiry(),
+	}
+
+	path, err := renderSecretTemplate("path", s.PathTemplate, data)
+	if err != nil {
+		return err
+	}
+	contents, err := renderSecretTemplate("contents", s.Template, data)
+	if err != nil {
+		return err
+	}
+	return writeSecretFile(path, []byte(contents))
+}
+
+func (s TemplateSecretSink) String() string {
+	return fmt.Sprintf("template sink %v", s.PathTemplate)
+}
+
+func renderSecretTemplate(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %v template: %s", name, err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %v template: %s", name, err)
+	}
+	return buf.String(), nil
+}
+
+// writes to a temporary file first so that readers never see a partially-written secret
+func writeSecretFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func NewLocalVaultSecretSink() *LocalVaultSecretSink {
+	return &LocalVaultSecretSink{secrets: make(map[string][]string)}
+}
+
+func (v *LocalVaultSecretSink) StoreSecret(client OIDCClient, secret string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.secrets[client.ClientID] = append(v.secrets[client.ClientID], secret)
+	return nil
+}
+
+// Returns the latest secret stored for the client
+func (v *LocalVaultSecretSink) GetSecret(clientId string) (string, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	versions := v.secrets[clientId]
+	if len(versions) == 0 {
+		return "", false
+	}
+	return versions[len(versions)-1], true
+}
+
+// Returns the number of secrets stored for the client
+func (v *LocalVaultSecretSink) GetVersionCount(clientId string) int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.secrets[clientId])
+}
+
+func (v *LocalVaultSecretSink) String() string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return fmt.Sprintf("local vault with %d clients", len(v.secrets))
+}
@@ -0,0 +1,378 @@
+package Cx1ClientGo
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+	Source context for results: SAST nodes and IaC results only carry a file name and line, so to show real code
+	(eg: in PR comments or exports) the scan's source archive is downloaded from the repostore (GetScanSourcesByID)
+	and indexed, and N lines of context around each node/result are attached as a SourceSnippet.
+
+	The ScanSourceCache keeps each archive in memory after the first download, and if a directory is given also
+	stores it on disk as <scanID>.zip so that later runs do not download it again (a cached file which cannot be
+	opened is replaced by a new download).
+	At most MaxArchives archives (DefaultSourceCacheArchives unless changed) are kept in memory, the least recently
+	loaded is dropped when another is added and read again from the directory or downloaded if needed later.
+	Archives on disk are kept until Remove is called for the scan.
+	File names in results are matched to archive entries ignoring leading slashes, and if there is no exact match,
+	by a unique path suffix (eg: when the archive has an extra top-level directory).
+*/
+
+const DefaultSourceCacheArchives = 10
+
+// Creates a source cache, dir can be empty to keep archives in memory only
+func NewScanSourceCache(client *Cx1Client, dir string) *ScanSourceCache {
+	return &ScanSourceCache{
+		MaxArchives: DefaultSourceCacheArchives,
+		client:      client,
+		dir:         dir,
+		archives:    make(map[string]*ScanSourceArchive),
+		scanLock:    make(map[string]*sync.Mutex),
+	}
+}
+
+// Returns the indexed source archive for a scan, from memory, from the cache directory, or downloaded
+func (sc *ScanSourceCache) GetArchive(scanID string) (*ScanSourceArchive, error) {
+	if err := validateSourceCacheScanID(scanID); err != nil {
+		return nil, err
+	}
+
+	sc.mutex.Lock()
+	if archive, ok := sc.archives[scanID]; ok {
+		sc.mutex.Unlock()
+		return archive, nil
+	}
+	lock, ok := sc.scanLock[scanID]
+	if !ok {
+		lock = &sync.Mutex{}
+		sc.scanLock[scanID] = lock
+	}
+	sc.mutex.Unlock()
+
+	// only requests for the same scan wait for the download
+	lock.Lock()
+	defer lock.Unlock()
+
+	sc.mutex.Lock()
+	archive, ok := sc.archives[scanID]
+	sc.mutex.Unlock()
+	if ok {
+		return archive, nil
+	}
+
+	archive, err := sc.loadArchive(scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mutex.Lock()
+	sc.archives[scanID] = archive
+	sc.loaded = append(sc.loaded, scanID)
+	for sc.MaxArchives > 0 && len(sc.loaded) > sc.MaxArchives {
+		sc.client.logger.Tracef("Dropping sources for scan %v from memory", sc.loaded[0])
+		delete(sc.archives, sc.loaded[0])
+		sc.loaded = sc.loaded[1:]
+	}
+	sc.mutex.Unlock()
+	return archive, nil
+}
+
+// reads the archive from the cache directory, downloading it again if it is missing or cannot be opened
+func (sc *ScanSourceCache) loadArchive(scanID string) (*ScanSourceArchive, error) {
+	cacheFile := ""
+	if sc.dir != "" {
+		cacheFile = filepath.Join(sc.dir, fmt.Sprintf("%v.zip", scanID))
+		data, err := os.ReadFile(cacheFile)
+		if err == nil {
+			archive, err := NewScanSourceArchive(scanID, data)
+			if err == nil {
+				sc.client.logger.Debugf("Using cached sources for scan %v from %v", scanID, cacheFile)
+				return archive, nil
+			}
+			sc.client.logger.Warnf("Cached sources for scan %v in %v are invalid and will be downloaded again: %s", scanID, cacheFile, err)
+			if err = os.Remove(cacheFile); err != nil {
+				sc.client.logger.Warnf("Failed to remove cached sources for scan %v: %s", scanID, err)
+			}
+		} else if !os.IsNotExist(err) {
+			sc.client.logger.Warnf("Failed to read cached sources for scan %v from %v: %s", scanID, cacheFile, err)
+		}
+	}
+
+	data, err := sc.client.GetScanSourcesByID(scanID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("failed to download sources for scan %v", scanID)
+	}
+
+	archive, err := NewScanSourceArchive(scanID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheFile != "" {
+		if err = writeFileAtomic(cacheFile, data); err != nil {
+			sc.client.logger.Warnf("Failed to store sources for scan %v in %v: %s", scanID, cacheFile, err)
+		}
+	}
+	return archive, nil
+}
+
+// writes to a temporary file in the same directory and renames it, so that a partial file is never left in place
+func writeFileAtomic(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// scan IDs are used as file names in the cache directory
+func validateSourceCacheScanID(scanID string) error {
+	if scanID == "" || scanID == "." || scanID == ".." || strings.ContainsAny(scanID, `/\`) {
+		return fmt.Errorf("invalid scan ID %q", scanID)
+	}
+	return nil
+}
+
+// Removes a scan's archive from memory and from the cache directory
+func (sc *ScanSourceCache) Remove(scanID string) {
+	if validateSourceCacheScanID(scanID) != nil {
+		return
+	}
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	delete(sc.archives, scanID)
+	if id := slices.Index(sc.loaded, scanID); id >= 0 {
+		sc.loaded = slices.Delete(sc.loaded, id, id+1)
+	}
+	if sc.dir != "" {
+		if err := os.Remove(filepath.Join(sc.dir, fmt.Sprintf("%v.zip", scanID))); err != nil && !os.IsNotExist(err) {
+			sc.client.logger.Warnf("Failed to remove cached sources for scan %v: %s", scanID, err)
+		}
+	}
+}
+
+// Attaches contextLines lines before and after each SAST node and IaC result in the set, from the scan's sources.
+// Nodes in files which are not in the archive are left without a snippet.
+func (sc *ScanSourceCache) AddSourceContext(scanID string, results *ScanResultSet, contextLines int) error {
+	archive, err := sc.GetArchive(scanID)
+	if err != nil {
+		return err
+	}
+
+	missing := 0
+	for rid := range results.SAST {
+		for nid := range results.SAST[rid].Data.Nodes {
+			node := &results.SAST[rid].Data.Nodes[nid]
+			snippet, err := archive.GetSnippet(node.FileName, node.Line, contextLines)
+			if err != nil {
+				missing++
+				sc.client.logger.Tracef("No source context for %v: %s", results.SAST[rid].SimilarityID, err)
+				continue
+			}
+			snippet.Column = node.Column
+			snippet.Length = node.Length
+			node.Snippet = snippet
+		}
+	}
+
+	for rid := range results.IAC {
+		data := &results.IAC[rid].Data
+		if data.Line <= 0 {
+			continue
+		}
+		snippet, err := archive.GetSnippet(data.FileName, uint64(data.Line), contextLines)
+		if err != nil {
+			missing++
+			sc.client.logger.Tracef("No source context for %v: %s", results.IAC[rid].SimilarityID, err)
+			continue
+		}
+		data.Snippet = snippet
+	}
+
+	if missing > 0 {
+		sc.client.logger.Debugf("Source context for scan %v: %d locations could not be found in the archive", scanID, missing)
+	}
+	return nil
+}
+
+// Indexes a scan source zip, eg: as returned by GetScanSourcesByID
+func NewScanSourceArchive(scanID string, data []byte) (*ScanSourceArchive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sources for scan %v: %s", scanID, err)
+	}
+
+	archive := &ScanSourceArchive{
+		ScanID: scanID,
+		reader: reader,
+		files:  make(map[string]*zip.File, len(reader.File)),
+		dirs:   map[string][]string{".": {}},
+		lines:  make(map[string][]string),
+	}
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := normalizeSourcePath(f.Name)
+		if !fs.ValidPath(name) || name == "." {
+			continue // eg: paths containing ..
+		}
+		if _, ok := archive.files[name]; !ok {
+			archive.addPath(name)
+		}
+		archive.files[name] = f
+	}
+	for _, entries := range archive.dirs {
+		sort.Strings(entries)
+	}
+	return archive, nil
+}
+
+// adds the file's name to the entries of its parent directories
+func (a *ScanSourceArchive) addPath(name string) {
+	for name != "." {
+		dir := path.Dir(name)
+		_, known := a.dirs[dir]
+		a.dirs[dir] = append(a.dirs[dir], path.Base(name))
+		if known {
+			return
+		}
+		name = dir
+	}
+}
+
+func normalizeSourcePath(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimPrefix(name, "./")
+	return strings.TrimLeft(name, "/")
+}
+
+// Returns the archive entry for a file name from a result, or nil
+func (a *ScanSourceArchive) getFile(fileName string) *zip.File {
+	name := normalizeSourcePath(fileName)
+	if f, ok := a.files[name]; ok {
+		return f
+	}
+
+	var match *zip.File
+	for path, f := range a.files {
+		if strings.HasSuffix(path, "/"+name) || strings.HasSuffix(name, "/"+path) {
+			if match != nil {
+				return nil // ambiguous
+			}
+			match = f
+		}
+	}
+	return match
+}
+
+// Returns the lines of a file in the archive
+func (a *ScanSourceArchive) GetLines(fileName string) ([]string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	f := a.getFile(fileName)
+	if f == nil {
+		return nil, fmt.Errorf("file %v not found in sources for scan %v", fileName, a.ScanID)
+	}
+	if lines, ok := a.lines[f.Name]; ok {
+		return lines, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v from sources for scan %v: %s", f.Name, a.ScanID, err)
+	}
+	defer rc.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %v from sources for scan %v: %s", f.Name, a.ScanID, err)
+	}
+
+	a.lines[f.Name] = lines
+	return lines, nil
+}
+
+// Returns the line (1-based) with contextLines lines before and after it
+func (a *ScanSourceArchive) GetSnippet(fileName string, line uint64, contextLines int) (*SourceSnippet, error) {
+	lines, err := a.GetLines(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if line == 0 || line > uint64(len(lines)) {
+		return nil, fmt.Errorf("line %d is outside of %v (%d lines)", line, fileName, len(lines))
+	}
+	if contextLines < 0 {
+		contextLines = 0
+	}
+
+	start := uint64(1)
+	if line > uint64(contextLines) {
+		start = line - uint64(contextLines)
+	}
+	end := line + uint64(contextLines)
+	if end > uint64(len(lines)) {
+		end = uint64(len(lines))
+	}
+
+	return &SourceSnippet{
+		FileName:  fileName,
+		Line:      line,
+		StartLine: start,
+		Lines:     append([]string{}, lines[start-1:end]...), // a copy, the archive keeps the file's lines
+	}, nil
+}
+
+// Returns the snippet with line numbers, the result line is marked with '>'
+func (s SourceSnippet) String() string {
+	var b strings.Builder
+	width := len(fmt.Sprintf("%d", s.StartLine+uint64(len(s.Lines))))
+	for id, text := range s.Lines {
+		number := s.StartLine + uint64(id)
+		marker := " "
+		if number == s.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%v%*d | %v\n", marker, width, number, text)
+	}
+	return b.String()
+}
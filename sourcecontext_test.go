@@ -0,0 +1,250 @@
+package Cx1ClientGo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func newTestSourceArchive(t *testing.T, files map[string]string) *ScanSourceArchive {
+	archive, err := NewScanSourceArchive("test", newTestSourceZip(t, files))
+	if err != nil {
+		t.Fatalf("NewScanSourceArchive: %s", err)
+	}
+	return archive
+}
+
+func TestNewScanSourceArchive(t *testing.T) {
+	if _, err := NewScanSourceArchive("test", []byte("not a zip")); err == nil {
+		t.Errorf("expected an error for an invalid archive")
+	}
+
+	archive := newTestSourceArchive(t, map[string]string{
+		"./src/Main.java":        "class Main {}",
+		"src\\util\\Helper.java": "class Helper {}",
+		"/README.md":             "# readme",
+		"src/../../etc/passwd":   "skipped",
+	})
+
+	tests := []struct {
+		dir     string
+		entries []string
+	}{
+		{".", []string{"README.md", "src"}},
+		{"src", []string{"Main.java", "util"}},
+		{"src/util", []string{"Helper.java"}},
+	}
+	for _, test := range tests {
+		if entries := archive.dirs[test.dir]; !slices.Equal(entries, test.entries) {
+			t.Errorf("directory %v has entries %v, expected %v", test.dir, entries, test.entries)
+		}
+	}
+	if len(archive.files) != 3 {
+		t.Errorf("expected 3 files, got %d", len(archive.files))
+	}
+}
+
+func TestScanSourceArchiveGetFile(t *testing.T) {
+	archive := newTestSourceArchive(t, map[string]string{
+		"repo/src/Main.java":     "main",
+		"repo/src/a/Util.java":   "a",
+		"repo/src/b/Util.java":   "b",
+		"repo/web/index.html":    "index",
+		"repo/other/index2.html": "index2",
+	})
+
+	tests := []struct {
+		fileName string
+		found    string // archive entry, empty if not found
+	}{
+		{"repo/src/Main.java", "repo/src/Main.java"},
+		{"/repo/src/Main.java", "repo/src/Main.java"},
+		{"src/Main.java", "repo/src/Main.java"},               // archive has an extra top-level directory
+		{"/build/repo/web/index.html", "repo/web/index.html"}, // result has an extra prefix
+		{"Util.java", ""}, // ambiguous
+		{"a/Util.java", "repo/src/a/Util.java"},
+		{"index.html", "repo/web/index.html"},
+		{"ndex.html", ""}, // suffixes match whole path segments only
+		{"Missing.java", ""},
+	}
+
+	for _, test := range tests {
+		f := archive.getFile(test.fileName)
+		found := ""
+		if f != nil {
+			found = f.Name
+		}
+		if found != test.found {
+			t.Errorf("getFile(%v) = %q, expected %q", test.fileName, found, test.found)
+		}
+	}
+}
+
+func TestScanSourceArchiveGetSnippet(t *testing.T) {
+	archive := newTestSourceArchive(t, map[string]string{
+		"Main.java": "line1\r\nline2\nline3\nline4\nline5",
+	})
+
+	tests := []struct {
+		name    string
+		line    uint64
+		context int
+		start   uint64
+		lines   []string
+		invalid bool
+	}{
+		{name: "line 0", line: 0, context: 1, invalid: true},
+		{name: "past the end", line: 6, context: 1, invalid: true},
+		{name: "first line", line: 1, context: 1, start: 1, lines: []string{"line1", "line2"}},
+		{name: "middle", line: 3, context: 1, start: 2, lines: []string{"line2", "line3", "line4"}},
+		{name: "last line", line: 5, context: 2, start: 3, lines: []string{"line3", "line4", "line5"}},
+		{name: "context larger than the file", line: 2, context: 100, start: 1, lines: []string{"line1", "line2", "line3", "line4", "line5"}},
+		{name: "negative context", line: 4, context: -1, start: 4, lines: []string{"line4"}},
+	}
+
+	for _, test := range tests {
+		snippet, err := archive.GetSnippet("Main.java", test.line, test.context)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", test.name, err)
+			continue
+		}
+		if snippet.StartLine != test.start || !slices.Equal(snippet.Lines, test.lines) {
+			t.Errorf("%v: got lines %v from %d, expected %v from %d", test.name, snippet.Lines, snippet.StartLine, test.lines, test.start)
+		}
+	}
+
+	// changing a snippet must not change the cached lines of the file
+	snippet, _ := archive.GetSnippet("Main.java", 1, 0)
+	snippet.Lines[0] = "changed"
+	if lines, _ := archive.GetLines("Main.java"); lines[0] != "line1" {
+		t.Errorf("snippet shares its lines with the archive, line 1 is now %q", lines[0])
+	}
+}
+
+func TestValidateSourceCacheScanID(t *testing.T) {
+	tests := []struct {
+		scanID string
+		valid  bool
+	}{
+		{"0b7e4a1c-7b8f-4d7a-9a7e-0a1b2c3d4e5f", true},
+		{"scan.1", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../scan", false},
+		{"a/b", false},
+		{`a\b`, false},
+	}
+
+	for _, test := range tests {
+		if err := validateSourceCacheScanID(test.scanID); (err == nil) != test.valid {
+			t.Errorf("validateSourceCacheScanID(%q): got error %v, expected valid = %v", test.scanID, err, test.valid)
+		}
+	}
+}
+
+func TestScanSourceCacheLoadArchive(t *testing.T) {
+	data := newTestSourceZip(t, map[string]string{"Main.java": "class Main {}"})
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/repostore/code/scan1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		downloads++
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := Cx1Client{
+		httpClient: server.Client(),
+		baseUrl:    server.URL,
+		logger:     testLogger{t},
+		auth:       Cx1ClientAuth{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+	}
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "scan1.zip")
+
+	tests := []struct {
+		name      string
+		cached    []byte // contents of the cache file before loading, nil for no file
+		downloads int
+	}{
+		{"not cached", nil, 1},
+		{"cached", data, 0},
+		{"corrupt cache file", []byte("corrupt"), 1},
+	}
+
+	for _, test := range tests {
+		os.Remove(cacheFile)
+		if test.cached != nil {
+			if err := os.WriteFile(cacheFile, test.cached, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		downloads = 0
+
+		cache := NewScanSourceCache(&client, dir)
+		archive, err := cache.loadArchive("scan1")
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", test.name, err)
+			continue
+		}
+		if _, err = archive.GetLines("Main.java"); err != nil {
+			t.Errorf("%v: %s", test.name, err)
+		}
+		if downloads != test.downloads {
+			t.Errorf("%v: expected %d downloads, got %d", test.name, test.downloads, downloads)
+		}
+		if cached, err := os.ReadFile(cacheFile); err != nil || !slices.Equal(cached, data) {
+			t.Errorf("%v: expected the cache file to hold the downloaded archive", test.name)
+		}
+	}
+}
+
+func TestScanSourceCacheMaxArchives(t *testing.T) {
+	data := newTestSourceZip(t, map[string]string{"Main.java": "class Main {}"})
+	downloads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads[filepath.Base(r.URL.Path)]++
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := Cx1Client{
+		httpClient: server.Client(),
+		baseUrl:    server.URL,
+		logger:     testLogger{t},
+		auth:       Cx1ClientAuth{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+	}
+	cache := NewScanSourceCache(&client, "")
+	cache.MaxArchives = 2
+
+	for _, scanID := range []string{"scan1", "scan2", "scan2", "scan3", "scan1"} {
+		if _, err := cache.GetArchive(scanID); err != nil {
+			t.Fatalf("GetArchive(%v): %s", scanID, err)
+		}
+	}
+	if len(cache.archives) != 2 || !slices.Equal(cache.loaded, []string{"scan3", "scan1"}) {
+		t.Errorf("expected scan3 and scan1 in memory, got %v", cache.loaded)
+	}
+	if downloads["scan1"] != 2 || downloads["scan2"] != 1 || downloads["scan3"] != 1 {
+		t.Errorf("unexpected downloads %v", downloads)
+	}
+
+	cache.Remove("scan3")
+	if _, ok := cache.archives["scan3"]; ok || !slices.Equal(cache.loaded, []string{"scan1"}) {
+		t.Errorf("expected only scan1 in memory after Remove, got %v", cache.loaded)
+	}
+}
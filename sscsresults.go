@@ -0,0 +1,154 @@
+package Cx1ClientGo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+	Supply-chain security (SSCS) results: secret detection (2ms) and OpenSSF scorecard findings.
+	These are returned by the /results endpoint with types sscs-secret-detection and sscs-scorecard and are
+	decoded into ScanResultSet.SecretDetection and ScanResultSet.Scorecard.
+	The endpoint cannot filter by result type, so each of the Get*ByID functions below downloads all results of the
+	scan (SAST, SCA, IaC etc.) and keeps only the SSCS ones. Use GetScanSSCSResultsByID to get both types in one
+	pass, or GetAllScanResultsFiltered if other result types are also needed.
+
+	Triage (state changes and comments) uses the micro-engines predicates endpoints, which have the same
+	structure as SAST/IAC predicates plus a scannerType. Severity changes are not supported for these results.
+*/
+
+const (
+	SecretValidityValid   = "Valid"
+	SecretValidityInvalid = "Invalid"
+	SecretValidityUnknown = "Unknown"
+
+	SSCSScannerSecretDetection = "secret-detection"
+	SSCSScannerScorecard       = "scorecard"
+
+	sscsPredicatesWritePath = "/micro-engines/write/predicates"
+	sscsPredicatesReadPath  = "/micro-engines/read/predicates"
+)
+
+// Returns all secret detection results for a scan, this downloads all results of the scan
+func (c Cx1Client) GetScanSecretDetectionResultsByID(scanID string) ([]ScanSecretDetectionResult, error) {
+	c.logger.Debugf("Get all Cx1 secret detection results for scan %v", scanID)
+	secrets, _, err := c.GetScanSSCSResultsByID(scanID)
+	return secrets, err
+}
+
+// Returns all scorecard results for a scan, this downloads all results of the scan
+func (c Cx1Client) GetScanScorecardResultsByID(scanID string) ([]ScanScorecardResult, error) {
+	c.logger.Debugf("Get all Cx1 scorecard results for scan %v", scanID)
+	_, scorecard, err := c.GetScanSSCSResultsByID(scanID)
+	return scorecard, err
+}
+
+// Returns the secret detection and scorecard results for a scan with a single pass over all results of the scan
+func (c Cx1Client) GetScanSSCSResultsByID(scanID string) ([]ScanSecretDetectionResult, []ScanScorecardResult, error) {
+	_, results, err := c.GetAllScanResultsFiltered(ScanResultsFilter{
+		BaseFilter: BaseFilter{Limit: c.pagination.Results},
+		ScanID:     scanID,
+	})
+	if err != nil {
+		return results.SecretDetection, results.Scorecard, err
+	}
+	c.logger.Debugf("Scan %v has %d secret detection and %d scorecard results out of %d", scanID, len(results.SecretDetection), len(results.Scorecard), results.Count())
+	return results.SecretDetection, results.Scorecard, nil
+}
+
+func (c Cx1Client) AddSSCSResultsPredicates(predicates []SSCSResultsPredicates) error {
+	c.logger.Debugf("Adding %d SSCS results predicates", len(predicates))
+
+	for _, p := range predicates {
+		if p.ScannerType != SSCSScannerSecretDetection && p.ScannerType != SSCSScannerScorecard {
+			return fmt.Errorf("predicate for %v has invalid scanner type '%v', must be %v or %v", p.SimilarityID, p.ScannerType, SSCSScannerSecretDetection, SSCSScannerScorecard)
+		}
+		if p.Severity != "" {
+			return fmt.Errorf("predicate for %v sets severity, which is not supported for %v results", p.SimilarityID, p.ScannerType)
+		}
+	}
+
+	jsonBody, err := json.Marshal(predicates)
+	if err != nil {
+		c.logger.Tracef("Failed to add SSCS results predicates: %s", err)
+		return err
+	}
+
+	_, err = c.sendRequest(http.MethodPost, sscsPredicatesWritePath, bytes.NewReader(jsonBody), nil)
+	return err
+}
+
+// Returns the predicate history for a secret detection or scorecard result in a project
+func (c Cx1Client) GetSSCSResultsPredicatesByID(SimilarityID, ProjectID, scannerType string) ([]SSCSResultsPredicates, error) {
+	c.logger.Debugf("Fetching %v results predicates for project %v similarityId %v", scannerType, ProjectID, SimilarityID)
+
+	var Predicates struct {
+		PredicateHistoryPerProject []struct {
+			ProjectID    string
+			SimilarityID string `json:"similarityId"`
+			Predicates   []SSCSResultsPredicates
+			TotalCount   uint
+		}
+
+		TotalCount uint
+	}
+
+	params := url.Values{
+		"project-ids":  {ProjectID},
+		"scanner-type": {scannerType},
+	}
+	response, err := c.sendRequest(http.MethodGet, fmt.Sprintf("%v/%v?%v", sscsPredicatesReadPath, SimilarityID, params.Encode()), nil, nil)
+	if err != nil {
+		return []SSCSResultsPredicates{}, err
+	}
+
+	err = json.Unmarshal(response, &Predicates)
+	if err != nil {
+		return []SSCSResultsPredicates{}, err
+	}
+
+	if Predicates.TotalCount == 0 || len(Predicates.PredicateHistoryPerProject) == 0 {
+		return []SSCSResultsPredicates{}, nil
+	}
+
+	return Predicates.PredicateHistoryPerProject[0].Predicates, err
+}
+
+func (r ScanSecretDetectionResult) CreateResultsPredicate(projectId, scanId string) SSCSResultsPredicates {
+	return SSCSResultsPredicates{
+		ResultsPredicatesBase: ResultsPredicatesBase{
+			SimilarityID: r.SimilarityID,
+			ProjectID:    projectId,
+			ScanID:       scanId,
+		},
+		ScannerType: SSCSScannerSecretDetection,
+	}
+}
+
+func (r ScanScorecardResult) CreateResultsPredicate(projectId, scanId string) SSCSResultsPredicates {
+	return SSCSResultsPredicates{
+		ResultsPredicatesBase: ResultsPredicatesBase{
+			SimilarityID: r.SimilarityID,
+			ProjectID:    projectId,
+			ScanID:       scanId,
+		},
+		ScannerType: SSCSScannerScorecard,
+	}
+}
+
+// Returns true if the platform validated the secret as live
+func (r ScanSecretDetectionResult) IsValid() bool {
+	return strings.EqualFold(r.Data.Validity, SecretValidityValid)
+}
+
+func (r ScanSecretDetectionResult) String() string {
+	return fmt.Sprintf("%v (%v) - %v secret in file %v:%d", r.Data.RuleName, r.SimilarityID, r.Data.Validity, r.Data.FileName, r.Data.Line)
+}
+
+func (r ScanScorecardResult) String() string {
+	return fmt.Sprintf("%v (%v) - %v", r.Data.RuleName, r.SimilarityID, r.Severity)
+}
@@ -0,0 +1,190 @@
+package Cx1ClientGo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+	Opt-in OpenTelemetry instrumentation for the Cx1Client.
+	When enabled via EnableTelemetry, every HTTP call gets a client span named "<method> <route>" where the route is
+	the URL path with IDs replaced by {id} and the tenant by {tenant} (eg: GET /api/projects/{id}), with the status
+	code and number of retries as attributes, and the requests/duration/errors/retries metrics are recorded.
+	Composite operations (GetAllProjectsFiltered paging, ScanPollingWithTimeout, AuditCreateSessionByID) create a
+	parent span so that their HTTP calls are grouped.
+
+	The client does not take a context, so the parent span is carried by the Cx1Client value itself: operations work
+	on a copy of the client with the span set. Use WithTelemetryContext to attach calls to a span of the caller.
+	Without EnableTelemetry nothing is recorded and no OpenTelemetry calls are made.
+*/
+
+const telemetryInstrumentationName = "github.com/cxpsemea/Cx1ClientGo"
+
+type clientTelemetry struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	requests   metric.Int64Counter
+	errors     metric.Int64Counter
+	retries    metric.Int64Counter
+	duration   metric.Float64Histogram
+}
+
+var telemetryIDSegment = regexp.MustCompile(`^(-?[0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// Enables tracing and metrics, providers which are not set in the options are taken from the otel globals
+func (c *Cx1Client) EnableTelemetry(options TelemetryOptions) error {
+	if options.TracerProvider == nil {
+		options.TracerProvider = otel.GetTracerProvider()
+	}
+	if options.MeterProvider == nil {
+		options.MeterProvider = otel.GetMeterProvider()
+	}
+	if options.Propagator == nil {
+		options.Propagator = otel.GetTextMapPropagator()
+	}
+
+	meter := options.MeterProvider.Meter(telemetryInstrumentationName)
+	t := &clientTelemetry{
+		tracer:     options.TracerProvider.Tracer(telemetryInstrumentationName),
+		propagator: options.Propagator,
+	}
+
+	var err error
+	if t.requests, err = meter.Int64Counter("cx1.client.requests", metric.WithDescription("Number of HTTP requests sent"), metric.WithUnit("{request}")); err != nil {
+		return fmt.Errorf("failed to create requests counter: %s", err)
+	}
+	if t.errors, err = meter.Int64Counter("cx1.client.errors", metric.WithDescription("Number of HTTP requests which failed or returned an error status"), metric.WithUnit("{request}")); err != nil {
+		return fmt.Errorf("failed to create errors counter: %s", err)
+	}
+	if t.retries, err = meter.Int64Counter("cx1.client.retries", metric.WithDescription("Number of HTTP request retries"), metric.WithUnit("{retry}")); err != nil {
+		return fmt.Errorf("failed to create retries counter: %s", err)
+	}
+	if t.duration, err = meter.Float64Histogram("cx1.client.request.duration", metric.WithDescription("Duration of HTTP requests including retries"), metric.WithUnit("s")); err != nil {
+		return fmt.Errorf("failed to create duration histogram: %s", err)
+	}
+
+	c.logger.Debugf("Enabling Cx1Client telemetry")
+	c.telemetry = t
+	return nil
+}
+
+func (c *Cx1Client) DisableTelemetry() {
+	c.logger.Debugf("Disabling Cx1Client telemetry")
+	c.telemetry = nil
+}
+
+func (c Cx1Client) IsTelemetryEnabled() bool {
+	return c.telemetry != nil
+}
+
+// Returns a copy of the client whose calls are children of the span in ctx
+func (c Cx1Client) WithTelemetryContext(ctx context.Context) Cx1Client {
+	c.telemetryContext = ctx
+	return c
+}
+
+func (c Cx1Client) getTelemetryContext() context.Context {
+	if c.telemetryContext == nil {
+		return context.Background()
+	}
+	return c.telemetryContext
+}
+
+// starts a span for a composite operation and returns a copy of the client carrying it, end it with endOperation
+func (c Cx1Client) startOperation(name string, attributes ...attribute.KeyValue) (Cx1Client, trace.Span) {
+	if c.telemetry == nil {
+		return c, trace.SpanFromContext(context.Background()) // no-op span
+	}
+	ctx, span := c.telemetry.tracer.Start(c.getTelemetryContext(), name, trace.WithAttributes(attributes...))
+	c.telemetryContext = ctx
+	return c, span
+}
+
+func endOperation(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// starts the client span for an HTTP request and propagates it in the request headers
+func (c Cx1Client) startRequestSpan(request *http.Request) trace.Span {
+	if c.telemetry == nil {
+		return nil
+	}
+	route := c.telemetryRoute(request)
+	ctx, span := c.telemetry.tracer.Start(c.getTelemetryContext(), fmt.Sprintf("%v %v", request.Method, route),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", request.Method),
+			attribute.String("url.template", route),
+			attribute.String("server.address", request.URL.Hostname()),
+		))
+	c.telemetry.propagator.Inject(ctx, propagation.HeaderCarrier(request.Header))
+	return span
+}
+
+// ends the request span and records the request metrics
+func (c Cx1Client) endRequestSpan(span trace.Span, request *http.Request, response *http.Response, retries int, err error, start time.Time) {
+	if c.telemetry == nil || span == nil {
+		return
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("http.request.method", request.Method),
+		attribute.String("url.template", c.telemetryRoute(request)),
+	}
+	if response != nil {
+		attributes = append(attributes, attribute.Int("http.response.status_code", response.StatusCode))
+	}
+	span.SetAttributes(append(attributes, attribute.Int("http.request.resend_count", retries))...)
+
+	failed := err != nil || response == nil || response.StatusCode >= 400
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if failed {
+		span.SetStatus(codes.Error, "HTTP request failed")
+	}
+	span.End()
+
+	ctx := c.getTelemetryContext()
+	set := metric.WithAttributes(attributes...)
+	c.telemetry.requests.Add(ctx, 1, set)
+	c.telemetry.duration.Record(ctx, time.Since(start).Seconds(), set)
+	if failed {
+		c.telemetry.errors.Add(ctx, 1, set)
+	}
+	if retries > 0 {
+		c.telemetry.retries.Add(ctx, int64(retries), set)
+	}
+}
+
+// returns the URL path with IDs replaced by {id} and the tenant name by {tenant}, to keep span names and metric
+// attributes low-cardinality
+func (c Cx1Client) telemetryRoute(request *http.Request) string {
+	segments := strings.Split(request.URL.Path, "/")
+	for id, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		if c.tenant != "" && segment == c.tenant {
+			segments[id] = "{tenant}"
+		} else if telemetryIDSegment.MatchString(segment) {
+			segments[id] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,44 @@
+package Cx1ClientGo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTelemetryRoute(t *testing.T) {
+	c := Cx1Client{tenant: "acme"}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://eu.ast.checkmarx.net/api/projects", expected: "/api/projects"},
+		{url: "https://eu.ast.checkmarx.net/api/projects/", expected: "/api/projects/"},
+		{url: "https://eu.ast.checkmarx.net/api/projects/4f8a6a3e-52a8-4f8e-9ae1-2b06f1f4c1d7", expected: "/api/projects/{id}"},
+		{url: "https://eu.ast.checkmarx.net/api/projects/4F8A6A3E-52A8-4F8E-9AE1-2B06F1F4C1D7/branches?limit=10", expected: "/api/projects/{id}/branches"},
+		{url: "https://eu.ast.checkmarx.net/api/queries/12345678/source", expected: "/api/queries/{id}/source"},
+		{url: "https://eu.ast.checkmarx.net/api/queries/-123", expected: "/api/queries/{id}"},
+		{url: "https://eu.ast.checkmarx.net/api/cx-audit/queries/0123456789abcdef01", expected: "/api/cx-audit/queries/{id}"},
+		{url: "https://eu.iam.checkmarx.net/auth/admin/realms/acme/groups/4f8a6a3e-52a8-4f8e-9ae1-2b06f1f4c1d7/members", expected: "/auth/admin/realms/{tenant}/groups/{id}/members"},
+		{url: "https://eu.iam.checkmarx.net/auth/realms/acme/protocol/openid-connect/token", expected: "/auth/realms/{tenant}/protocol/openid-connect/token"},
+		{url: "https://eu.iam.checkmarx.net/auth/realms/acme-dev/users", expected: "/auth/realms/acme-dev/users"},
+		{url: "https://eu.ast.checkmarx.net/api/presets/v2", expected: "/api/presets/v2"},
+		{url: "https://eu.ast.checkmarx.net/api/scans/deadbeef", expected: "/api/scans/deadbeef"},
+	}
+
+	for _, test := range tests {
+		request, err := http.NewRequest(http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatalf("failed to create request for %v: %s", test.url, err)
+		}
+		if route := c.telemetryRoute(request); route != test.expected {
+			t.Errorf("telemetryRoute(%v) = %v, expected %v", test.url, route, test.expected)
+		}
+	}
+
+	// without a tenant only IDs are replaced
+	request, _ := http.NewRequest(http.MethodGet, "https://eu.iam.checkmarx.net/auth/realms/acme/users/42", nil)
+	if route := (Cx1Client{}).telemetryRoute(request); route != "/auth/realms/acme/users/{id}" {
+		t.Errorf("telemetryRoute without tenant = %v, expected /auth/realms/acme/users/{id}", route)
+	}
+}
@@ -1,10 +1,18 @@
 package Cx1ClientGo
 
 import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Logger interface {
@@ -40,6 +48,10 @@ type Cx1Client struct {
 	tenantOwner  *TenantOwner
 	maxRetries   int
 	retryDelay   int
+	cache        *clientCache // nil unless EnableCache is called
+
+	telemetry        *clientTelemetry // nil unless EnableTelemetry is called
+	telemetryContext context.Context  // parent span for requests sent by this copy of the client
 }
 
 type Cx1ClientAuth struct {
@@ -86,6 +98,81 @@ type ASTLicense struct {
 	}
 }
 
+type LicenseEngineUsage struct {
+	Engine    string   `json:"engine"`  // engine name as used in scans
+	License   string   `json:"license"` // engine name in the license, empty if the engine is not mapped
+	Allowed   bool     `json:"allowed"`
+	ScanCount int      `json:"scanCount"`
+	Projects  []string `json:"projects"`
+}
+
+type LicenseReport struct {
+	Tenant           string               `json:"tenant"`
+	PackageName      string               `json:"packageName"`
+	GeneratedAt      time.Time            `json:"generatedAt"`
+	Since            time.Time            `json:"since"`
+	EnabledUsers     int                  `json:"enabledUsers"`
+	Users            LicenseUsage         `json:"users"`           // active users vs UsersCount
+	ConcurrentScans  LicenseUsage         `json:"concurrentScans"` // peak concurrent scans including queued time vs MaxConcurrentScans, an upper bound so not warned on
+	PeakConcurrentAt time.Time            `json:"peakConcurrentAt"`
+	RunningScans     uint64               `json:"runningScans"`
+	QueuedScans      uint64               `json:"queuedScans"`
+	Engines          []LicenseEngineUsage `json:"engines"`
+	UnusedEngines    []string             `json:"unusedEngines"`  // licensed engines not used in the period
+	ProjectEngines   map[string][]string  `json:"projectEngines"` // project name -> engines used
+	Warnings         []string             `json:"warnings"`
+}
+
+type LicenseReportOptions struct {
+	Since         time.Time // start of the scan history to check, defaults to 30 days ago
+	ActiveDays    int       // users who logged in within this many days are active, defaults to 30
+	WarnThreshold float64   // fraction of a limit at which to warn, defaults to 0.8
+}
+
+type LicenseUsage struct {
+	Name   string  `json:"name"`
+	Used   int     `json:"used"`
+	Limit  int     `json:"limit"` // 0 if the license does not set a limit
+	Ratio  float64 `json:"ratio"`
+	Status string  `json:"status"` // one of the LicenseUsage* constants
+}
+
+// Serves tenant metrics in the Prometheus exposition format, create with NewMetricsHandler
+type MetricsHandler struct {
+	client         *Cx1Client
+	options        MetricsHandlerOptions
+	snapshot       []byte
+	projects       map[string]*metricsProjectResults // project ID -> results of the last completed scan
+	applications   map[string][]string               // application name -> project IDs
+	license        []metricsFamily
+	finishedScans  map[string]time.Time // scans already in the duration histogram
+	durationCounts []uint64
+	durationSum    float64
+	durationTotal  uint64
+	errors         uint64
+	lastCollection time.Time
+	resultsSince   time.Time
+	durationsSince time.Time
+	lastLicense    time.Time
+	mutex          sync.Mutex
+	ctx            context.Context
+	cancel         context.CancelFunc
+	done           chan struct{}
+}
+
+type MetricsHandlerOptions struct {
+	Interval        time.Duration // how often metrics are collected, default 5 minutes
+	LicenseInterval time.Duration // how often license usage is collected, default 1 hour
+	Prefix          string        // metric name prefix, default cx1
+}
+
+// Providers used by EnableTelemetry, unset providers are taken from the otel globals
+type TelemetryOptions struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Propagator     propagation.TextMapPropagator
+}
+
 type TenantOwner struct {
 	Username  string
 	Firstname string
@@ -94,6 +181,14 @@ type TenantOwner struct {
 	UserID    string `json:"id"`
 }
 
+type ClientCacheStats struct {
+	Entries     uint64
+	Hits        uint64
+	Misses      uint64
+	Revalidated uint64 // expired entries confirmed unchanged by the server via ETag
+	Invalidated uint64
+}
+
 type ClientVars struct {
 	MigrationPollingMaxSeconds                int
 	MigrationPollingDelaySeconds              int
@@ -105,6 +200,8 @@ type ClientVars struct {
 	AuditCompilePollingDelaySeconds           int
 	AuditLanguagePollingMaxSeconds            int
 	AuditLanguagePollingDelaySeconds          int
+	AuditSessionHeartbeatSeconds              int // used by the AuditSessionManager
+	AuditSessionIdleMaxSeconds                int // used by the AuditSessionManager
 	ReportPollingMaxSeconds                   int
 	ReportPollingDelaySeconds                 int
 	ScanPollingMaxSeconds                     int
@@ -158,6 +255,61 @@ type AccessAssignedRole struct {
 	Name string `json:"name"`
 }
 
+type AccessReview struct {
+	Tenant      string                 `json:"tenant"`
+	GeneratedAt time.Time              `json:"generatedAt"`
+	DormantDays int                    `json:"dormantDays"`
+	Users       []AccessReviewUser     `json:"users"`
+	Resources   []AccessReviewResource `json:"resources"`
+}
+
+type AccessReviewOptions struct {
+	DormantDays  int      // users who have not logged in for this many days are flagged as dormant, default 90
+	AdminRoles   []string // roles that make the holder a tenant admin, default ast-admin and iam-admin
+	IgnoredRoles []string // direct roles that are expected on every user and are not flagged, default offline_access and uma_authorization
+}
+
+type AccessReviewUser struct {
+	UserID         string                    `json:"id"`
+	UserName       string                    `json:"username"`
+	Email          string                    `json:"email"`
+	Enabled        bool                      `json:"enabled"`
+	ServiceAccount bool                      `json:"serviceAccount"`
+	ClientID       string                    `json:"clientId,omitempty"` // OIDC client for service accounts
+	LastLogin      time.Time                 `json:"lastLogin"`
+	Groups         []string                  `json:"groups"`
+	DirectRoles    []string                  `json:"directRoles"`
+	TenantRoles    []string                  `json:"tenantRoles"` // effective tenant-wide roles, including composites and tenant assignments
+	Entitlements   []AccessReviewEntitlement `json:"entitlements"`
+	Dormant        bool                      `json:"dormant"`
+	DirectGrants   bool                      `json:"directGrants"`
+	TenantAdmin    bool                      `json:"tenantAdmin"`
+	TenantOwner    bool                      `json:"tenantOwner"`
+	Flags          []string                  `json:"flags"`
+}
+
+type AccessReviewEntitlement struct {
+	ResourceType string   `json:"resourceType"` // tenant, group, application or project
+	ResourceID   string   `json:"resourceId"`
+	ResourceName string   `json:"resourceName"`
+	Roles        []string `json:"roles"`
+	Via          string   `json:"via"`
+}
+
+type AccessReviewResource struct {
+	ResourceType string                   `json:"resourceType"`
+	ResourceID   string                   `json:"resourceId"`
+	ResourceName string                   `json:"resourceName"`
+	Entries      []AccessReviewGrantEntry `json:"entries"`
+}
+
+type AccessReviewGrantEntry struct {
+	UserID   string   `json:"userId"`
+	UserName string   `json:"username"`
+	Roles    []string `json:"roles"`
+	Via      string   `json:"via"`
+}
+
 type AccessibleResource struct {
 	ResourceID   string   `json:"resourceId"`
 	ResourceType string   `json:"resourceType"`
@@ -185,6 +337,36 @@ type AnalyticsFilter struct {
 	EndDate         *AnalyticsTime `json:"endDate,omitempty"`
 }
 
+// Names to resolve into an AnalyticsFilter with NewAnalyticsFilter, DateRange is eg: "last 90 days"
+type AnalyticsFilterNames struct {
+	Projects        []string
+	Applications    []string
+	Groups          []string // name or full path
+	ApplicationTags []string
+	ProjectTags     []string
+	ScanTags        []string
+	BranchNames     []string
+	Scanners        []string
+	Severities      []string
+	States          []string
+	Timezone        string
+	DateRange       string
+}
+
+type AnalyticsAgeingStats struct {
+	MostAging            []AnalyticsVulnerabilitiesStats `json:"mostAging"`
+	MeanTimeToResolution AnalyticsMeanTimeStats          `json:"meanTimeToResolution"`
+}
+
+type AnalyticsApplicationRisk struct {
+	ApplicationID string            `json:"applicationId"`
+	Name          string            `json:"name"`
+	Criticality   uint              `json:"criticality"`
+	Severities    map[string]uint64 `json:"severities"` // lower-case severity -> results
+	Total         uint64            `json:"total"`
+	Score         float64           `json:"score"`
+}
+
 type AnalyticsDistributionEntry struct {
 	Label      string  `json:"label"`
 	Density    float32 `json:"density"`
@@ -224,7 +406,7 @@ type AnalyticsSeverityAndstateStats struct {
 type AnalyticsMeanTimeEntry struct {
 	Label    string `json:"label"`
 	Results  int64  `json:"results"`
-	MeanTime int64  `json:"meanTime"`
+	MeanTime int64  `json:"meanTime"` // days
 }
 type AnalyticsMeanTimeStats struct {
 	MeanTimeData      []AnalyticsMeanTimeEntry `json:"meanTimeData"`
@@ -232,6 +414,15 @@ type AnalyticsMeanTimeStats struct {
 	TotalResults      int64                    `json:"totalResults"`
 }
 
+// The mean time to resolution for a severity compared with a target, see GetAnalyticsMeanTimeWithinTarget
+type AnalyticsMeanTimeTarget struct {
+	Severity     string `json:"severity"`
+	Results      int64  `json:"results"`
+	MeanTimeDays int64  `json:"meanTimeDays"`
+	TargetDays   int64  `json:"targetDays"`
+	WithinTarget bool   `json:"withinTarget"`
+}
+
 type AnalyticsVulnerabilitiesStats struct {
 	VulnerabilityName string                           `json:"vulnerabilityName"`
 	Total             int64                            `json:"total"`
@@ -321,6 +512,21 @@ type AuditQueryTree struct {
 	Children []AuditQueryTree
 }
 
+type AuditQueryResult struct {
+	ResultID     string                 `json:"resultId"`
+	SimilarityID int64                  `json:"similarityId"`
+	Severity     string                 `json:"severity"`
+	Nodes        []AuditQueryResultNode `json:"nodes"`
+}
+
+type AuditQueryResultNode struct {
+	FileName string `json:"fileName"`
+	Name     string `json:"name"`
+	Line     uint64 `json:"line"`
+	Column   uint64 `json:"column"`
+	Length   uint64 `json:"length"`
+}
+
 type AuditPermissions struct {
 	View   bool `json:"view"`
 	Update bool `json:"update"`
@@ -355,6 +561,20 @@ type AuditSession struct {
 	LastHeartbeat          time.Time `json:"-"`
 }
 
+// Pools audit sessions per engine/project and keeps them alive in the background
+// create with NewAuditSessionManager, always Close when done
+type AuditSessionManager struct {
+	client      *Cx1Client
+	maxSessions int
+	sessions    []*pooledAuditSession
+	pending     int           // sessions currently being created
+	changed     chan struct{} // closed & replaced whenever a session is released or removed
+	mutex       sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
 type AuditSessionFilters map[string]AuditSessionLanguageFilters
 
 type AuditSessionLanguageFilters struct {
@@ -376,9 +596,13 @@ type AuditScanSourceFile struct {
 }
 
 type AuthenticationProvider struct {
-	Alias      string `json:"alias"`
-	ID         string `json:"internalId,omitempty"`
-	ProviderID string `json:"providerId"`
+	Alias       string            `json:"alias"`
+	ID          string            `json:"internalId,omitempty"`
+	ProviderID  string            `json:"providerId"`
+	DisplayName string            `json:"displayName,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	TrustEmail  bool              `json:"trustEmail"`
+	Config      map[string]string `json:"config,omitempty"` // use GetSAMLConfig/GetOIDCConfig for the typed configuration
 }
 
 type AuthenticationProviderMapper struct {
@@ -399,6 +623,69 @@ type AuthenticationProviderMapperConfig struct {
 	Value         string `json:"attribute.value,omitempty"`
 	Target        string `json:"target,omitempty"`
 	Template      string `json:"template,omitempty"`
+	Claim         string `json:"claim,omitempty"`       // OIDC mappers
+	ClaimValue    string `json:"claim.value,omitempty"` // OIDC role mapper
+	ClaimRole     string `json:"role,omitempty"`        // OIDC role mapper
+}
+
+type AuthenticationProviderMapperChange struct {
+	Action  string // one of AuthenticationProviderMapperAdd/Update/Remove
+	Name    string
+	Current *AuthenticationProviderMapper
+	Desired *AuthenticationProviderMapper
+	Details []string
+}
+
+// Template for a standard set of IdP mappers, the fields are the names of the SAML attributes or OIDC claims
+// provided by the IdP. Empty fields do not produce a mapper.
+type AuthenticationProviderMapperTemplate struct {
+	Email         string
+	FirstName     string
+	LastName      string
+	Username      string            // SAML attribute or OIDC claim used to build the username
+	Groups        string            // attribute containing the group names, mapped to Cx1 groups with the same path
+	RoleAttribute string            // attribute checked for role mapping
+	Roles         map[string]string // attribute value -> role, for example "Scanner" -> "ast-app.ast-scanner"
+}
+
+type OIDCProviderConfig struct {
+	Issuer               string
+	AuthorizationURL     string
+	TokenURL             string
+	UserInfoURL          string
+	LogoutURL            string
+	JWKSURL              string
+	ClientID             string
+	ClientSecret         string
+	ClientAuthMethod     string // client_secret_post, client_secret_basic, client_secret_jwt or private_key_jwt
+	DefaultScope         string
+	ValidateSignature    bool
+	PKCEEnabled          bool
+	PKCEMethod           string
+	SyncMode             string
+	DisableUserInfo      bool
+	BackchannelSupported bool
+	AdditionalConfig     map[string]string // other keycloak config entries, preserved as-is
+}
+
+type SAMLProviderConfig struct {
+	IdPEntityID             string
+	SingleSignOnServiceURL  string
+	SingleLogoutServiceURL  string
+	SigningCertificates     []string // base64 DER certificates, without PEM headers
+	NameIDPolicyFormat      string
+	PrincipalType           string // SUBJECT, ATTRIBUTE or FRIENDLY_ATTRIBUTE
+	PrincipalAttribute      string
+	SPEntityID              string
+	PostBindingAuthnRequest bool
+	PostBindingResponse     bool
+	PostBindingLogout       bool
+	WantAuthnRequestsSigned bool
+	WantAssertionsSigned    bool
+	WantAssertionsEncrypted bool
+	ValidateSignature       bool
+	SyncMode                string
+	AdditionalConfig        map[string]string // other keycloak config entries, preserved as-is
 }
 
 type ConfigurationSetting struct {
@@ -516,6 +803,144 @@ type OIDCClientScope struct {
 	Protocol    string `json:"protocol"`
 }
 
+// Audit record for a client secret rotation, the secret itself is never included
+type OIDCClientSecretRotation struct {
+	ID             string    `json:"id"`
+	ClientID       string    `json:"clientId"`
+	RotatedAt      time.Time `json:"rotatedAt"`
+	RotatedBy      string    `json:"rotatedBy"`
+	PreviousExpiry time.Time `json:"previousExpiry"`
+	NewExpiry      time.Time `json:"newExpiry"`
+	Sink           string    `json:"sink"`
+	Stored         bool      `json:"stored"`
+	Verified       bool      `json:"verified"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Returned by RotateClientSecret when the secret was regenerated but every attempt to store it in the sink failed.
+// The previous secret is no longer valid, so the caller must save Secret elsewhere; it is not part of the error message.
+type SecretStoreError struct {
+	ClientID string
+	Secret   string
+	Err      error
+}
+
+// Receives new client secrets during rotation, eg: to update a vault or configuration file
+type SecretSink interface {
+	StoreSecret(client OIDCClient, secret string) error
+	String() string
+}
+
+// Writes each secret to <Directory>/<clientId>.secret with 0600 permissions
+type FileSecretSink struct {
+	Directory string
+}
+
+// Renders Template to the file at PathTemplate, both are text/template strings with fields .ID, .ClientID, .Secret and .Expiry
+// eg: PathTemplate "/etc/app/{{.ClientID}}.env" and Template "CX1_CLIENT_ID={{.ClientID}}\nCX1_CLIENT_SECRET={{.Secret}}\n"
+type TemplateSecretSink struct {
+	PathTemplate string
+	Template     string
+}
+
+// In-memory stand-in for a secrets vault which keeps all versions of each secret, for testing rotation workflows
+// create with NewLocalVaultSecretSink
+type LocalVaultSecretSink struct {
+	secrets map[string][]string
+	mutex   sync.Mutex
+}
+
+// A permission held by an entity and how it was granted
+type PermissionGrant struct {
+	Permission string   // name of the (non-composite) role
+	Via        string   // PermissionViaUserRole, PermissionViaGroupRole or PermissionViaAssignment
+	Source     string   // the user, group path or access assignment resource that holds the role
+	RolePath   []string // the assigned role followed by the composite roles leading to the permission
+}
+
+// The effective permissions of a user or service account on a resource, calculated by a PermissionResolver
+type EffectivePermissions struct {
+	EntityID     string
+	EntityName   string
+	ResourceType string // tenant, application or project
+	ResourceID   string
+	Grants       []PermissionGrant
+}
+
+// Resolves effective permissions from roles, composite roles, groups and access assignments.
+// Role composites, groups and projects are cached for the lifetime of the resolver, create with NewPermissionResolver
+type PermissionResolver struct {
+	client            *Cx1Client
+	mutex             sync.Mutex
+	composites        map[string][]Role   // role ID -> direct composites
+	roles             map[string]Role     // client/name -> role
+	clientIDs         map[string]string   // client name -> client ID
+	groups            map[string]Group    // group ID -> group, including roles
+	groupsByPath      map[string]Group    // group path -> group
+	projects          map[string]Project  // project ID -> project
+	ancestors         map[string][]Group  // group ID -> the group and its ancestors
+	applicationGroups map[string][]string // application ID -> IDs of groups assigned to the application
+}
+
+type ProvisioningChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"` // one of the Provisioning* constants
+	UserName  string    `json:"username"`
+	UserID    string    `json:"userId,omitempty"`
+	GroupPath string    `json:"group,omitempty"`
+	Details   []string  `json:"details,omitempty"`
+	DryRun    bool      `json:"dryRun"`
+	Applied   bool      `json:"applied"`
+	Error     string    `json:"error,omitempty"`
+
+	user    *User
+	source  *ProvisioningUser
+	groupID string
+}
+
+type ProvisioningOptions struct {
+	DryRun           bool      // only plan the changes, the audit records are still written
+	DeleteUsers      bool      // delete users that are missing from the source instead of disabling them
+	ManagedGroups    []string  // group paths whose memberships are managed, defaults to all groups referenced by the source
+	IdPAlias         string    // users linked to this identity provider are also treated as managed
+	IgnoreUsers      []string  // usernames that are never changed, the tenant owner and the calling user are always ignored
+	MaxLeaverPercent float64   // planning fails if more than this percentage of Cx1 users would be leavers, default 10
+	AllowMassLeavers bool      // disables the MaxLeaverPercent check
+	AuditLog         io.Writer // each change is written here as a line of JSON
+}
+
+// A source of truth for users and their group memberships
+type ProvisioningSource interface {
+	GetUsers() ([]ProvisioningUser, error)
+	String() string
+}
+
+type ProvisioningUser struct {
+	UserName    string   `json:"username"`
+	Email       string   `json:"email"`
+	FirstName   string   `json:"firstName"`
+	LastName    string   `json:"lastName"`
+	Enabled     *bool    `json:"enabled,omitempty"` // defaults to true
+	Groups      []string `json:"groups"`            // group paths (/parent/child) or top-level group names
+	IdPAlias    string   `json:"idpAlias,omitempty"`
+	IdPUserID   string   `json:"idpUserId,omitempty"`
+	IdPUserName string   `json:"idpUserName,omitempty"`
+}
+
+type CSVProvisioningSource struct {
+	Path string
+}
+
+type JSONProvisioningSource struct {
+	Path string
+}
+
+type SCIMProvisioningSource struct {
+	URL    string // base URL of the SCIM 2.0 service, the /Users endpoint is appended
+	Token  string // optional bearer token
+	Client *http.Client
+}
+
 type Preset struct {
 	PresetID           string        `json:"id"`
 	Name               string        `json:"name"`
@@ -590,6 +1015,12 @@ type ProjectScanSchedule struct {
 	Tags          map[string]string `json:"tags"`
 }
 
+type QueryCollectionChange struct {
+	Change  string // QueryChangeAdded, QueryChangeRemoved or QueryChangeModified
+	Query   string
+	Details []string
+}
+
 type QueryError struct {
 	Line        uint64
 	StartColumn uint64
@@ -609,6 +1040,82 @@ type QueryFamily struct {
 	QueryIDs   []string `json:"queryIds"`
 }
 
+// A local directory of query sources, loaded via LoadQueryRepository and applied via SyncQueryRepository
+type QueryRepository struct {
+	Path    string
+	Queries []QueryRepositoryQuery
+}
+
+// Metadata from the <query>.yaml file next to the query source. Fields that are not set are inherited from the base query.
+type QueryRepositoryMetadata struct {
+	Severity    string `yaml:"severity,omitempty"`
+	CWE         *int64 `yaml:"cwe,omitempty"`
+	Description *int64 `yaml:"description,omitempty"`
+	Executable  *bool  `yaml:"executable,omitempty"`
+}
+
+type QueryRepositoryQuery struct {
+	Scope      string // "tenant", "application" or "project" - the top-level directory
+	ScopeName  string // application or project name or ID, empty for tenant
+	Language   string
+	Group      string
+	Name       string
+	Source     string
+	Metadata   QueryRepositoryMetadata
+	SourceFile string
+}
+
+type QuerySyncChange struct {
+	Action          string // QuerySyncCreate, QuerySyncOverride or QuerySyncUpdate
+	Query           QueryRepositoryQuery
+	Level           string
+	LevelID         string
+	Current         *SASTQuery // existing query at this level, nil if it will be created
+	SourceChanged   bool
+	MetadataChanged bool
+	Metadata        AuditSASTQueryMetadata
+	Failures        []QueryFailure
+	Applied         bool
+	Error           error
+}
+
+// A directory with a querytest.yaml file and sample code, loaded via LoadQueryTestSuites
+type QueryTestSuite struct {
+	Name    string      `yaml:"name"`
+	Samples string      `yaml:"samples"` // directory with the sample code relative to the suite, default "samples"
+	Tests   []QueryTest `yaml:"tests"`
+	Path    string      `yaml:"-"`
+}
+
+type QueryTest struct {
+	Name       string                 `yaml:"name"`
+	Language   string                 `yaml:"language"`
+	Group      string                 `yaml:"group"`
+	Query      string                 `yaml:"query"`
+	SourceFile string                 `yaml:"source"` // optional query source relative to the suite, otherwise the current source in Cx1 is run
+	Exact      bool                   `yaml:"exact"`  // fail if there are results not covered by the expectations
+	Expected   []QueryTestExpectation `yaml:"expected"`
+	Source     string                 `yaml:"-"`
+}
+
+// Count results for which the first or last node is in File (suffix match) at Line (0 = any line)
+// Count is the exact number of matching results expected, 0 to expect none; when omitted at least one is expected
+type QueryTestExpectation struct {
+	File  string  `yaml:"file"`
+	Line  uint64  `yaml:"line"`
+	Count *uint64 `yaml:"count"`
+}
+
+type QueryTestResult struct {
+	Suite    string
+	Test     string
+	Passed   bool
+	Failures []string // mismatched expectations or compilation errors
+	Error    error    // the test could not be run
+	Duration time.Duration
+	Results  []AuditQueryResult
+}
+
 type QueryUpdate_v310 struct {
 	// used when saving queries in Cx1
 	Name     string `json:"name"`
@@ -623,6 +1130,12 @@ type QueryUpdateMetadata_v310 struct {
 	Severity uint `json:"severity"`
 }
 
+// A scan result of a type without a registered decoder, the full payload is preserved in Data
+type RawResult struct {
+	ScanResultBase
+	Data json.RawMessage
+}
+
 type ReportStatus struct {
 	ReportID  string `json:"reportId"`
 	Status    string `json:"status"`
@@ -641,6 +1154,72 @@ type ResultsPredicatesBase struct {
 	CreatedAt    string `json:"createdAt,omitempty"`
 }
 
+// Locally computed result metrics, see NewResultMetrics
+type ResultMetrics struct {
+	client  *Cx1Client
+	options ResultMetricsOptions
+	mutex   sync.Mutex
+	state   resultMetricsState
+	open    map[string]map[string]*ResultMetricsFinding // project|branch -> key -> open findings, built from state when nil
+}
+
+// A finding tracked by ResultMetrics, per project, branch, result type and similarity ID
+type ResultMetricsFinding struct {
+	ProjectID       string
+	Branch          string
+	Type            string
+	SimilarityID    string
+	Query           string // query name for SAST and IaC, CVE for SCA and containers
+	Severity        string
+	State           string
+	FirstFoundAt    time.Time
+	OpenedAt        time.Time // FirstFoundAt, or the time it last recurred
+	RemediatedAt    time.Time // zero while open
+	LastSeenAt      time.Time
+	LastScanID      string
+	Remediations    uint64
+	Recurrences     uint64
+	TimeToRemediate time.Duration // total over all remediations
+}
+
+type ResultMetricsMTTR struct {
+	Dimension    string
+	Key          string
+	Type         string
+	Query        string
+	Remediations uint64
+	TotalTime    time.Duration
+	MeanTime     time.Duration
+}
+
+type ResultMetricsOptions struct {
+	SLATargets       map[string]int64 // days per lower-case severity, default AnalyticsDefaultSLATargets
+	Branches         []string         // only process scans of these branches, default all
+	RemediatedStates []string         // states which count as remediated, default NOT_EXPLOITABLE
+	UsePredicates    bool             // take the remediation time of triaged SAST/IaC findings from the predicate history instead of the scan time
+	Since            time.Time        // first run only: process scans created after this, default 90 days ago
+	Overlap          time.Duration    // scans created up to this long before the last completion are checked again, default 24h
+}
+
+type ResultMetricsRecurrence struct {
+	Dimension        string
+	Key              string
+	Findings         uint64
+	RecurredFindings uint64
+	Remediations     uint64
+	Recurrences      uint64
+	Rate             float64 // recurrences per remediation
+}
+
+type ResultMetricsSLABreach struct {
+	Dimension  string
+	Key        string
+	Finding    ResultMetricsFinding
+	Age        time.Duration
+	TargetDays int64
+	Overdue    time.Duration
+}
+
 type ResultState struct {
 	ID        uint64 `json:"id"`
 	Name      string `json:"name"`
@@ -664,6 +1243,42 @@ type Role struct {
 	SubRoles   []Role `json:"-"`
 }
 
+type RolePermissionGap struct {
+	Role    string   `json:"role"`
+	RoleID  string   `json:"roleId"`
+	BasedOn string   `json:"basedOn"` // the built-in role whose previous permissions the custom role contains
+	Missing []string `json:"missing"` // permissions added to the built-in role that the custom role lacks
+}
+
+// Permissions of the built-in composite roles at a point in time, used to detect newly-introduced permissions
+type RoleSnapshot struct {
+	Tenant string              `json:"tenant"`
+	Taken  time.Time           `json:"taken"`
+	Roles  map[string][]string `json:"roles"`
+}
+
+// A custom ast-app role declared as its composite roles and attributes
+type RoleTemplate struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Creator     string   `json:"creator,omitempty" yaml:"creator,omitempty"`
+	Type        string   `json:"type,omitempty" yaml:"type,omitempty"`         // defaults to Role
+	Category    string   `json:"category,omitempty" yaml:"category,omitempty"` // defaults to Composite role
+	Composites  []string `json:"composites,omitempty" yaml:"composites,omitempty"`
+	Extends     []string `json:"extends,omitempty" yaml:"extends,omitempty"` // built-in roles whose current permissions are included
+}
+
+type RoleTemplateDiff struct {
+	Template          string   `json:"template"`
+	RoleID            string   `json:"roleId,omitempty"`
+	Exists            bool     `json:"exists"`
+	AttributeChanges  []string `json:"attributeChanges,omitempty"`
+	MissingComposites []string `json:"missingComposites,omitempty"`
+	ExtraComposites   []string `json:"extraComposites,omitempty"`
+	Applied           bool     `json:"applied"`
+	Error             string   `json:"error,omitempty"`
+}
+
 type RunningScan struct {
 	ScanID    string
 	Status    string
@@ -713,6 +1328,50 @@ type IACResultsPredicates struct {
 	ResultsPredicatesBase // actually the same structure but different endpoint
 }
 
+// Triage for a container image vulnerability, identified by image, package and CVE rather than similarity ID
+type ContainersResultsPredicates struct {
+	ResultsPredicatesBase
+	ImageName       string `json:"imageName"`
+	ImageTag        string `json:"imageTag"`
+	PackageName     string `json:"packageName"`
+	PackageVersion  string `json:"packageVersion"`
+	VulnerabilityID string `json:"vulnerabilityId"`
+}
+
+// Package-level triage applies to all vulnerabilities of a package version in the project
+type SCAPackagePredicates struct {
+	PackageName    string
+	PackageVersion string
+	PackageManager string
+	ProjectID      string
+	Ignore         bool
+	Comment        string    // justification, required when ignoring
+	ExpiresAt      time.Time // when an ignore lapses, zero for no expiry
+	CreatedBy      string
+	CreatedAt      string
+}
+
+// Triage for a single vulnerability (CVE) in a package version
+type SCAResultsPredicates struct {
+	PackageName     string
+	PackageVersion  string
+	PackageManager  string
+	VulnerabilityID string // CVE name
+	ProjectID       string
+	State           string    // one of the SCAState* constants, empty to leave the state unchanged
+	Ignore          bool      // ignored vulnerabilities are excluded from risk counts
+	Comment         string    // justification, required for NotExploitable and ignores
+	ExpiresAt       time.Time // when an ignore lapses, zero for no expiry
+	CreatedBy       string
+	CreatedAt       string
+}
+
+// Predicates for supply-chain security (secret detection and scorecard) results
+type SSCSResultsPredicates struct {
+	ResultsPredicatesBase
+	ScannerType string `json:"scannerType"` // secret-detection or scorecard
+}
+
 /*
 type KeyCloakClient struct {
 	ClientID string `json:"id"`
@@ -793,6 +1452,170 @@ type ScanFilter struct {
 	ToDate    time.Time `url:"to-date,omitempty"`
 }
 
+type SCADependencyNode struct {
+	SCAPackageRef
+	Vulnerable   bool
+	Dependencies []*SCADependencyNode
+}
+
+type SCAExploitableMethod struct {
+	FullName   string `json:"fullName"`
+	ShortName  string `json:"shortName"`
+	Namespace  string `json:"namespace"`
+	SourceFile string `json:"sourceFile"`
+	Line       int    `json:"line"`
+}
+
+type SCAExportStatus struct {
+	ExportID     string `json:"exportId"`
+	ExportStatus string `json:"exportStatus"`
+	FileURL      string `json:"fileUrl"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+type SCALicense struct {
+	PackageID          string  `json:"PackageId"`
+	Name               string  `json:"Name"`
+	ReferenceType      string  `json:"ReferenceType"`
+	Reference          string  `json:"Reference"`
+	URL                string  `json:"Url"`
+	RiskLevel          string  `json:"RiskLevel"`
+	CopyrightRiskScore float64 `json:"CopyrightRiskScore"`
+	PatentRiskScore    float64 `json:"PatentRiskScore"`
+	CopyLeft           string  `json:"CopyLeft"`
+	Linking            string  `json:"Linking"`
+	RoyaltyFree        string  `json:"RoyaltyFree"`
+}
+
+type SCAPackage struct {
+	SCAPackageRef
+	Licenses                   []string          `json:"Licenses"`
+	MatchType                  string            `json:"MatchType"`
+	PackageRepository          string            `json:"PackageRepository"`
+	Severity                   string            `json:"Severity"`
+	RiskScore                  float64           `json:"RiskScore"`
+	CriticalVulnerabilityCount int               `json:"CriticalVulnerabilityCount"`
+	HighVulnerabilityCount     int               `json:"HighVulnerabilityCount"`
+	MediumVulnerabilityCount   int               `json:"MediumVulnerabilityCount"`
+	LowVulnerabilityCount      int               `json:"LowVulnerabilityCount"`
+	Outdated                   bool              `json:"Outdated"`
+	NewestVersion              string            `json:"NewestVersion"`
+	ReleaseDate                string            `json:"ReleaseDate"`
+	IsDirectDependency         bool              `json:"IsDirectDependency"`
+	IsDevelopmentDependency    bool              `json:"IsDevelopmentDependency"`
+	IsTestDependency           bool              `json:"IsTestDependency"`
+	IsMalicious                bool              `json:"IsMalicious"`
+	Locations                  []string          `json:"Locations"`
+	DependencyPaths            [][]SCAPackageRef `json:"DependencyPaths"` // each path runs from a direct dependency to this package
+}
+
+type SCAPackageRef struct {
+	PackageID string `json:"Id"`
+	Name      string `json:"Name"`
+	Version   string `json:"Version"`
+}
+
+// Contents of the SCA ScanReportJson export for a scan, retrieve with GetSCAScanReportByID
+type SCAScanReport struct {
+	ScanID          string             `json:"-"`
+	Packages        []SCAPackage       `json:"Packages"`
+	Vulnerabilities []SCAVulnerability `json:"Vulnerabilities"`
+	Licenses        []SCALicense       `json:"Licenses"`
+	packageIndex    map[string]int
+}
+
+// A suggested fix for a vulnerability: bump DirectPackage so that the vulnerable package resolves to RecommendedVersion
+type SCAUpgradeRecommendation struct {
+	CveName            string
+	Severity           string
+	VulnerablePackage  SCAPackageRef
+	RecommendedVersion string
+	DirectPackage      SCAPackageRef // the dependency declared in the project which pulls in the vulnerable package
+	Path               []SCAPackageRef
+}
+
+type SCAVulnerability struct {
+	ID                 string                 `json:"Id"`
+	CveName            string                 `json:"CveName"`
+	PackageID          string                 `json:"PackageId"`
+	Severity           string                 `json:"Severity"`
+	Score              float64                `json:"Score"`
+	Cwe                string                 `json:"Cwe"`
+	PublishDate        string                 `json:"PublishDate"`
+	Description        string                 `json:"Description"`
+	FixResolutionText  string                 `json:"FixResolutionText"` // the recommended version of the vulnerable package
+	Recommendations    string                 `json:"Recommendations"`
+	IsIgnored          bool                   `json:"IsIgnored"`
+	IsExploitable      bool                   `json:"IsExploitable"`
+	ExploitableMethods []SCAExploitableMethod `json:"ExploitableMethods"`
+	References         []string               `json:"References"`
+}
+
+// A parsed SCIM filter, a list of alternatives (or) each with a list of conditions (and). Use ParseSCIMFilter.
+type SCIMFilter struct {
+	alternatives [][]scimComparison
+}
+
+type SCIMGroup struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	DisplayName string           `json:"displayName"` // the group path without the leading /
+	Members     []SCIMMultiValue `json:"members,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
+type SCIMMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// http.Handler implementing the SCIM 2.0 /Users and /Groups endpoints on top of a Cx1Client, see NewSCIMServer
+type SCIMServer struct {
+	client          *Cx1Client
+	BaseURL         string // external URL of the server, used for meta.location
+	Token           string // requests must carry this bearer token, all requests are rejected if it is empty
+	DisableOnDelete bool   // DELETE /Users disables the user instead of deleting it
+}
+
+type SCIMUser struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id,omitempty"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Name       struct {
+		Formatted  string `json:"formatted,omitempty"`
+		GivenName  string `json:"givenName,omitempty"`
+		FamilyName string `json:"familyName,omitempty"`
+	} `json:"name"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []SCIMMultiValue `json:"emails,omitempty"`
+	Active      *bool            `json:"active,omitempty"` // nil when omitted, see IsActive
+	Groups      []SCIMMultiValue `json:"groups,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
 type ScanConfiguration struct {
 	ScanType string            `json:"type"`
 	Values   map[string]string `json:"value"`
@@ -836,14 +1659,81 @@ type ScanMetrics struct {
 	}
 }
 
+type ScanPreflightIssue struct {
+	Field   string // engine, preset, config key, branch, repository, or project
+	Value   string
+	Message string
+}
+
+type ScanPreflightResult struct {
+	ProjectID string
+	ScanType  string // upload or git
+	Engines   []string
+	Errors    []ScanPreflightIssue // the scan would be rejected or fail
+	Warnings  []ScanPreflightIssue // the scan may run differently than expected
+}
+
+// A scan to be submitted through a ScanScheduler
+type ScanRequest struct {
+	ProjectID string
+	Team      string // used for fairness between teams, defaults to the project's first group
+	ScanType  string // upload or git
+	SourceURL string // upload URL or repository URL
+	Branch    string
+	Settings  []ScanConfiguration
+	Tags      map[string]string
+}
+
+// Holds scan submissions in a local priority queue until the tenant has capacity
+// create with NewScanScheduler, always Close when done
+type ScanScheduler struct {
+	client     *Cx1Client
+	options    ScanSchedulerOptions
+	capacity   int
+	queue      []*ScheduledScan
+	inFlight   []*ScheduledScan // submitted and still queued or running on the tenant
+	projects   map[string]scanSchedulerProject
+	lastActive uint64 // running + queued scans on the tenant at the last check
+	wake       chan struct{}
+	mutex      sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+type ScanSchedulerOptions struct {
+	MaxConcurrentScans int           // overrides the license MaxConcurrentScans
+	CapacityShare      float64       // fraction of the concurrent scans this scheduler may fill, default 1.0
+	MaxPerTeam         int           // maximum scans in flight per team from this scheduler (0 = no limit)
+	PriorityBranches   []string      // branches scanned before others, in addition to each project's main branch
+	PollInterval       time.Duration // how often tenant load is checked, default 30s
+}
+
+type ScheduledScan struct {
+	ScanRequest
+	Criticality    uint // highest criticality of the project's applications
+	PriorityBranch bool
+	QueuedAt       time.Time
+	SubmittedAt    time.Time
+	Scan           Scan
+	Err            error
+	done           chan struct{}
+}
+
 type ScanResultSet struct {
-	SAST         []ScanSASTResult
-	SCA          []ScanSCAResult
-	SCAContainer []ScanSCAContainerResult
-	IAC          []ScanIACResult
-	Containers   []ScanContainersResult
+	SAST            []ScanSASTResult
+	SCA             []ScanSCAResult
+	SCAContainer    []ScanSCAContainerResult
+	IAC             []ScanIACResult
+	Containers      []ScanContainersResult
+	SecretDetection []ScanSecretDetectionResult
+	Scorecard       []ScanScorecardResult
+	Raw             []RawResult // results of types without a registered decoder, or which failed to decode
 }
 
+// Decodes a single result of a registered type and adds it to the result set, see RegisterScanResultType
+type ScanResultDecoder func(data json.RawMessage, results *ScanResultSet) error
+
 type ScanResultsFilter struct {
 	BaseFilter
 	ScanID             string   `url:"scan-id"`
@@ -875,6 +1765,23 @@ type ScanResultBase struct {
 	SourceFileName string
 }
 
+// API Security risk, retrieved with GetScanAPISecResultsByID (not part of the /results response)
+type ScanAPISecResult struct {
+	RiskID        string `json:"risk_id"`
+	APIID         string `json:"api_id"`
+	Name          string `json:"name"`
+	Severity      string `json:"severity"`
+	Status        string `json:"status"`
+	State         string `json:"state"`
+	HTTPMethod    string `json:"http_method"`
+	URL           string `json:"url"` // endpoint
+	Origin        string `json:"origin"`
+	SourceFile    string `json:"source_file"`
+	DiscoveryDate string `json:"discovery_date"`
+	SASTRiskID    string `json:"sast_risk_id"` // similarity ID of the related SAST result, if any
+	ScanID        string `json:"-"`
+}
+
 type ScanContainersResult struct {
 	ScanResultBase
 	Data                 ScanContainersResultData
@@ -923,6 +1830,7 @@ type ScanIACResultData struct {
 	IssueType     string
 	ExpectedValue string
 	Value         string
+	Snippet       *SourceSnippet `json:"snippet,omitempty"` // set by ScanSourceCache.AddSourceContext
 }
 
 type ScanSASTResult struct {
@@ -952,6 +1860,7 @@ type ScanSASTResultNodes struct {
 	TypeName    string
 	MethodLine  uint64
 	Definitions string
+	Snippet     *SourceSnippet `json:"snippet,omitempty"` // set by ScanSourceCache.AddSourceContext
 }
 type ScanSASTResultDetails struct {
 	CweId       int
@@ -994,6 +1903,39 @@ type ScanSASTResultsFilter struct {
 	State                  []string `url:"state,omitempty"`
 }
 
+type ScanScorecardResult struct {
+	ScanResultBase
+	Data ScanScorecardResultData
+}
+
+type ScanScorecardResultData struct {
+	RuleID          string  `json:"ruleId"`
+	RuleName        string  `json:"ruleName"`
+	RuleDescription string  `json:"ruleDescription"`
+	FileName        string  `json:"fileName"`
+	Line            int     `json:"line"`
+	Remediation     string  `json:"remediation"`
+	RemediationLink string  `json:"remediationLink"`
+	Score           float64 `json:"score"`
+}
+
+type ScanSecretDetectionResult struct {
+	ScanResultBase
+	Data ScanSecretDetectionResultData
+}
+
+type ScanSecretDetectionResultData struct {
+	RuleID          string `json:"ruleId"`
+	RuleName        string `json:"ruleName"`
+	RuleDescription string `json:"ruleDescription"`
+	FileName        string `json:"fileName"`
+	Line            int    `json:"line"`
+	Snippet         string `json:"snippet"` // the matching line, with the secret value masked by the platform
+	Remediation     string `json:"remediation"`
+	RemediationLink string `json:"remediationLink"`
+	Validity        string `json:"validity"` // one of the SecretValidity* constants
+}
+
 type ScanSCAResult struct {
 	ScanResultBase
 	Data                 ScanSCAResultData `json:"data"`
@@ -1001,11 +1943,13 @@ type ScanSCAResult struct {
 }
 type ScanSCAResultData struct {
 	PackageIdentifier  string
+	PackageName        string `json:"packageName,omitempty"`    // not returned by all Cx1 versions, see ScanSCAResult.GetPackage
+	PackageVersion     string `json:"packageVersion,omitempty"` // not returned by all Cx1 versions
 	PublishedAt        string
 	Recommendation     string
 	RecommendedVersion string
-	//ExploitableMethods // TODO
-	PackageData []ScanSCAResultPackageData
+	ExploitableMethods []SCAExploitableMethod
+	PackageData        []ScanSCAResultPackageData
 }
 type ScanSCAResultDetails struct {
 	CweId     string
@@ -1062,6 +2006,56 @@ type ScanResultSummary struct {
 	Information ScanResultStatusSummary
 }
 
+// An indexed scan source archive, create with NewScanSourceArchive or ScanSourceCache.GetArchive
+type ScanSourceArchive struct {
+	ScanID string
+	reader *zip.Reader
+	files  map[string]*zip.File // by normalized path
+	dirs   map[string][]string  // names of the entries in each directory of the normalized paths, "." is the root
+	lines  map[string][]string
+	mutex  sync.Mutex
+}
+
+// Downloads scan source archives once and keeps them in memory, and optionally in a directory on disk
+// create with NewScanSourceCache
+type ScanSourceCache struct {
+	MaxArchives int // archives kept in memory, 0 for no limit
+	client      *Cx1Client
+	dir         string
+	archives    map[string]*ScanSourceArchive
+	loaded      []string               // scan IDs in archives, in the order they were loaded
+	scanLock    map[string]*sync.Mutex // held while a scan's archive is loaded, so each is downloaded once
+	mutex       sync.Mutex
+}
+
+type ScanSourceFile struct {
+	Path     string
+	Size     uint64
+	CRC32    uint32
+	Language string // SAST language name, or empty if not detected
+	Modified time.Time
+}
+
+// Lazily downloads and opens a scan's source archive, implements io/fs.FS
+// create with NewScanSources or ScanSourceCache.GetSources
+type ScanSources struct {
+	ScanID  string
+	client  *Cx1Client
+	cache   *ScanSourceCache
+	archive *ScanSourceArchive
+	err     error
+	once    sync.Once
+}
+
+type ScanSourcesDiff struct {
+	OldScanID string
+	NewScanID string
+	Added     []ScanSourceFile
+	Removed   []ScanSourceFile
+	Modified  []ScanSourceFile // as in the new scan
+	Unchanged int
+}
+
 type ScanStatusSummary struct {
 	Canceled  uint64
 	Completed uint64
@@ -1262,6 +2256,15 @@ type ScanSummaryFilter struct {
 	ExcludeTypes   []string `url:"exclude-result-types"` // DEV_AND_TEST, NONE
 }
 
+type SourceSnippet struct {
+	FileName  string
+	Line      uint64 // the line of the result, StartLine <= Line < StartLine+len(Lines)
+	Column    uint64
+	Length    uint64
+	StartLine uint64
+	Lines     []string
+}
+
 type Status struct {
 	ID      int               `json:"id"`
 	Name    string            `json:"name"`
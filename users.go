@@ -61,7 +61,7 @@ func (c Cx1Client) GetUserByID(userID string) (User, error) {
 
 	var user UserWithAttributes
 	// Note: this list includes API Key/service account users from Cx1, remove the /admin/ for regular users only.
-	response, err := c.sendRequestIAM(http.MethodGet, "/auth/admin", fmt.Sprintf("/users/%v?briefRepresentation=false", userID), nil, nil)
+	response, err := c.sendCachedRequestIAM("/auth/admin", fmt.Sprintf("/users/%v?briefRepresentation=false", userID))
 	if err != nil {
 		return User{}, err
 	}